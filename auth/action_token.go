@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"proj/config"
+	"proj/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	EmailVerificationTTL = 24 * time.Hour
+	PasswordResetTTL     = time.Hour
+)
+
+var ErrInvalidActionToken = errors.New("invalid or expired token")
+
+type ActionClaims struct {
+	UserID  uint   `json:"user_id"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// GenerateActionToken issues a signed, single-use token for flows such as
+// email verification and password reset. Any earlier unused token for the
+// same user and purpose is invalidated so only the latest link works.
+func GenerateActionToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	tokenID, err := generateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	if err := config.DB.Model(&models.ActionToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", now).Error; err != nil {
+		return "", err
+	}
+
+	record := models.ActionToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenID:   tokenID,
+		ExpiresAt: expiresAt,
+	}
+	if err := config.DB.Create(&record).Error; err != nil {
+		return "", err
+	}
+
+	claims := ActionClaims{
+		UserID:  userID,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret)
+}
+
+func ConsumeActionToken(tokenString string, purpose string) (uint, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &ActionClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	})
+	if err != nil {
+		return 0, ErrInvalidActionToken
+	}
+
+	claims, ok := token.Claims.(*ActionClaims)
+	if !ok || !token.Valid || claims.Purpose != purpose || claims.ID == "" {
+		return 0, ErrInvalidActionToken
+	}
+
+	now := time.Now()
+	result := config.DB.Model(&models.ActionToken{}).
+		Where("token_id = ? AND user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?",
+			claims.ID, claims.UserID, purpose, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, ErrInvalidActionToken
+	}
+
+	return claims.UserID, nil
+}
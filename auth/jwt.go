@@ -5,9 +5,17 @@ import (
 	"os"
 	"time"
 
+	"proj/config"
+	"proj/models"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
 var jwtSecret = []byte(getJWTSecret())
 
 func getJWTSecret() string {
@@ -19,19 +27,21 @@ func getJWTSecret() string {
 }
 
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID    uint   `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	SessionID uint   `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uint, email string, role string) (string, error) {
+func GenerateToken(userID uint, email string, role string, sessionID uint) (string, error) {
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -49,9 +59,31 @@ func ValidateToken(tokenString string) (*Claims, error) {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if err := checkSessionActive(claims.SessionID); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func checkSessionActive(sessionID uint) error {
+	if sessionID == 0 {
+		return ErrSessionRevoked
+	}
+
+	var session models.Session
+	if err := config.DB.Select("id", "revoked_at", "expires_at").First(&session, sessionID).Error; err != nil {
+		return ErrSessionRevoked
+	}
+
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return ErrSessionRevoked
 	}
 
-	return nil, errors.New("invalid token")
+	return nil
 }
@@ -0,0 +1,83 @@
+package auth
+
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+	RoleOwner     = "owner"
+	RoleRenter    = "renter"
+
+	// RoleUser is the role older accounts were created with before roles
+	// were split into owner and renter. It is treated as a renter.
+	RoleUser = "user"
+)
+
+type Permission string
+
+const (
+	PermissionAdminAccess     Permission = "admin:access"
+	PermissionUsersRead       Permission = "users:read"
+	PermissionDocumentsReview Permission = "documents:review"
+	PermissionVehiclesCreate  Permission = "vehicles:create"
+	PermissionVehiclesManage  Permission = "vehicles:manage"
+	PermissionEarningsRead    Permission = "earnings:read"
+	PermissionBookingsCreate  Permission = "bookings:create"
+	PermissionBookingsManage  Permission = "bookings:manage"
+)
+
+// Renters can list their first vehicle, which makes them an owner; only
+// owners manage a fleet and see earnings. Staff with bookings:manage can see
+// and act on any booking's payments, deposit and invoice.
+
+var rolePermissions = map[string][]Permission{
+	RoleAdmin: {
+		PermissionAdminAccess,
+		PermissionUsersRead,
+		PermissionDocumentsReview,
+		PermissionVehiclesCreate,
+		PermissionVehiclesManage,
+		PermissionEarningsRead,
+		PermissionBookingsCreate,
+		PermissionBookingsManage,
+	},
+	RoleModerator: {
+		PermissionAdminAccess,
+		PermissionUsersRead,
+		PermissionDocumentsReview,
+		PermissionVehiclesCreate,
+		PermissionVehiclesManage,
+		PermissionEarningsRead,
+		PermissionBookingsCreate,
+		PermissionBookingsManage,
+	},
+	RoleOwner: {
+		PermissionVehiclesCreate,
+		PermissionVehiclesManage,
+		PermissionEarningsRead,
+		PermissionBookingsCreate,
+	},
+	RoleRenter: {
+		PermissionVehiclesCreate,
+		PermissionBookingsCreate,
+	},
+}
+
+func NormalizeRole(role string) string {
+	if role == "" || role == RoleUser {
+		return RoleRenter
+	}
+	return role
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[NormalizeRole(role)]
+	return ok
+}
+
+func HasPermission(role string, permission Permission) bool {
+	for _, p := range rolePermissions[NormalizeRole(role)] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
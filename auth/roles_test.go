@@ -0,0 +1,67 @@
+package auth
+
+import "testing"
+
+func TestRolePermissions(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission Permission
+		want       bool
+	}{
+		{RoleRenter, PermissionBookingsCreate, true},
+		{RoleRenter, PermissionVehiclesCreate, true},
+		{RoleRenter, PermissionVehiclesManage, false},
+		{RoleRenter, PermissionEarningsRead, false},
+		{RoleRenter, PermissionAdminAccess, false},
+		{RoleUser, PermissionVehiclesManage, false},
+		{RoleUser, PermissionBookingsCreate, true},
+		{"", PermissionBookingsCreate, true},
+		{RoleOwner, PermissionVehiclesManage, true},
+		{RoleOwner, PermissionEarningsRead, true},
+		{RoleOwner, PermissionBookingsCreate, true},
+		{RoleOwner, PermissionAdminAccess, false},
+		{RoleOwner, PermissionUsersRead, false},
+		{RoleModerator, PermissionAdminAccess, true},
+		{RoleModerator, PermissionDocumentsReview, true},
+		{RoleModerator, PermissionBookingsManage, true},
+		{RoleOwner, PermissionBookingsManage, false},
+		{RoleRenter, PermissionBookingsManage, false},
+		{RoleAdmin, PermissionAdminAccess, true},
+		{RoleAdmin, PermissionUsersRead, true},
+		{"superuser", PermissionBookingsCreate, false},
+	}
+
+	for _, tt := range tests {
+		if got := HasPermission(tt.role, tt.permission); got != tt.want {
+			t.Errorf("HasPermission(%q, %q) = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestRenterAndOwnerDiffer(t *testing.T) {
+	renter := map[Permission]bool{}
+	for _, p := range rolePermissions[RoleRenter] {
+		renter[p] = true
+	}
+
+	extra := 0
+	for _, p := range rolePermissions[RoleOwner] {
+		if !renter[p] {
+			extra++
+		}
+	}
+	if extra == 0 {
+		t.Fatal("owner has no permissions beyond renter")
+	}
+}
+
+func TestIsValidRole(t *testing.T) {
+	for _, role := range []string{RoleAdmin, RoleModerator, RoleOwner, RoleRenter, RoleUser} {
+		if !IsValidRole(role) {
+			t.Errorf("IsValidRole(%q) = false", role)
+		}
+	}
+	if IsValidRole("superuser") {
+		t.Error(`IsValidRole("superuser") = true`)
+	}
+}
@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"proj/config"
+	"proj/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrSessionRevoked      = errors.New("session has been revoked")
+)
+
+const (
+	RevokeReasonLogout        = "logout"
+	RevokeReasonLogoutAll     = "logout_all"
+	RevokeReasonTokenReuse    = "refresh_token_reuse"
+	RevokeReasonPasswordReset = "password_reset"
+	RevokeReasonRoleChange    = "role_change"
+)
+
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+func StartSession(user *models.User, userAgent string, ipAddress string) (*TokenPair, error) {
+	var pair *TokenPair
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		session := models.Session{
+			UserID:    user.ID,
+			UserAgent: userAgent,
+			IPAddress: ipAddress,
+			ExpiresAt: time.Now().Add(RefreshTokenTTL),
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		pair, err = issueTokenPair(tx, user, &session)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+func RefreshSession(refreshToken string) (*TokenPair, error) {
+	var stored models.RefreshToken
+	if err := config.DB.Preload("Session").
+		Where("token_hash = ?", hashToken(refreshToken)).
+		First(&stored).Error; err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if stored.Session.RevokedAt != nil {
+		return nil, ErrSessionRevoked
+	}
+
+	if stored.UsedAt != nil {
+		RevokeSession(stored.SessionID, RevokeReasonTokenReuse)
+		return nil, ErrRefreshTokenReused
+	}
+
+	now := time.Now()
+	if now.After(stored.ExpiresAt) || now.After(stored.Session.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	var pair *TokenPair
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// The conditional update makes rotation atomic: if two requests race
+		// with the same token only one of them marks it used.
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", stored.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		var user models.User
+		if err := tx.First(&user, stored.Session.UserID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+		if !user.IsActive {
+			return ErrSessionRevoked
+		}
+
+		var err error
+		pair, err = issueTokenPair(tx, &user, &stored.Session)
+		return err
+	})
+
+	if errors.Is(err, ErrRefreshTokenReused) {
+		RevokeSession(stored.SessionID, RevokeReasonTokenReuse)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+func RevokeSession(sessionID uint, reason string) error {
+	now := time.Now()
+	return config.DB.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{
+			"revoked_at":     &now,
+			"revoked_reason": reason,
+		}).Error
+}
+
+func RevokeAllSessions(userID uint, reason string) error {
+	now := time.Now()
+	return config.DB.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"revoked_at":     &now,
+			"revoked_reason": reason,
+		}).Error
+}
+
+func issueTokenPair(tx *gorm.DB, user *models.User, session *models.Session) (*TokenPair, error) {
+	rawToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(RefreshTokenTTL)
+
+	refresh := models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: hashToken(rawToken),
+		ExpiresAt: expiresAt,
+	}
+	if err := tx.Create(&refresh).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(session).Updates(map[string]interface{}{
+		"last_used_at": &now,
+		"expires_at":   expiresAt,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	accessToken, err := GenerateToken(user.ID, user.Email, user.Role, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: rawToken,
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+func generateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
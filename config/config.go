@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -21,6 +24,163 @@ func GetDBUrl() string {
 	return url
 }
 
+func GetAppBaseURL() string {
+	url := os.Getenv("APP_BASE_URL")
+	if url == "" {
+		return "http://localhost:8080"
+	}
+	return url
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("invalid %s %q, using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+func getInt64Env(key string, fallback int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		log.Printf("invalid %s %q, using %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+func GetBookingConfirmationWindow() time.Duration {
+	return getDurationEnv("BOOKING_CONFIRMATION_WINDOW", 24*time.Hour)
+}
+
+var defaultFuelPrices = map[string]int64{
+	"petrol":   105,
+	"diesel":   92,
+	"cng":      80,
+	"electric": 10,
+}
+
+// GetFuelPricePerLiter returns the refuel price for a fuel type, read from
+// FUEL_PRICE_<TYPE> (e.g. FUEL_PRICE_PETROL). Electric vehicles are priced
+// per kWh. Unknown fuel types cost nothing.
+func GetFuelPricePerLiter(fuelType string) int64 {
+	fuelType = strings.ToLower(fuelType)
+	fallback := defaultFuelPrices[fuelType]
+
+	key := "FUEL_PRICE_" + strings.ToUpper(fuelType)
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	price, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || price < 0 {
+		log.Printf("invalid %s %q, using %d", key, value, fallback)
+		return fallback
+	}
+	return price
+}
+
+// GetTimeLocation is the zone used for calendar rules such as weekends and
+// hours of the day, read from APP_TIMEZONE.
+func GetTimeLocation() *time.Location {
+	name := os.Getenv("APP_TIMEZONE")
+	if name == "" {
+		name = "Asia/Kolkata"
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("invalid APP_TIMEZONE %q, using UTC", name)
+		return time.UTC
+	}
+	return loc
+}
+
+// GetReferralSignupCredit is granted to a new user who signs up with a
+// referral code.
+func GetReferralSignupCredit() int64 {
+	return getInt64Env("REFERRAL_SIGNUP_CREDIT", 50)
+}
+
+// GetReferralRewardCredit is granted to the referrer once the referred user
+// completes their first ride.
+func GetReferralRewardCredit() int64 {
+	return getInt64Env("REFERRAL_REWARD_CREDIT", 100)
+}
+
+// GetDepositDisputeWindow is how long after a return the owner can still
+// claim against the security deposit before it is released automatically.
+func GetDepositDisputeWindow() time.Duration {
+	return getDurationEnv("DEPOSIT_DISPUTE_WINDOW", 48*time.Hour)
+}
+
+func GetPaymentWebhookSecret() string {
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		return "dev-payment-webhook-secret"
+	}
+	return secret
+}
+
+// GetPaymentWindow is how long a renter has to pay once the owner accepts a
+// booking.
+func GetPaymentWindow() time.Duration {
+	return getDurationEnv("PAYMENT_WINDOW", 2*time.Hour)
+}
+
+// GetPlatformCommissionPercent is the platform's cut of each booking's price,
+// captured on the booking when it is made.
+func GetPlatformCommissionPercent() int {
+	percent := getInt64Env("PLATFORM_COMMISSION_PERCENT", 10)
+	if percent > 100 {
+		log.Printf("invalid PLATFORM_COMMISSION_PERCENT %d, using 10", percent)
+		return 10
+	}
+	return int(percent)
+}
+
+// GetPayoutMinimum is the smallest owner balance swept into a payout.
+func GetPayoutMinimum() int64 {
+	return getInt64Env("PAYOUT_MINIMUM", 500)
+}
+
+func GetPayoutInterval() time.Duration {
+	return getDurationEnv("PAYOUT_INTERVAL", 24*time.Hour)
+}
+
+// GetGSTPercent is the GST rate included in the platform commission.
+func GetGSTPercent() int {
+	return int(getInt64Env("GST_RATE_PERCENT", 18))
+}
+
+func GetPlatformName() string {
+	name := os.Getenv("PLATFORM_NAME")
+	if name == "" {
+		return "Vehicle Rental Platform"
+	}
+	return name
+}
+
+func GetPlatformGSTIN() string {
+	return os.Getenv("PLATFORM_GSTIN")
+}
+
+func GetLateReturnWarningLead() time.Duration {
+	return getDurationEnv("LATE_RETURN_WARNING_LEAD", time.Hour)
+}
+
 func ConnectDB() {
 	var err error
 	dsn := GetDBUrl()
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"proj/auth"
+	"proj/config"
+	"proj/mailer"
+	"proj/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+func RequestEmailVerification(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, uid).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.EmailVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already verified"})
+		return
+	}
+
+	if err := sendVerificationEmail(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Verification email sent",
+	})
+}
+
+func VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid, err := auth.ConsumeActionToken(req.Token, models.ActionTokenPurposeEmailVerification)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	now := time.Now()
+	if err := config.DB.Model(&models.User{}).Where("id = ?", uid).Updates(map[string]interface{}{
+		"email_verified":    true,
+		"email_verified_at": &now,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email verified successfully",
+	})
+}
+
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Always answer the same way so the endpoint can't be used to find out
+	// which emails have accounts.
+	response := gin.H{"message": "If an account exists for this email, a reset link has been sent"}
+
+	var user models.User
+	if err := config.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := auth.GenerateActionToken(user.ID, models.ActionTokenPurposePasswordReset, auth.PasswordResetTTL)
+	if err != nil {
+		log.Printf("failed to generate password reset token for user %d: %v", user.ID, err)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", config.GetAppBaseURL(), token)
+	if err := mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to reset your password. It expires in %d minutes.\n\n%s\n\nIf you didn't request this, you can ignore this email.",
+			user.Name, int(auth.PasswordResetTTL.Minutes()), link),
+		Sensitive: true,
+	}); err != nil {
+		log.Printf("failed to send password reset email to user %d: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid, err := auth.ConsumeActionToken(req.Token, models.ActionTokenPurposePasswordReset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	if err := config.DB.Model(&models.User{}).Where("id = ?", uid).Update("password", string(hashedPassword)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	if err := auth.RevokeAllSessions(uid, auth.RevokeReasonPasswordReset); err != nil {
+		log.Printf("failed to revoke sessions after password reset for user %d: %v", uid, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password reset successfully. Please log in again",
+	})
+}
+
+func sendVerificationEmail(user *models.User) error {
+	token, err := auth.GenerateActionToken(user.ID, models.ActionTokenPurposeEmailVerification, auth.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", config.GetAppBaseURL(), token)
+	return mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %d hours.\n\n%s",
+			user.Name, int(auth.EmailVerificationTTL.Hours()), link),
+		Sensitive: true,
+	})
+}
@@ -0,0 +1,527 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"proj/config"
+	"proj/models"
+	"proj/notifications"
+	"proj/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errAmendmentPending    = errors.New("booking already has a pending amendment")
+	errAmendmentNotPending = errors.New("amendment is not pending")
+)
+
+type ExtendBookingRequest struct {
+	EndTime time.Time `json:"end_time" binding:"required"`
+	Reason  string    `json:"reason"`
+}
+
+type ModifyBookingRequest struct {
+	StartTime           *time.Time `json:"start_time"`
+	EndTime             *time.Time `json:"end_time"`
+	PickupLocation      *string    `json:"pickup_location"`
+	ReturnLocation      *string    `json:"return_location"`
+	PricingModel        *string    `json:"pricing_model"`
+	EstimatedDistanceKm *float64   `json:"estimated_distance_km"`
+	Reason              string     `json:"reason"`
+}
+
+type DecideAmendmentRequest struct {
+	Note string `json:"note"`
+}
+
+func ExtendBooking(c *gin.Context) {
+	bookingID := c.Param("id")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var booking models.Booking
+	if err := config.DB.Preload("Vehicle").First(&booking, bookingID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+
+	if booking.RenterID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the renter can extend a booking"})
+		return
+	}
+
+	if booking.Status != models.BookingStatusConfirmed && booking.Status != models.BookingStatusOngoing {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only confirmed or ongoing bookings can be extended"})
+		return
+	}
+
+	var req ExtendBookingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !req.EndTime.After(booking.EndTime) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New end_time must be after the current end_time"})
+		return
+	}
+
+	amendment := newAmendment(&booking, uid, models.AmendmentTypeExtend, req.Reason)
+	amendment.NewEndTime = req.EndTime
+
+	submitAmendment(c, &booking, &amendment)
+}
+
+func ModifyBooking(c *gin.Context) {
+	bookingID := c.Param("id")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var booking models.Booking
+	if err := config.DB.Preload("Vehicle").First(&booking, bookingID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+
+	if booking.RenterID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the renter can modify a booking"})
+		return
+	}
+
+	if booking.Status != models.BookingStatusPending && booking.Status != models.BookingStatusConfirmed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending or confirmed bookings can be modified. Use extend for ongoing bookings"})
+		return
+	}
+
+	var req ModifyBookingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	amendment := newAmendment(&booking, uid, models.AmendmentTypeModify, req.Reason)
+
+	if req.StartTime != nil {
+		amendment.NewStartTime = *req.StartTime
+	}
+	if req.EndTime != nil {
+		amendment.NewEndTime = *req.EndTime
+	}
+	if req.PickupLocation != nil {
+		amendment.NewPickupLocation = *req.PickupLocation
+	}
+	if req.ReturnLocation != nil {
+		amendment.NewReturnLocation = *req.ReturnLocation
+	}
+	if req.PricingModel != nil {
+		amendment.NewPricingModel = *req.PricingModel
+	}
+	if req.EstimatedDistanceKm != nil {
+		amendment.NewEstimatedDistanceKm = *req.EstimatedDistanceKm
+	}
+
+	if !amendment.NewStartTime.Before(amendment.NewEndTime) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_time must be before end_time"})
+		return
+	}
+
+	if !amendment.NewStartTime.Equal(booking.StartTime) && amendment.NewStartTime.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot move a booking into the past"})
+		return
+	}
+
+	if amendment.NewPickupLocation == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pickup_location cannot be empty"})
+		return
+	}
+
+	if !utils.IsValidPricingModel(amendment.NewPricingModel) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pricing_model must be distance, time, hybrid or daily"})
+		return
+	}
+
+	if amendment.NewPricingModel == models.PricingModelDaily && booking.PricePerDay <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This booking has no day rate"})
+		return
+	}
+
+	submitAmendment(c, &booking, &amendment)
+}
+
+func GetBookingAmendments(c *gin.Context) {
+	bookingID := c.Param("id")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var booking models.Booking
+	if err := config.DB.First(&booking, bookingID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+
+	if booking.RenterID != uid && booking.OwnerID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this booking"})
+		return
+	}
+
+	var amendments []models.BookingAmendment
+	if err := config.DB.Where("booking_id = ?", booking.ID).
+		Order("created_at DESC").
+		Find(&amendments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch amendments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":      len(amendments),
+		"amendments": amendments,
+	})
+}
+
+func ApproveAmendment(c *gin.Context) {
+	decideAmendment(c, true)
+}
+
+func RejectAmendment(c *gin.Context) {
+	decideAmendment(c, false)
+}
+
+func decideAmendment(c *gin.Context, approve bool) {
+	bookingID := c.Param("id")
+	amendmentID := c.Param("amendment_id")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var booking models.Booking
+	if err := config.DB.Preload("Vehicle").First(&booking, bookingID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+
+	if booking.OwnerID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the vehicle owner can approve or reject amendments"})
+		return
+	}
+
+	var req DecideAmendmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var amendment models.BookingAmendment
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		vehicle, err := lockVehicle(tx, booking.VehicleID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, booking.ID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND booking_id = ?", amendmentID, booking.ID).
+			First(&amendment).Error; err != nil {
+			return err
+		}
+
+		if amendment.Status != models.AmendmentStatusPending {
+			return errAmendmentNotPending
+		}
+
+		now := time.Now()
+		amendment.DecidedByID = &uid
+		amendment.DecidedAt = &now
+		amendment.DecisionNote = req.Note
+
+		if !approve {
+			amendment.Status = models.AmendmentStatusRejected
+			return tx.Save(&amendment).Error
+		}
+
+		// The booking may have moved on since the renter asked, in which case
+		// the request no longer describes a change that can be made.
+		if !amendableStatus(amendment.Type, booking.Status) {
+			return errAmendmentNotPending
+		}
+		if !booking.StartTime.Equal(amendment.OldStartTime) || !booking.EndTime.Equal(amendment.OldEndTime) {
+			return errAmendmentNotPending
+		}
+
+		return applyAmendment(tx, vehicle, &booking, &amendment)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Amendment not found"})
+		case errors.Is(err, errAmendmentNotPending):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Amendment is no longer pending"})
+		default:
+			respondBookabilityError(c, err, "Failed to update amendment")
+		}
+		return
+	}
+
+	decision := "rejected"
+	response := gin.H{"amendment": &amendment}
+	if approve {
+		decision = "approved"
+		if payment := chargeAmendment(&booking); payment != nil {
+			response["payment"] = payment
+		}
+	}
+	notifyAmendment(booking.RenterID, &booking, fmt.Sprintf("Your %s request for booking #%d was %s", amendment.Type, booking.ID, decision))
+
+	response["message"] = "Amendment " + decision
+	c.JSON(http.StatusOK, response)
+}
+
+// amendableStatus mirrors the checks made when an amendment is requested:
+// extensions need a confirmed or ongoing booking, other changes one that
+// hasn't started.
+func amendableStatus(amendmentType string, status string) bool {
+	if amendmentType == models.AmendmentTypeExtend {
+		return status == models.BookingStatusConfirmed || status == models.BookingStatusOngoing
+	}
+	return status == models.BookingStatusPending || status == models.BookingStatusConfirmed
+}
+
+// chargeAmendment opens a top-up payment when an applied change left a paid
+// booking owing more than the renter paid, and tells the renter. The renter
+// can also open one later through the booking's payments endpoint.
+func chargeAmendment(booking *models.Booking) *models.Payment {
+	if !isPaidBookingStatus(booking.Status) {
+		return nil
+	}
+
+	payment, _, err := openPayment(booking, booking.RenterID, "")
+	if err != nil {
+		if !errors.Is(err, errNothingOwed) {
+			log.Printf("failed to open top-up payment for booking %d: %v", booking.ID, err)
+		}
+		return nil
+	}
+
+	notifyAmendment(booking.RenterID, booking, fmt.Sprintf("The change to booking #%d costs %d more. Pay it from the booking's payments to cover it.", booking.ID, payment.Amount))
+	return payment
+}
+
+func newAmendment(booking *models.Booking, requestedBy uint, amendmentType string, reason string) models.BookingAmendment {
+	return models.BookingAmendment{
+		BookingID:              booking.ID,
+		RequestedByID:          requestedBy,
+		Type:                   amendmentType,
+		Status:                 models.AmendmentStatusPending,
+		Reason:                 reason,
+		OldStartTime:           booking.StartTime,
+		OldEndTime:             booking.EndTime,
+		NewStartTime:           booking.StartTime,
+		NewEndTime:             booking.EndTime,
+		OldPickupLocation:      booking.PickupLocation,
+		NewPickupLocation:      booking.PickupLocation,
+		OldReturnLocation:      booking.ReturnLocation,
+		NewReturnLocation:      booking.ReturnLocation,
+		OldPricingModel:        booking.PricingModel,
+		NewPricingModel:        booking.PricingModel,
+		OldEstimatedDistanceKm: booking.EstimatedDistanceKm,
+		NewEstimatedDistanceKm: booking.EstimatedDistanceKm,
+		OldEstimatedPrice:      booking.EstimatedPrice,
+	}
+}
+
+// submitAmendment validates the requested change against availability and
+// existing bookings, prices it, and either applies it straight away or
+// parks it for the owner. Pending bookings are applied directly since the
+// owner reviews them anyway when confirming.
+func submitAmendment(c *gin.Context, booking *models.Booking, amendment *models.BookingAmendment) {
+	needsApproval := booking.Vehicle.RequireAmendmentApproval && booking.Status != models.BookingStatusPending
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		vehicle, err := lockVehicle(tx, booking.VehicleID)
+		if err != nil {
+			return err
+		}
+
+		current := models.Booking{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, booking.ID).Error; err != nil {
+			return err
+		}
+		if current.Status != booking.Status || !current.StartTime.Equal(amendment.OldStartTime) || !current.EndTime.Equal(amendment.OldEndTime) {
+			return models.ErrBookingStatusChanged
+		}
+
+		if !amendment.NewStartTime.Equal(amendment.OldStartTime) || !amendment.NewEndTime.Equal(amendment.OldEndTime) {
+			if violations := utils.CheckRentalDuration(vehicle, amendment.NewStartTime, amendment.NewEndTime); len(violations) > 0 {
+				return &utils.BookingRulesError{Violations: violations}
+			}
+		}
+
+		// Notice and horizon only apply to the times being moved, so an
+		// ongoing booking can still be extended.
+		now := time.Now()
+		if !amendment.NewStartTime.Equal(amendment.OldStartTime) {
+			if err := checkBookingNotice(vehicle, amendment.NewStartTime, now); err != nil {
+				return err
+			}
+		}
+		if amendment.NewEndTime.After(amendment.OldEndTime) {
+			if err := checkBookingHorizon(vehicle, amendment.NewEndTime, now); err != nil {
+				return err
+			}
+		}
+
+		var pending int64
+		if err := tx.Model(&models.BookingAmendment{}).
+			Where("booking_id = ? AND status = ?", booking.ID, models.AmendmentStatusPending).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return errAmendmentPending
+		}
+
+		if !needsApproval {
+			return applyAmendment(tx, vehicle, booking, amendment)
+		}
+
+		if err := ensureVehicleBookable(tx, vehicle, amendment.NewStartTime, amendment.NewEndTime, booking.ID); err != nil {
+			return err
+		}
+
+		amendment.NewEstimatedPrice = quoteAmendment(booking, amendment).Total
+		return tx.Create(amendment).Error
+	})
+	if err != nil {
+		if errors.Is(err, errAmendmentPending) {
+			c.JSON(http.StatusConflict, gin.H{"error": "This booking already has a change waiting for the owner's approval"})
+			return
+		}
+		if respondTransitionError(c, err) {
+			return
+		}
+		respondBookabilityError(c, err, "Failed to update booking")
+		return
+	}
+
+	if needsApproval {
+		notifyAmendment(booking.OwnerID, booking, fmt.Sprintf("The renter requested to %s booking #%d", amendment.Type, booking.ID))
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":   "Change requested. Waiting for owner approval",
+			"amendment": amendment,
+		})
+		return
+	}
+
+	payment := chargeAmendment(booking)
+
+	if err := config.DB.Preload("Vehicle").Preload("Owner").Preload("Renter").First(booking, booking.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Booking updated but failed to load details"})
+		return
+	}
+
+	response := gin.H{
+		"message":   "Booking updated successfully",
+		"booking":   booking,
+		"amendment": amendment,
+	}
+	if payment != nil {
+		response["payment"] = payment
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func quoteAmendment(booking *models.Booking, amendment *models.BookingAmendment) models.PriceQuote {
+	durationHours := int(math.Ceil(amendment.NewEndTime.Sub(amendment.NewStartTime).Hours()))
+	quote := utils.QuoteEstimate(bookingPricingVehicle(booking), amendment.NewEstimatedDistanceKm, durationHours, amendment.NewPricingModel)
+	utils.ApplyPricingRules(&quote, booking.PricingRules, amendment.NewStartTime, amendment.NewEndTime, booking.CreatedAt)
+	utils.ApplyBookingDiscounts(&quote, booking)
+	return quote
+}
+
+// applyAmendment re-checks availability and writes the amended fields onto
+// the booking. The caller must hold the vehicle lock.
+func applyAmendment(tx *gorm.DB, vehicle *models.Vehicle, booking *models.Booking, amendment *models.BookingAmendment) error {
+	quote := quoteAmendment(booking, amendment)
+	amendment.NewEstimatedPrice = quote.Total
+	amendment.Status = models.AmendmentStatusApplied
+
+	if err := ensureVehicleBookable(tx, vehicle, amendment.NewStartTime, amendment.NewEndTime, booking.ID); err != nil {
+		return err
+	}
+
+	if err := tx.Save(amendment).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(booking).Updates(map[string]interface{}{
+		"start_time":            amendment.NewStartTime,
+		"end_time":              amendment.NewEndTime,
+		"duration_hours":        int(math.Ceil(amendment.NewEndTime.Sub(amendment.NewStartTime).Hours())),
+		"pickup_location":       amendment.NewPickupLocation,
+		"return_location":       amendment.NewReturnLocation,
+		"pricing_model":         amendment.NewPricingModel,
+		"estimated_distance_km": amendment.NewEstimatedDistanceKm,
+		"estimated_price":       amendment.NewEstimatedPrice,
+		"price_breakdown":       quote,
+		// A new end time gets its own ending-soon and overdue reminders.
+		"ending_soon_notified_at": nil,
+		"overdue_notified_at":     nil,
+	}).Error
+}
+
+func notifyAmendment(userID uint, booking *models.Booking, message string) {
+	if _, err := notifications.Notify(userID, notifications.Notification{
+		Kind:  notifications.KindBooking,
+		Title: fmt.Sprintf("Booking #%d change", booking.ID),
+		Body:  message,
+	}); err != nil {
+		log.Printf("failed to notify user %d about amendment on booking %d: %v", userID, booking.ID, err)
+	}
+}
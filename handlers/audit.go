@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+
+	"proj/config"
+	"proj/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func recordAudit(c *gin.Context, actorID *uint, action string, entityType string, entityID uint, details map[string]interface{}) {
+	entry := models.AuditLog{
+		ActorID:    actorID,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+	}
+
+	if c != nil {
+		entry.IPAddress = c.ClientIP()
+	}
+
+	if len(details) > 0 {
+		if data, err := json.Marshal(details); err == nil {
+			entry.Details = string(data)
+		}
+	}
+
+	if err := config.DB.Create(&entry).Error; err != nil {
+		log.Printf("failed to record audit entry %s for %s %d: %v", action, entityType, entityID, err)
+	}
+}
@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"proj/config"
 	"proj/models"
+	"proj/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type SetAvailabilityRequest struct {
@@ -17,14 +20,24 @@ type SetAvailabilityRequest struct {
 	DaysOfWeek    string    `json:"days_of_week"`
 	StartTime     string    `json:"start_time"`
 	EndTime       string    `json:"end_time"`
+	ExceptDates   string    `json:"except_dates"`
 }
 
 type UpdateAvailabilityRequest struct {
 	AvailableFrom *time.Time `json:"available_from"`
 	AvailableTo   *time.Time `json:"available_to"`
 	Status        *string    `json:"status"`
+	IsRecurring   *bool      `json:"is_recurring"`
+	DaysOfWeek    *string    `json:"days_of_week"`
+	StartTime     *string    `json:"start_time"`
+	EndTime       *string    `json:"end_time"`
+	ExceptDates   *string    `json:"except_dates"`
 }
 
+// availabilityWindowDays is how far ahead GetAvailability expands recurring
+// windows when no range is given.
+const availabilityWindowDays = 14
+
 func SetAvailability(c *gin.Context) {
 	vehicleID := c.Param("id")
 	userID, exists := c.Get("user_id")
@@ -66,11 +79,28 @@ func SetAvailability(c *gin.Context) {
 		return
 	}
 
+	availability := models.Availability{
+		VehicleID:     vehicle.ID,
+		AvailableFrom: req.AvailableFrom,
+		AvailableTo:   req.AvailableTo,
+		IsRecurring:   req.IsRecurring,
+		DaysOfWeek:    req.DaysOfWeek,
+		StartTime:     req.StartTime,
+		EndTime:       req.EndTime,
+		ExceptDates:   req.ExceptDates,
+		Status:        models.AvailabilityStatusAvailable,
+	}
+
+	if err := utils.ValidateAvailability(&availability); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var overlappingBookings int64
 	result := config.DB.Model(&models.Booking{}).
 		Where("vehicle_id = ? AND status IN ? AND ((start_time BETWEEN ? AND ?) OR (end_time BETWEEN ? AND ?) OR (start_time <= ? AND end_time >= ?))",
 			vehicleID,
-			[]string{models.BookingStatusConfirmed, models.BookingStatusOngoing},
+			models.BookingStatusesHoldingVehicle,
 			req.AvailableFrom, req.AvailableTo,
 			req.AvailableFrom, req.AvailableTo,
 			req.AvailableFrom, req.AvailableTo,
@@ -86,17 +116,6 @@ func SetAvailability(c *gin.Context) {
 		return
 	}
 
-	availability := models.Availability{
-		VehicleID:     vehicle.ID,
-		AvailableFrom: req.AvailableFrom,
-		AvailableTo:   req.AvailableTo,
-		IsRecurring:   req.IsRecurring,
-		DaysOfWeek:    req.DaysOfWeek,
-		StartTime:     req.StartTime,
-		EndTime:       req.EndTime,
-		Status:        models.AvailabilityStatusAvailable,
-	}
-
 	if err := config.DB.Create(&availability).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set availability"})
 		return
@@ -129,11 +148,17 @@ func GetAvailability(c *gin.Context) {
 		query = query.Where("status = ?", models.AvailabilityStatusAvailable)
 	}
 
+	windowFrom := time.Now()
+	windowTo := windowFrom.AddDate(0, 0, availabilityWindowDays)
+
 	if fromDate != "" && toDate != "" {
 		from, err1 := time.Parse(time.RFC3339, fromDate)
 		to, err2 := time.Parse(time.RFC3339, toDate)
 		if err1 == nil && err2 == nil {
 			query = query.Where("available_from <= ? AND available_to >= ?", to, from)
+			if from.Before(to) {
+				windowFrom, windowTo = from, to
+			}
 		}
 	}
 
@@ -145,10 +170,19 @@ func GetAvailability(c *gin.Context) {
 		return
 	}
 
+	rules, err := vehicleAvailabilities(config.DB, vehicle.ID, windowFrom, windowTo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch availability"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"vehicle_id":     vehicle.ID,
 		"total":          len(availabilities),
 		"availabilities": availabilities,
+		"open_from":      windowFrom,
+		"open_to":        windowTo,
+		"open_windows":   utils.AvailableIntervals(rules, windowFrom, windowTo),
 	})
 }
 
@@ -206,16 +240,57 @@ func UpdateAvailability(c *gin.Context) {
 		updates["available_to"] = *req.AvailableTo
 	}
 
-	if !effectiveFrom.Before(effectiveTo) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "available_from must be before available_to"})
+	effective := availability
+	effective.AvailableFrom = effectiveFrom
+	effective.AvailableTo = effectiveTo
+	if req.IsRecurring != nil {
+		effective.IsRecurring = *req.IsRecurring
+	}
+	if req.DaysOfWeek != nil {
+		effective.DaysOfWeek = *req.DaysOfWeek
+	}
+	if req.StartTime != nil {
+		effective.StartTime = *req.StartTime
+	}
+	if req.EndTime != nil {
+		effective.EndTime = *req.EndTime
+	}
+	if req.ExceptDates != nil {
+		effective.ExceptDates = *req.ExceptDates
+	}
+
+	// Turning recurrence off drops the weekly pattern rather than failing
+	// validation on the leftover fields.
+	if !effective.IsRecurring {
+		effective.DaysOfWeek, effective.StartTime, effective.EndTime, effective.ExceptDates = "", "", "", ""
+	}
+
+	if err := utils.ValidateAvailability(&effective); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if effective.IsRecurring != availability.IsRecurring {
+		updates["is_recurring"] = effective.IsRecurring
+	}
+	if effective.DaysOfWeek != availability.DaysOfWeek {
+		updates["days_of_week"] = effective.DaysOfWeek
+	}
+	if effective.StartTime != availability.StartTime {
+		updates["start_time"] = effective.StartTime
+	}
+	if effective.EndTime != availability.EndTime {
+		updates["end_time"] = effective.EndTime
+	}
+	if effective.ExceptDates != availability.ExceptDates {
+		updates["except_dates"] = effective.ExceptDates
+	}
+
 	var conflictingBookings int64
 	result := config.DB.Model(&models.Booking{}).
 		Where("vehicle_id = ? AND status IN ? AND start_time <= ? AND end_time >= ?",
 			availability.VehicleID,
-			[]string{models.BookingStatusConfirmed, models.BookingStatusOngoing},
+			models.BookingStatusesHoldingVehicle,
 			effectiveTo,
 			effectiveFrom,
 		).Count(&conflictingBookings)
@@ -301,7 +376,7 @@ func DeleteAvailability(c *gin.Context) {
 	result := config.DB.Model(&models.Booking{}).
 		Where("vehicle_id = ? AND status IN ? AND start_time <= ? AND end_time >= ?",
 			availability.VehicleID,
-			[]string{models.BookingStatusConfirmed, models.BookingStatusOngoing},
+			models.BookingStatusesHoldingVehicle,
 			availability.AvailableTo,
 			availability.AvailableFrom,
 		).Count(&activeBookings)
@@ -372,50 +447,54 @@ func CheckAvailability(c *gin.Context) {
 		return
 	}
 
-	var availabilitySlots int64
-	result := config.DB.Model(&models.Availability{}).
-		Where("vehicle_id = ? AND status = ? AND available_from <= ? AND available_to >= ?",
-			vehicleID,
-			models.AvailabilityStatusAvailable,
-			startTime,
-			endTime,
-		).Count(&availabilitySlots)
-
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check availability slots"})
-		return
+	// Signed-in renters are also checked against the owner's rules about
+	// who may book.
+	var renter *models.User
+	if userID, exists := c.Get("user_id"); exists {
+		if uid, ok := userID.(uint); ok {
+			var user models.User
+			if err := config.DB.First(&user, uid).Error; err == nil {
+				renter = &user
+			}
+		}
 	}
 
-	if availabilitySlots == 0 {
+	var rulesErr *utils.BookingRulesError
+	if errors.As(utils.CheckBookingRules(&vehicle, renter, startTime, endTime, c.Query("brings_helmet") == "true"), &rulesErr) {
 		c.JSON(http.StatusOK, gin.H{
-			"available": false,
-			"reason":    "No availability slot for this time range",
+			"available":  false,
+			"reason":     "Booking does not meet this vehicle's rules",
+			"violations": rulesErr.Violations,
 		})
 		return
 	}
 
-	var conflictingBookings int64
-	result = config.DB.Model(&models.Booking{}).
-		Where("vehicle_id = ? AND status IN ? AND start_time <= ? AND end_time >= ?",
-			vehicleID,
-			[]string{models.BookingStatusConfirmed, models.BookingStatusOngoing},
-			endTime,
-			startTime,
-		).Count(&conflictingBookings)
-
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for booking conflicts"})
-		return
-	}
-
-	if conflictingBookings > 0 {
+	if err := checkBookingWindow(&vehicle, startTime, endTime, time.Now()); err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"available": false,
-			"reason":    "Vehicle is already booked for this time",
+			"reason":    err.Error(),
 		})
 		return
 	}
 
+	if err := ensureVehicleBookable(config.DB, &vehicle, startTime, endTime, 0); err != nil {
+		switch {
+		case errors.Is(err, errNoAvailabilitySlot):
+			c.JSON(http.StatusOK, gin.H{
+				"available": false,
+				"reason":    "No availability slot for this time range",
+			})
+		case errors.Is(err, errBookingConflict):
+			c.JSON(http.StatusOK, gin.H{
+				"available": false,
+				"reason":    "Vehicle is already booked for this time",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check availability"})
+		}
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"available":   true,
 		"vehicle_id":  vehicle.ID,
@@ -429,3 +508,64 @@ func CheckAvailability(c *gin.Context) {
 		},
 	})
 }
+
+// vehicleAvailabilities loads every availability of the vehicle, open or
+// closed, whose bounds overlap [from, to).
+func vehicleAvailabilities(tx *gorm.DB, vehicleID uint, from, to time.Time) ([]models.Availability, error) {
+	var availabilities []models.Availability
+	err := tx.Where("vehicle_id = ? AND available_from < ? AND available_to > ?", vehicleID, to, from).
+		Order("available_from ASC").
+		Find(&availabilities).Error
+	return availabilities, err
+}
+
+// vehicleOpenFor reports whether the vehicle's availability covers all of
+// [start, end). Adjacent windows count as one, so a rental may span several
+// recurring days or slots as long as there is no gap.
+func vehicleOpenFor(tx *gorm.DB, vehicleID uint, start, end time.Time) (bool, error) {
+	availabilities, err := vehicleAvailabilities(tx, vehicleID, start, end)
+	if err != nil {
+		return false, err
+	}
+	return utils.IntervalsCover(utils.AvailableIntervals(availabilities, start, end), start, end), nil
+}
+
+// vehicleFreeIntervals returns when the vehicle can still be booked within
+// [from, to) as of now: its open availability inside the notice and
+// advance-booking window, minus the bookings holding it padded by the
+// turnaround buffer on both sides.
+func vehicleFreeIntervals(tx *gorm.DB, vehicle *models.Vehicle, from, to, now time.Time) ([]utils.Interval, error) {
+	earliest, latest := utils.BookingWindow(vehicle, now)
+	if from.Before(earliest) {
+		from = earliest
+	}
+	if !latest.IsZero() && to.After(latest) {
+		to = latest
+	}
+	if !from.Before(to) {
+		return nil, nil
+	}
+
+	availabilities, err := vehicleAvailabilities(tx, vehicle.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := time.Duration(vehicle.BufferMinutes) * time.Minute
+
+	var bookings []models.Booking
+	if err := tx.Select("start_time", "end_time").
+		Where("vehicle_id = ? AND status IN ? AND start_time < ? AND end_time > ?",
+			vehicle.ID, models.BookingStatusesHoldingVehicle, to.Add(buffer), from.Add(-buffer)).
+		Order("start_time ASC").
+		Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+
+	booked := make([]utils.Interval, len(bookings))
+	for i, b := range bookings {
+		booked[i] = utils.Interval{Start: b.StartTime.Add(-buffer), End: b.EndTime.Add(buffer)}
+	}
+
+	return utils.SubtractIntervals(utils.AvailableIntervals(availabilities, from, to), utils.MergeIntervals(booked)), nil
+}
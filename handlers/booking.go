@@ -1,26 +1,45 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"math"
 	"net/http"
 	"time"
 
 	"proj/config"
 	"proj/models"
+	"proj/payments"
 	"proj/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errVehicleUnavailable = errors.New("vehicle is not available")
+	errNoAvailabilitySlot = errors.New("vehicle is not available for this time range")
+	errBookingConflict    = errors.New("vehicle is already booked for this time")
+	errShortNotice        = errors.New("booking starts too soon")
+	errBeyondHorizon      = errors.New("booking is too far ahead")
+	errRefundExceedsPaid  = errors.New("refund exceeds the amount paid")
 )
 
 type CreateBookingRequest struct {
-	VehicleID       uint      `json:"vehicle_id" binding:"required"`
-	StartTime       time.Time `json:"start_time" binding:"required"`
-	EndTime         time.Time `json:"end_time" binding:"required"`
-	PickupLocation  string    `json:"pickup_location" binding:"required"`
-	ReturnLocation  string    `json:"return_location"`
-	PricingModel    string    `json:"pricing_model"`
-	EstimatedDistanceKm float64 `json:"estimated_distance_km"`
-	Notes           string    `json:"notes"`
+	VehicleID           uint      `json:"vehicle_id" binding:"required"`
+	StartTime           time.Time `json:"start_time" binding:"required"`
+	EndTime             time.Time `json:"end_time" binding:"required"`
+	PickupLocation      string    `json:"pickup_location" binding:"required"`
+	ReturnLocation      string    `json:"return_location"`
+	PricingModel        string    `json:"pricing_model"`
+	EstimatedDistanceKm float64   `json:"estimated_distance_km"`
+	PromoCode           string    `json:"promo_code"`
+	UseCredits          bool      `json:"use_credits"`
+	BringsHelmet        bool      `json:"brings_helmet"`
+	Notes               string    `json:"notes"`
 }
 
 func CreateBooking(c *gin.Context) {
@@ -52,6 +71,17 @@ func CreateBooking(c *gin.Context) {
 		return
 	}
 
+	var renter models.User
+	if err := config.DB.First(&renter, uid).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !renter.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Please verify your email before booking"})
+		return
+	}
+
 	var vehicle models.Vehicle
 	if err := config.DB.Preload("Owner").First(&vehicle, req.VehicleID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
@@ -68,52 +98,36 @@ func CreateBooking(c *gin.Context) {
 		return
 	}
 
-	var availabilitySlots int64
-	result := config.DB.Model(&models.Availability{}).
-		Where("vehicle_id = ? AND status = ? AND available_from <= ? AND available_to >= ?",
-			req.VehicleID,
-			models.AvailabilityStatusAvailable,
-			req.StartTime,
-			req.EndTime,
-		).Count(&availabilitySlots)
-
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check availability"})
+	if err := utils.CheckBookingRules(&vehicle, &renter, req.StartTime, req.EndTime, req.BringsHelmet); err != nil {
+		respondBookabilityError(c, err, "Failed to create booking")
 		return
 	}
 
-	if availabilitySlots == 0 {
-		c.JSON(http.StatusConflict, gin.H{"error": "Vehicle is not available for this time range"})
-		return
-	}
+	durationHours := int(math.Ceil(req.EndTime.Sub(req.StartTime).Hours()))
 
-	var conflictingBookings int64
-	result = config.DB.Model(&models.Booking{}).
-		Where("vehicle_id = ? AND status IN ? AND start_time <= ? AND end_time >= ?",
-			req.VehicleID,
-			[]string{models.BookingStatusConfirmed, models.BookingStatusOngoing},
-			req.EndTime,
-			req.StartTime,
-		).Count(&conflictingBookings)
+	pricingModel := req.PricingModel
+	if pricingModel == "" {
+		pricingModel = models.PricingModelDistance
+	}
 
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for conflicts"})
+	if !utils.IsValidPricingModel(pricingModel) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pricing_model must be distance, time, hybrid or daily"})
 		return
 	}
 
-	if conflictingBookings > 0 {
-		c.JSON(http.StatusConflict, gin.H{"error": "Vehicle is already booked for this time"})
+	if pricingModel == models.PricingModelDaily && vehicle.PricePerDay <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This vehicle has no day rate"})
 		return
 	}
 
-	durationHours := int(math.Ceil(req.EndTime.Sub(req.StartTime).Hours()))
-
-	pricingModel := req.PricingModel
-	if pricingModel == "" {
-		pricingModel = models.PricingModelDistance
+	pricingRules, err := activePricingRules(config.DB, vehicle.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load pricing rules"})
+		return
 	}
 
-	estimatedPrice := utils.EstimatePrice(&vehicle, req.EstimatedDistanceKm, durationHours, pricingModel)
+	quote := utils.QuoteEstimate(&vehicle, req.EstimatedDistanceKm, durationHours, pricingModel)
+	utils.ApplyPricingRules(&quote, pricingRules, req.StartTime, req.EndTime, time.Now())
 
 	securityDeposit := vehicle.PricePerDay
 
@@ -123,30 +137,93 @@ func CreateBooking(c *gin.Context) {
 	}
 
 	booking := models.Booking{
-		VehicleID:       req.VehicleID,
-		RenterID:        uid,
-		OwnerID:         vehicle.OwnerID,
-		StartTime:       req.StartTime,
-		EndTime:         req.EndTime,
-		DurationHours:   durationHours,
-		PricingModel:    pricingModel,
-		PricePerKm:      vehicle.PricePerKm,
-		PricePerHour:    vehicle.PricePerHour,
-		BasePrice:       vehicle.BasePrice,
-		EstimatedPrice:  estimatedPrice,
-		SecurityDeposit: securityDeposit,
-		PickupLocation:  req.PickupLocation,
-		ReturnLocation:  returnLocation,
-		Status:          models.BookingStatusPending,
-		Notes:           req.Notes,
-	}
-
-	if err := config.DB.Create(&booking).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
-		return
-	}
-
-	result = config.DB.Preload("Vehicle").Preload("Owner").Preload("Renter").First(&booking, booking.ID)
+		VehicleID:             req.VehicleID,
+		RenterID:              uid,
+		OwnerID:               vehicle.OwnerID,
+		StartTime:             req.StartTime,
+		EndTime:               req.EndTime,
+		DurationHours:         durationHours,
+		PricingModel:          pricingModel,
+		EstimatedDistanceKm:   req.EstimatedDistanceKm,
+		LateGraceMinutes:      vehicle.LateGraceMinutes,
+		LateFeePerHour:        vehicle.LateFeePerHour,
+		FuelPolicy:            vehicle.FuelPolicy,
+		TankCapacityLiters:    vehicle.TankCapacityLiters,
+		PricePerKm:            vehicle.PricePerKm,
+		PricePerHour:          vehicle.PricePerHour,
+		PricePerDay:           vehicle.PricePerDay,
+		BasePrice:             vehicle.BasePrice,
+		WeeklyDiscountPercent: vehicle.WeeklyDiscountPercent,
+		EstimatedPrice:        quote.Total,
+		PriceBreakdown:        &quote,
+		PricingRules:          pricingRules,
+		SecurityDeposit:       securityDeposit,
+		CommissionPercent:     config.GetPlatformCommissionPercent(),
+		CancellationPolicy:    vehicle.CancellationPolicy,
+		PickupLocation:        req.PickupLocation,
+		ReturnLocation:        returnLocation,
+		Status:                models.BookingStatusPending,
+		Notes:                 req.Notes,
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		lockedVehicle, err := lockVehicle(tx, req.VehicleID)
+		if err != nil {
+			return err
+		}
+
+		if err := checkBookingWindow(lockedVehicle, req.StartTime, req.EndTime, time.Now()); err != nil {
+			return err
+		}
+
+		if err := ensureVehicleBookable(tx, lockedVehicle, req.StartTime, req.EndTime, 0); err != nil {
+			return err
+		}
+
+		if req.PromoCode != "" {
+			if err := applyBookingPromo(tx, &booking, quote.Total, req.PromoCode); err != nil {
+				return err
+			}
+		}
+
+		if req.UseCredits {
+			if err := applyBookingCredits(tx, &booking, quote.Total-booking.PromoDiscount); err != nil {
+				return err
+			}
+		}
+
+		utils.ApplyBookingDiscounts(&quote, &booking)
+		booking.EstimatedPrice = quote.Total
+
+		if err := tx.Create(&booking).Error; err != nil {
+			return err
+		}
+
+		if err := redeemBookingDiscounts(tx, &booking); err != nil {
+			return err
+		}
+
+		return models.RecordBookingCreated(tx, &booking, &uid)
+	})
+	if err != nil {
+		var promoErr *utils.PromoIneligibleError
+		if errors.As(err, &promoErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": promoErr.Reason})
+			return
+		}
+		respondBookabilityError(c, err, "Failed to create booking")
+		return
+	}
+
+	if booking.PromoCodeID != nil {
+		recordAudit(c, &uid, models.AuditActionPromoRedeemed, "booking", booking.ID, map[string]interface{}{
+			"promo_code_id": *booking.PromoCodeID,
+			"code":          booking.PromoCode,
+			"discount":      booking.PromoDiscount,
+		})
+	}
+
+	result := config.DB.Preload("Vehicle").Preload("Owner").Preload("Renter").First(&booking, booking.ID)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Booking created but failed to load details"})
 		return
@@ -255,18 +332,64 @@ func ConfirmBooking(c *gin.Context) {
 		return
 	}
 
-	if booking.Status != models.BookingStatusPending {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Booking is not in pending status"})
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		vehicle, err := lockVehicle(tx, booking.VehicleID)
+		if err != nil {
+			return err
+		}
+
+		// Re-read the booking under the vehicle lock so a concurrent cancel
+		// or confirm can't slip in between the status check and the update.
+		if err := tx.First(&booking, booking.ID).Error; err != nil {
+			return err
+		}
+
+		// Bookings with something to collect wait for the renter's payment
+		// before they are confirmed; free bookings are confirmed directly.
+		target := models.BookingStatusConfirmed
+		var updates map[string]interface{}
+		if amountDue(&booking) > 0 {
+			target = models.BookingStatusAwaitingPayment
+			updates = map[string]interface{}{"payment_due_at": paymentDeadline(&booking, time.Now())}
+		}
+
+		if err := models.CanTransitionBooking(booking.Status, target, models.BookingActorOwner); err != nil {
+			return err
+		}
+
+		if err := ensureVehicleBookable(tx, vehicle, booking.StartTime, booking.EndTime, booking.ID); err != nil {
+			return err
+		}
+
+		if err := models.TransitionBooking(tx, &booking, target, models.BookingActorOwner, &uid, "", updates); err != nil {
+			return err
+		}
+
+		if target != models.BookingStatusConfirmed {
+			return nil
+		}
+		return models.HoldDeposit(tx, &booking, &uid, models.BookingActorOwner)
+	})
+	if err != nil {
+		if respondTransitionError(c, err) {
+			return
+		}
+		respondBookabilityError(c, err, "Failed to confirm booking")
 		return
 	}
 
-	if err := config.DB.Model(&booking).Update("status", models.BookingStatusConfirmed).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm booking"})
+	if err := config.DB.Preload("Vehicle").Preload("Owner").Preload("Renter").First(&booking, bookingID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Booking confirmed but failed to load details"})
 		return
 	}
 
-	if err := config.DB.Preload("Vehicle").Preload("Owner").Preload("Renter").First(&booking, bookingID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Booking confirmed but failed to load details"})
+	if booking.Status == models.BookingStatusAwaitingPayment {
+		notifyPaymentDue(&booking)
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Booking accepted, awaiting payment from the renter",
+			"booking":    booking,
+			"amount_due": amountDue(&booking),
+		})
 		return
 	}
 
@@ -276,6 +399,10 @@ func ConfirmBooking(c *gin.Context) {
 	})
 }
 
+type CancelBookingRequest struct {
+	Reason string `json:"reason" binding:"max=1000"`
+}
+
 func CancelBooking(c *gin.Context) {
 	bookingID := c.Param("id")
 	userID, exists := c.Get("user_id")
@@ -291,7 +418,7 @@ func CancelBooking(c *gin.Context) {
 	}
 
 	var booking models.Booking
-	if err := config.DB.First(&booking, bookingID).Error; err != nil {
+	if err := config.DB.Preload("Vehicle").First(&booking, bookingID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
 		return
 	}
@@ -301,31 +428,82 @@ func CancelBooking(c *gin.Context) {
 		return
 	}
 
-	switch booking.Status {
-	case models.BookingStatusOngoing:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot cancel ongoing booking"})
-		return
-	case models.BookingStatusCompleted:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot cancel completed booking"})
-		return
-	case models.BookingStatusCancelled:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Booking is already cancelled"})
+	var req CancelBookingRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := config.DB.Model(&booking).Update("status", models.BookingStatusCancelled).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel booking"})
+	actor := bookingActor(&booking, uid)
+
+	policy := booking.CancellationPolicy
+	if policy == "" {
+		policy = booking.Vehicle.CancellationPolicy
+	}
+
+	now := time.Now()
+	var outcome utils.CancellationOutcome
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		paid, err := models.LedgerBalance(tx, models.LedgerAccountRenterPrepayments, nil, &booking.ID)
+		if err != nil {
+			return err
+		}
+		outcome = utils.CalculateCancellation(&booking, policy, actor, paid, now)
+
+		updates := map[string]interface{}{
+			"cancelled_at":        &now,
+			"cancelled_by_id":     uid,
+			"cancelled_by_role":   actor,
+			"cancellation_reason": req.Reason,
+			"cancellation_fee":    outcome.Fee,
+			"cancellation_refund": outcome.Refund,
+			"owner_penalty":       outcome.OwnerPenalty,
+		}
+		if err := models.TransitionBooking(tx, &booking, models.BookingStatusCancelled, actor, &uid, req.Reason, updates); err != nil {
+			return err
+		}
+
+		if err := models.ReleaseBookingDiscounts(tx, &booking); err != nil {
+			return err
+		}
+
+		if err := models.ReleaseDeposit(tx, &booking, &uid, actor, "booking cancelled"); err != nil {
+			return err
+		}
+
+		if err := models.PostBookingCancellation(tx, &booking, outcome.Fee, outcome.OwnerPenalty); err != nil {
+			return err
+		}
+
+		if actor != models.BookingActorOwner || outcome.OwnerPenalty == 0 {
+			return nil
+		}
+
+		return tx.Model(&models.User{}).Where("id = ?", booking.OwnerID).
+			Update("owner_cancellations", gorm.Expr("owner_cancellations + 1")).Error
+	})
+	if err != nil {
+		if !respondTransitionError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel booking"})
+		}
 		return
 	}
 
+	if _, err := payments.RefundBooking(config.DB, booking.ID, "booking cancelled"); err != nil {
+		log.Printf("failed to refund payment for cancelled booking %d: %v", booking.ID, err)
+	}
+
 	if err := config.DB.Preload("Vehicle").Preload("Owner").Preload("Renter").First(&booking, bookingID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Booking cancelled but failed to load details"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Booking cancelled successfully",
-		"booking": booking,
+		"message":      "Booking cancelled successfully",
+		"booking":      booking,
+		"cancellation": outcome,
+		"refund":       outcome.Refund,
 	})
 }
 
@@ -357,6 +535,7 @@ func GetActiveBooking(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"active_booking": booking,
+		"late_return":    utils.CalculateLateReturn(&booking, time.Now()),
 	})
 }
 
@@ -378,6 +557,7 @@ func GetBookingHistory(c *gin.Context) {
 		Where("renter_id = ? AND status IN ?", uid, []string{
 			models.BookingStatusCompleted,
 			models.BookingStatusCancelled,
+			models.BookingStatusExpired,
 		}).
 		Order("created_at DESC").
 		Find(&bookings).Error; err != nil {
@@ -453,9 +633,9 @@ func GeneratePickupOTP(c *gin.Context) {
 		return
 	}
 
-	otp, err := utils.StoreOTP(bookingID, "pickup")
+	otp, err := otpStore.Issue(booking.ID, models.OTPPurposePickup)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate OTP"})
+		respondOTPIssueError(c, err)
 		return
 	}
 
@@ -463,7 +643,6 @@ func GeneratePickupOTP(c *gin.Context) {
 		"odometer_start_km":        req.OdometerStart,
 		"fuel_level_start_percent": req.FuelLevelStartPercent,
 		"damage_report_start":      req.DamageReportStart,
-		"pickup_otp":               otp,
 		"pickup_time":              time.Now(),
 	}
 
@@ -472,10 +651,16 @@ func GeneratePickupOTP(c *gin.Context) {
 		return
 	}
 
+	deliveredVia, err := deliverOTP(&booking, models.OTPPurposePickup, otp)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to deliver OTP to the renter"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message":            "Pickup OTP generated successfully",
-		"otp":                otp,
-		"expires_in_minutes": 10,
+		"message":            "Pickup OTP sent to the renter",
+		"delivered_via":      deliveredVia,
+		"expires_in_minutes": int(utils.OTPTTL.Minutes()),
 	})
 }
 
@@ -499,12 +684,12 @@ func VerifyPickupOTP(c *gin.Context) {
 		return
 	}
 
-	if booking.RenterID != uid {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only the renter can verify pickup OTP"})
+	if booking.OwnerID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the vehicle owner can verify pickup OTP"})
 		return
 	}
 
-	if booking.Status != models.BookingStatusConfirmed {
+	if err := models.CanTransitionBooking(booking.Status, models.BookingStatusOngoing, models.BookingActorOwner); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Booking must be confirmed"})
 		return
 	}
@@ -515,13 +700,15 @@ func VerifyPickupOTP(c *gin.Context) {
 		return
 	}
 
-	if !utils.VerifyOTP(bookingID, "pickup", req.OTP) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired OTP"})
+	if err := otpStore.Verify(booking.ID, models.OTPPurposePickup, req.OTP); err != nil {
+		respondOTPVerifyError(c, &booking, models.OTPPurposePickup, uid, err)
 		return
 	}
 
-	if err := config.DB.Model(&booking).Update("status", models.BookingStatusOngoing).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start booking"})
+	if err := models.TransitionBooking(config.DB, &booking, models.BookingStatusOngoing, models.BookingActorOwner, &uid, "pickup OTP verified", nil); err != nil {
+		if !respondTransitionError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start booking"})
+		}
 		return
 	}
 
@@ -582,12 +769,15 @@ func GenerateReturnOTP(c *gin.Context) {
 		return
 	}
 
-	otp, err := utils.StoreOTP(bookingID, "return")
+	otp, err := otpStore.Issue(booking.ID, models.OTPPurposeReturn)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate OTP"})
+		respondOTPIssueError(c, err)
 		return
 	}
 
+	returnTime := time.Now()
+	lateReturn := utils.CalculateLateReturn(&booking, returnTime)
+
 	actualDistanceKm := float64(req.OdometerEnd - booking.OdometerStartKm)
 	
 	fuelConsumed := 0.0
@@ -595,14 +785,20 @@ func GenerateReturnOTP(c *gin.Context) {
 		fuelConsumed = actualDistanceKm / booking.Vehicle.Mileage
 	}
 
+	fuelCharge := utils.CalculateFuelCharge(&booking, req.FuelLevelEndPercent, config.GetFuelPricePerLiter(booking.Vehicle.FuelType))
+
 	updates := map[string]interface{}{
 		"odometer_end_km":        req.OdometerEnd,
 		"actual_distance_km":     actualDistanceKm,
 		"fuel_level_end_percent": req.FuelLevelEndPercent,
 		"fuel_consumed_liters":   fuelConsumed,
+		"fuel_refill_liters":     fuelCharge.RefillLiters,
+		"fuel_price_per_liter":   fuelCharge.PricePerLiter,
+		"fuel_cost_charged":      fuelCharge.Cost,
 		"damage_report_end":      req.DamageReportEnd,
-		"return_otp":             otp,
-		"return_time":            time.Now(),
+		"return_time":            returnTime,
+		"late_minutes":           lateReturn.LateMinutes,
+		"late_fee":               lateReturn.Fee,
 	}
 
 	if err := config.DB.Model(&booking).Updates(updates).Error; err != nil {
@@ -610,13 +806,21 @@ func GenerateReturnOTP(c *gin.Context) {
 		return
 	}
 
+	deliveredVia, err := deliverOTP(&booking, models.OTPPurposeReturn, otp)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to deliver OTP to the renter"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message":            "Return OTP generated successfully",
-		"otp":                otp,
-		"expires_in_minutes": 10,
+		"message":            "Return OTP sent to the renter",
+		"delivered_via":      deliveredVia,
+		"expires_in_minutes": int(utils.OTPTTL.Minutes()),
 		"trip_summary": gin.H{
 			"distance_km":     actualDistanceKm,
 			"fuel_consumed_l": fuelConsumed,
+			"fuel":            fuelCharge,
+			"late_return":     lateReturn,
 		},
 	})
 }
@@ -641,12 +845,12 @@ func VerifyReturnOTP(c *gin.Context) {
 		return
 	}
 
-	if booking.RenterID != uid {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only the renter can verify return OTP"})
+	if booking.OwnerID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the vehicle owner can verify return OTP"})
 		return
 	}
 
-	if booking.Status != models.BookingStatusOngoing {
+	if err := models.CanTransitionBooking(booking.Status, models.BookingStatusCompleted, models.BookingActorOwner); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Booking must be ongoing"})
 		return
 	}
@@ -657,22 +861,46 @@ func VerifyReturnOTP(c *gin.Context) {
 		return
 	}
 
-	if !utils.VerifyOTP(bookingID, "return", req.OTP) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired OTP"})
+	if err := otpStore.Verify(booking.ID, models.OTPPurposeReturn, req.OTP); err != nil {
+		respondOTPVerifyError(c, &booking, models.OTPPurposeReturn, uid, err)
 		return
 	}
 
-	finalPrice := utils.CalculateFinalPrice(&booking)
+	quote := utils.QuoteFinal(&booking)
 
+	depositReleaseAfter := time.Now().Add(config.GetDepositDisputeWindow())
 	updates := map[string]interface{}{
-		"status":      models.BookingStatusCompleted,
-		"final_price": finalPrice,
+		"final_price":           quote.Total,
+		"price_breakdown":       quote,
+		"deposit_release_after": &depositReleaseAfter,
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := models.TransitionBooking(tx, &booking, models.BookingStatusCompleted, models.BookingActorOwner, &uid, "return OTP verified", updates); err != nil {
+			return err
+		}
+		if err := issueInvoice(tx, &booking, &quote, quote.Total); err != nil {
+			return err
+		}
+		if err := models.ReturnUnusedCredits(tx, &booking, &quote); err != nil {
+			return err
+		}
+		if err := models.PostBookingSettlement(tx, &booking, quote.Total); err != nil {
+			return err
+		}
+		return models.CollectShortfallFromDeposit(tx, &booking)
+	})
+	if err != nil {
+		if !respondTransitionError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete booking"})
+		}
+		return
 	}
 
-	if err := config.DB.Model(&booking).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete booking"})
-		return
+	if _, err := payments.RefundBooking(config.DB, booking.ID, "final price below amount paid"); err != nil {
+		log.Printf("failed to refund overpayment on booking %d: %v", booking.ID, err)
 	}
+	chargeShortfall(&booking)
 
 	var vehicle models.Vehicle
 	config.DB.First(&vehicle, booking.VehicleID)
@@ -685,20 +913,398 @@ func VerifyReturnOTP(c *gin.Context) {
 	config.DB.First(&renter, booking.RenterID)
 	config.DB.Model(&renter).Update("total_rentals", renter.TotalRentals+1)
 
+	grantReferralReward(booking.RenterID)
+
 	if err := config.DB.Preload("Vehicle").Preload("Owner").Preload("Renter").First(&booking, bookingID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Booking completed but failed to load details"})
 		return
 	}
 
+	var invoice models.Invoice
+	config.DB.Where("booking_id = ?", booking.ID).First(&invoice)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Return verified successfully. Ride completed!",
 		"booking": booking,
+		"invoice_number": invoice.Number,
 		"payment_summary": gin.H{
 			"estimated_price":  booking.EstimatedPrice,
 			"final_price":      booking.FinalPrice,
 			"security_deposit": booking.SecurityDeposit,
 			"distance_km":      booking.ActualDistanceKm,
 			"fuel_consumed_l":  booking.FuelConsumedLiters,
+			"fuel":             utils.BookingFuelCharge(&booking),
+			"late_minutes":     booking.LateMinutes,
+			"late_fee":         booking.LateFee,
+			"breakdown":        booking.PriceBreakdown,
 		},
 	})
 }
+
+type DisputeBookingRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+func DisputeBooking(c *gin.Context) {
+	bookingID := c.Param("id")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var booking models.Booking
+	if err := config.DB.First(&booking, bookingID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+
+	if booking.RenterID != uid && booking.OwnerID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to dispute this booking"})
+		return
+	}
+
+	var req DisputeBookingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor := bookingActor(&booking, uid)
+	if err := models.TransitionBooking(config.DB, &booking, models.BookingStatusDisputed, actor, &uid, req.Reason, nil); err != nil {
+		if !respondTransitionError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to dispute booking"})
+		}
+		return
+	}
+
+	if err := config.DB.Preload("Vehicle").Preload("Owner").Preload("Renter").First(&booking, bookingID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Booking disputed but failed to load details"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Dispute raised successfully",
+		"booking": booking,
+	})
+}
+
+// ResolveDisputeRequest.RefundAmount is how much of the prepaid rent goes
+// back to the renter when the booking is cancelled; the rest is kept for the
+// owner. It defaults to all of it.
+type ResolveDisputeRequest struct {
+	Status       string `json:"status" binding:"required"`
+	Reason       string `json:"reason" binding:"required"`
+	RefundAmount *int64 `json:"refund_amount" binding:"omitempty,min=0"`
+}
+
+func ResolveDispute(c *gin.Context) {
+	bookingID := c.Param("id")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var req ResolveDisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var booking models.Booking
+	if err := config.DB.First(&booking, bookingID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// A dispute raised during the ride has no final price or claim
+		// window yet; settle it at the estimate and open the window now.
+		var updates map[string]interface{}
+		if req.Status == models.BookingStatusCompleted && booking.DepositReleaseAfter == nil {
+			updates = map[string]interface{}{"deposit_release_after": time.Now().Add(config.GetDepositDisputeWindow())}
+		}
+
+		if err := models.TransitionBooking(tx, &booking, req.Status, models.BookingActorAdmin, &uid, req.Reason, updates); err != nil {
+			return err
+		}
+
+		if booking.Status == models.BookingStatusCompleted {
+			price := booking.FinalPrice
+			if price == 0 {
+				price = booking.EstimatedPrice
+			}
+			if err := issueInvoice(tx, &booking, booking.PriceBreakdown, price); err != nil {
+				return err
+			}
+			if err := models.ReturnUnusedCredits(tx, &booking, booking.PriceBreakdown); err != nil {
+				return err
+			}
+			if err := models.PostBookingSettlement(tx, &booking, price); err != nil {
+				return err
+			}
+			return models.CollectShortfallFromDeposit(tx, &booking)
+		}
+
+		if err := models.ReleaseBookingDiscounts(tx, &booking); err != nil {
+			return err
+		}
+		if err := models.ReleaseDeposit(tx, &booking, &uid, models.BookingActorAdmin, "dispute resolved as cancelled"); err != nil {
+			return err
+		}
+		// A booking disputed after completion was already settled, so the
+		// owner's earnings have to come back before the renter is refunded.
+		if err := models.PostBookingSettlementReversal(tx, &booking); err != nil {
+			return err
+		}
+		if err := models.VoidInvoice(tx, booking.ID, "booking cancelled after dispute: "+req.Reason); err != nil {
+			return err
+		}
+
+		paid, err := models.LedgerBalance(tx, models.LedgerAccountRenterPrepayments, nil, &booking.ID)
+		if err != nil {
+			return err
+		}
+		refund := paid
+		if req.RefundAmount != nil {
+			if *req.RefundAmount > paid {
+				return errRefundExceedsPaid
+			}
+			refund = *req.RefundAmount
+		}
+
+		now := time.Now()
+		if err := tx.Model(&booking).Updates(map[string]interface{}{
+			"cancelled_at":        &now,
+			"cancelled_by_id":     uid,
+			"cancelled_by_role":   models.BookingActorAdmin,
+			"cancellation_reason": req.Reason,
+			"cancellation_fee":    paid - refund,
+			"cancellation_refund": refund,
+		}).Error; err != nil {
+			return err
+		}
+		return models.PostBookingCancellation(tx, &booking, paid-refund, 0)
+	})
+	if errors.Is(err, errRefundExceedsPaid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refund amount exceeds what the renter paid"})
+		return
+	}
+	if err != nil {
+		if !respondTransitionError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve dispute"})
+		}
+		return
+	}
+
+	if _, err := payments.RefundBooking(config.DB, booking.ID, "dispute resolved"); err != nil {
+		log.Printf("failed to refund booking %d after dispute resolution: %v", booking.ID, err)
+	}
+	if booking.Status == models.BookingStatusCompleted {
+		chargeShortfall(&booking)
+	}
+
+	if err := config.DB.Preload("Vehicle").Preload("Owner").Preload("Renter").First(&booking, bookingID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Dispute resolved but failed to load details"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Dispute resolved successfully",
+		"booking": booking,
+	})
+}
+
+func GetBookingTimeline(c *gin.Context) {
+	bookingID := c.Param("id")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var booking models.Booking
+	if err := config.DB.First(&booking, bookingID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+
+	if booking.RenterID != uid && booking.OwnerID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this booking"})
+		return
+	}
+
+	var events []models.BookingEvent
+	if err := config.DB.Where("booking_id = ?", booking.ID).
+		Order("occurred_at ASC, id ASC").
+		Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch booking timeline"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"booking_id": booking.ID,
+		"status":     booking.Status,
+		"total":      len(events),
+		"events":     events,
+	})
+}
+
+func bookingActor(booking *models.Booking, uid uint) string {
+	if booking.OwnerID == uid {
+		return models.BookingActorOwner
+	}
+	return models.BookingActorRenter
+}
+
+// bookingPricingVehicle returns the vehicle with its rates replaced by the
+// ones snapshotted on the booking, so re-pricing a booking later isn't
+// affected by the owner changing their prices in the meantime.
+func bookingPricingVehicle(booking *models.Booking) *models.Vehicle {
+	vehicle := booking.Vehicle
+	vehicle.PricePerKm = booking.PricePerKm
+	vehicle.PricePerHour = booking.PricePerHour
+	vehicle.PricePerDay = booking.PricePerDay
+	vehicle.BasePrice = booking.BasePrice
+	vehicle.WeeklyDiscountPercent = booking.WeeklyDiscountPercent
+	return &vehicle
+}
+
+// respondTransitionError writes the response for state machine errors and
+// reports whether it did, so callers can fall back to their own message.
+func respondTransitionError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, models.ErrInvalidBookingTransition):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrBookingTransitionDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrBookingStatusChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": "Booking was updated by another request, please retry"})
+	default:
+		return false
+	}
+	return true
+}
+
+// lockVehicle takes a row lock on the vehicle for the rest of the
+// transaction. Every path that checks for conflicts and then writes a
+// booking goes through it, so two requests for the same vehicle are
+// serialized and the second one sees the first one's booking.
+func lockVehicle(tx *gorm.DB, vehicleID uint) (*models.Vehicle, error) {
+	var vehicle models.Vehicle
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&vehicle, vehicleID).Error; err != nil {
+		return nil, err
+	}
+
+	if !vehicle.IsAvailable || !vehicle.IsActive {
+		return nil, errVehicleUnavailable
+	}
+
+	return &vehicle, nil
+}
+
+// ensureVehicleBookable checks that the vehicle's availability, recurring
+// windows included, covers the window and no other booking holding the
+// vehicle comes within its turnaround buffer of it.
+// excludeBookingID lets a booking be re-validated against everything but
+// itself.
+func ensureVehicleBookable(tx *gorm.DB, vehicle *models.Vehicle, startTime, endTime time.Time, excludeBookingID uint) error {
+	open, err := vehicleOpenFor(tx, vehicle.ID, startTime, endTime)
+	if err != nil {
+		return err
+	}
+
+	if !open {
+		return errNoAvailabilitySlot
+	}
+
+	buffer := time.Duration(vehicle.BufferMinutes) * time.Minute
+
+	var conflictingBookings int64
+	if err := tx.Model(&models.Booking{}).
+		Where("vehicle_id = ? AND id <> ? AND status IN ? AND start_time < ? AND end_time > ?",
+			vehicle.ID,
+			excludeBookingID,
+			models.BookingStatusesHoldingVehicle,
+			endTime.Add(buffer),
+			startTime.Add(-buffer),
+		).Count(&conflictingBookings).Error; err != nil {
+		return err
+	}
+
+	if conflictingBookings > 0 {
+		return errBookingConflict
+	}
+
+	return nil
+}
+
+// checkBookingWindow enforces the vehicle's minimum notice before the start
+// and its advance-booking horizon, which the whole booking must end within.
+func checkBookingWindow(vehicle *models.Vehicle, startTime, endTime time.Time, now time.Time) error {
+	if err := checkBookingNotice(vehicle, startTime, now); err != nil {
+		return err
+	}
+	return checkBookingHorizon(vehicle, endTime, now)
+}
+
+func checkBookingNotice(vehicle *models.Vehicle, startTime time.Time, now time.Time) error {
+	earliest, _ := utils.BookingWindow(vehicle, now)
+	if startTime.Before(earliest) {
+		return fmt.Errorf("%w: this vehicle must be booked at least %d hours ahead", errShortNotice, vehicle.MinNoticeHours)
+	}
+	return nil
+}
+
+func checkBookingHorizon(vehicle *models.Vehicle, endTime time.Time, now time.Time) error {
+	_, latest := utils.BookingWindow(vehicle, now)
+	if !latest.IsZero() && endTime.After(latest) {
+		return fmt.Errorf("%w: this vehicle can only be booked up to %d days ahead", errBeyondHorizon, vehicle.MaxAdvanceDays)
+	}
+	return nil
+}
+
+func respondBookabilityError(c *gin.Context, err error, fallback string) {
+	var rulesErr *utils.BookingRulesError
+	if errors.As(err, &rulesErr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      "Booking does not meet this vehicle's rules",
+			"violations": rulesErr.Violations,
+		})
+		return
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+	case errors.Is(err, errVehicleUnavailable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vehicle is not available"})
+	case errors.Is(err, errNoAvailabilitySlot):
+		c.JSON(http.StatusConflict, gin.H{"error": "Vehicle is not available for this time range"})
+	case errors.Is(err, errBookingConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Vehicle is already booked for this time"})
+	case errors.Is(err, errShortNotice), errors.Is(err, errBeyondHorizon):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
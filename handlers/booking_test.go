@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"proj/config"
+	"proj/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// openTestDB connects to TEST_DATABASE_URL and migrates it, skipping the
+// test when no database is configured.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if err := models.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	config.DB = db
+	return db
+}
+
+func createTestUser(t *testing.T, db *gorm.DB, name string) models.User {
+	t.Helper()
+
+	suffix := fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+	user := models.User{
+		Name:          name,
+		Email:         suffix + "@example.com",
+		EmailVerified: true,
+		Password:      "x",
+		Phone:         suffix,
+		StudentID:     suffix,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user %s: %v", name, err)
+	}
+	return user
+}
+
+// testRouter serves handlers as the user named in the X-Test-User header.
+func testRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		id, _ := strconv.ParseUint(c.GetHeader("X-Test-User"), 10, 64)
+		c.Set("user_id", uint(id))
+	})
+	r.POST("/bookings", CreateBooking)
+	r.POST("/bookings/:id/confirm", ConfirmBooking)
+	return r
+}
+
+func doJSON(r *gin.Engine, method, path string, userID uint, body interface{}) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", strconv.FormatUint(uint64(userID), 10))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// TestConcurrentBookingsOnlyOneWins races several renters for the same
+// vehicle and time. Pending requests don't hold the vehicle, so every
+// CreateBooking succeeds, but when the owner accepts them all at once only
+// one may end up holding the vehicle.
+func TestConcurrentBookingsOnlyOneWins(t *testing.T) {
+	db := openTestDB(t)
+	const renters = 8
+
+	owner := createTestUser(t, db, "owner")
+	vehicle := models.Vehicle{
+		OwnerID:       owner.ID,
+		VehicleType:   "bike",
+		Brand:         "Test",
+		VehicleModel:  "Racer",
+		Year:          2024,
+		VehicleNumber: fmt.Sprintf("TEST-%d", time.Now().UnixNano()),
+		PricePerHour:  100,
+		PricePerKm:    5,
+		PricePerDay:   1000,
+		Location:      "Campus",
+		IsAvailable:   true,
+		IsActive:      true,
+	}
+	if err := db.Create(&vehicle).Error; err != nil {
+		t.Fatalf("create vehicle: %v", err)
+	}
+
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+	end := start.Add(4 * time.Hour)
+
+	if err := db.Create(&models.Availability{
+		VehicleID:     vehicle.ID,
+		AvailableFrom: start.Add(-24 * time.Hour),
+		AvailableTo:   end.Add(24 * time.Hour),
+		Status:        models.AvailabilityStatusAvailable,
+	}).Error; err != nil {
+		t.Fatalf("create availability: %v", err)
+	}
+
+	r := testRouter()
+
+	var wg sync.WaitGroup
+	created := make(chan uint, renters)
+	for i := 0; i < renters; i++ {
+		renter := createTestUser(t, db, fmt.Sprintf("renter%d", i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := doJSON(r, http.MethodPost, "/bookings", renter.ID, gin.H{
+				"vehicle_id":            vehicle.ID,
+				"start_time":            start,
+				"end_time":              end,
+				"pickup_location":       "Gate 1",
+				"pricing_model":         models.PricingModelTime,
+				"estimated_distance_km": 10,
+			})
+			if w.Code != http.StatusCreated {
+				t.Errorf("create booking: got %d: %s", w.Code, w.Body.String())
+				return
+			}
+			var resp struct {
+				Booking models.Booking `json:"booking"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Errorf("decode booking: %v", err)
+				return
+			}
+			created <- resp.Booking.ID
+		}()
+	}
+	wg.Wait()
+	close(created)
+
+	var bookingIDs []uint
+	for id := range created {
+		bookingIDs = append(bookingIDs, id)
+	}
+	if len(bookingIDs) != renters {
+		t.Fatalf("created %d bookings, want %d", len(bookingIDs), renters)
+	}
+
+	var mu sync.Mutex
+	codes := map[int]int{}
+	for _, id := range bookingIDs {
+		wg.Add(1)
+		go func(id uint) {
+			defer wg.Done()
+			w := doJSON(r, http.MethodPost, fmt.Sprintf("/bookings/%d/confirm", id), owner.ID, gin.H{})
+			mu.Lock()
+			codes[w.Code]++
+			mu.Unlock()
+		}(id)
+	}
+	wg.Wait()
+
+	if codes[http.StatusOK] != 1 || codes[http.StatusConflict] != renters-1 {
+		t.Fatalf("confirm responses = %v, want one 200 and %d 409", codes, renters-1)
+	}
+
+	var holding int64
+	if err := db.Model(&models.Booking{}).
+		Where("vehicle_id = ? AND status IN ?", vehicle.ID, models.BookingStatusesHoldingVehicle).
+		Count(&holding).Error; err != nil {
+		t.Fatalf("count bookings: %v", err)
+	}
+	if holding != 1 {
+		t.Fatalf("%d bookings hold the vehicle, want 1", holding)
+	}
+}
+
+// TestVehicleBufferKeepsBookingsApart checks that a booking may not start or
+// end within the vehicle's turnaround buffer of one that holds it, and that
+// an explicit zero buffer lets bookings run back to back.
+func TestVehicleBufferKeepsBookingsApart(t *testing.T) {
+	db := openTestDB(t)
+
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+	end := start.Add(2 * time.Hour)
+
+	tests := []struct {
+		name          string
+		bufferMinutes int
+		offset        time.Duration
+		wantErr       error
+	}{
+		{name: "inside the buffer after", bufferMinutes: 30, offset: 2*time.Hour + 15*time.Minute, wantErr: errBookingConflict},
+		{name: "inside the buffer before", bufferMinutes: 30, offset: -75 * time.Minute, wantErr: errBookingConflict},
+		{name: "right after the buffer", bufferMinutes: 30, offset: 2*time.Hour + 30*time.Minute},
+		{name: "right before the buffer", bufferMinutes: 30, offset: -90 * time.Minute},
+		{name: "back to back with zero buffer", bufferMinutes: 0, offset: 2 * time.Hour},
+		{name: "overlapping with zero buffer", bufferMinutes: 0, offset: time.Hour, wantErr: errBookingConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner := createTestUser(t, db, "owner")
+			renter := createTestUser(t, db, "renter")
+			vehicle := models.Vehicle{
+				OwnerID:       owner.ID,
+				VehicleType:   "bike",
+				Brand:         "Test",
+				VehicleModel:  "Racer",
+				Year:          2024,
+				VehicleNumber: fmt.Sprintf("TEST-%d", time.Now().UnixNano()),
+				PricePerHour:  100,
+				PricePerKm:    5,
+				PricePerDay:   1000,
+				Location:      "Campus",
+				IsAvailable:   true,
+				IsActive:      true,
+				BufferMinutes: tt.bufferMinutes,
+			}
+			if err := db.Create(&vehicle).Error; err != nil {
+				t.Fatalf("create vehicle: %v", err)
+			}
+
+			if err := db.Create(&models.Availability{
+				VehicleID:     vehicle.ID,
+				AvailableFrom: start.Add(-24 * time.Hour),
+				AvailableTo:   end.Add(24 * time.Hour),
+				Status:        models.AvailabilityStatusAvailable,
+			}).Error; err != nil {
+				t.Fatalf("create availability: %v", err)
+			}
+
+			if err := db.Create(&models.Booking{
+				VehicleID:      vehicle.ID,
+				RenterID:       renter.ID,
+				OwnerID:        owner.ID,
+				StartTime:      start,
+				EndTime:        end,
+				PickupLocation: "Gate 1",
+				PricingModel:   models.PricingModelTime,
+				Status:         models.BookingStatusConfirmed,
+			}).Error; err != nil {
+				t.Fatalf("create booking: %v", err)
+			}
+
+			newStart := start.Add(tt.offset)
+			err := ensureVehicleBookable(db, &vehicle, newStart, newStart.Add(time.Hour), 0)
+			if err != tt.wantErr {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"proj/config"
+	"proj/models"
+	"proj/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// calendarMaxRange caps how far a single calendar request may span.
+const calendarMaxRange = 92 * 24 * time.Hour
+
+var calendarGranularities = map[string]time.Duration{
+	"15m":  15 * time.Minute,
+	"30m":  30 * time.Minute,
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+}
+
+type calendarDay struct {
+	Date        string           `json:"date"`
+	FreeMinutes int64            `json:"free_minutes"`
+	Intervals   []utils.Interval `json:"intervals"`
+}
+
+// GetVehicleCalendar lists the times a vehicle can be booked between from
+// and to (default the next 14 days): its availability, recurring rules
+// included, minus blocked and maintenance windows and bookings holding the
+// vehicle with their turnaround buffer, limited to the vehicle's notice and
+// advance-booking window. Intervals are trimmed to whole steps of granularity (15m, 30m,
+// hour or day; default hour) and also grouped by day in APP_TIMEZONE.
+func GetVehicleCalendar(c *gin.Context) {
+	vehicleID := c.Param("id")
+
+	var vehicle models.Vehicle
+	if err := config.DB.First(&vehicle, vehicleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+
+	now := time.Now()
+	from := now
+	to := now.AddDate(0, 0, availabilityWindowDays)
+
+	if s := c.Query("from"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from format. Use RFC3339 (e.g., 2026-01-10T00:00:00Z)"})
+			return
+		}
+		from = t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to format. Use RFC3339 (e.g., 2026-01-20T00:00:00Z)"})
+			return
+		}
+		to = t
+	}
+
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+	if to.Sub(from) > calendarMaxRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Calendar range cannot exceed 92 days"})
+		return
+	}
+
+	granularity := c.DefaultQuery("granularity", "hour")
+	step, ok := calendarGranularities[granularity]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "granularity must be 15m, 30m, hour or day"})
+		return
+	}
+
+	var free []utils.Interval
+	if vehicle.IsAvailable && vehicle.IsActive {
+		intervals, err := vehicleFreeIntervals(config.DB, &vehicle, from, to, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build calendar"})
+			return
+		}
+		free = utils.AlignIntervals(intervals, step, config.GetTimeLocation())
+	}
+
+	dates, byDate := utils.SplitByDay(free, config.GetTimeLocation())
+	days := make([]calendarDay, 0, len(dates))
+	for _, date := range dates {
+		day := calendarDay{Date: date, Intervals: byDate[date]}
+		for _, interval := range day.Intervals {
+			day.FreeMinutes += int64(interval.End.Sub(interval.Start) / time.Minute)
+		}
+		days = append(days, day)
+	}
+
+	if free == nil {
+		free = []utils.Interval{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"vehicle_id":  vehicle.ID,
+		"from":        from,
+		"to":          to,
+		"granularity": granularity,
+		"timezone":    config.GetTimeLocation().String(),
+		"intervals":   free,
+		"days":        days,
+	})
+}
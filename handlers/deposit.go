@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"proj/auth"
+	"proj/config"
+	"proj/models"
+	"proj/notifications"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errDepositWindowClosed = errors.New("deposit claim window has closed")
+
+type CaptureDepositRequest struct {
+	Amount      int64  `json:"amount" binding:"required,gt=0"`
+	Reason      string `json:"reason" binding:"required"`
+	Description string `json:"description" binding:"max=1000"`
+}
+
+func GetBookingDeposit(c *gin.Context) {
+	bookingID := c.Param("id")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var booking models.Booking
+	if err := config.DB.First(&booking, bookingID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+
+	if booking.RenterID != uid && booking.OwnerID != uid && !auth.HasPermission(c.GetString("role"), auth.PermissionBookingsManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this booking"})
+		return
+	}
+
+	var entries []models.DepositTransaction
+	if err := config.DB.Where("booking_id = ?", booking.ID).Order("created_at ASC").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deposit history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"booking_id":    booking.ID,
+		"status":        booking.DepositStatus,
+		"amount":        booking.SecurityDeposit,
+		"held":          booking.DepositHeld,
+		"captured":      booking.DepositCaptured,
+		"remaining":     models.DepositRemaining(&booking),
+		"released":      booking.DepositReleased,
+		"release_after": booking.DepositReleaseAfter,
+		"released_at":   booking.DepositReleasedAt,
+		"transactions":  entries,
+	})
+}
+
+// CaptureDeposit lets the owner claim part of the deposit for damage or
+// cleaning until the claim window after return closes. Staff
+// with bookings:manage can capture at any time while the deposit is held,
+// e.g. when resolving a dispute.
+func CaptureDeposit(c *gin.Context) {
+	bookingID := c.Param("id")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var req CaptureDepositRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch req.Reason {
+	case models.DepositReasonDamage, models.DepositReasonCleaning, models.DepositReasonOther:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason must be damage, cleaning or other"})
+		return
+	}
+
+	if req.Reason == models.DepositReasonOther && req.Description == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "description is required when reason is other"})
+		return
+	}
+
+	var booking models.Booking
+	if err := config.DB.First(&booking, bookingID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+
+	if booking.OwnerID != uid && !auth.HasPermission(c.GetString("role"), auth.PermissionBookingsManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the vehicle owner can claim against the deposit"})
+		return
+	}
+
+	actor := models.BookingActorOwner
+	if booking.OwnerID != uid {
+		actor = models.BookingActorAdmin
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, booking.ID).Error; err != nil {
+			return err
+		}
+
+		if booking.Status != models.BookingStatusCompleted && booking.Status != models.BookingStatusDisputed {
+			return models.ErrDepositNotHeld
+		}
+
+		if actor == models.BookingActorOwner && booking.DepositReleaseAfter != nil && time.Now().After(*booking.DepositReleaseAfter) {
+			return errDepositWindowClosed
+		}
+
+		return models.CaptureDeposit(tx, &booking, req.Amount, req.Reason, req.Description, &uid, actor)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDepositNotHeld):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Deposit can only be claimed on a completed or disputed booking while it is held"})
+		case errors.Is(err, models.ErrDepositInsufficient):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Amount exceeds the remaining deposit"})
+		case errors.Is(err, errDepositWindowClosed):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The claim window for this deposit has closed"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to capture deposit"})
+		}
+		return
+	}
+
+	if _, err := notifications.Notify(booking.RenterID, notifications.Notification{
+		Kind:  notifications.KindBooking,
+		Title: fmt.Sprintf("Deposit claim on booking #%d", booking.ID),
+		Body: fmt.Sprintf("%d was deducted from your security deposit for %s. %s",
+			req.Amount, req.Reason, req.Description),
+	}); err != nil {
+		log.Printf("failed to notify user %d about deposit capture on booking %d: %v", booking.RenterID, booking.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Deposit captured successfully",
+		"captured":  booking.DepositCaptured,
+		"remaining": models.DepositRemaining(&booking),
+	})
+}
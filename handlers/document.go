@@ -224,23 +224,6 @@ func DeleteDocument(c *gin.Context) {
 }
 
 func GetPendingDocuments(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	var user models.User
-	if err := config.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-		return
-	}
-
-	if user.Role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
-		return
-	}
-
 	documentType := c.Query("type")
 
 	query := config.DB.Model(&models.Document{}).Where("status = ?", models.DocumentStatusPending)
@@ -280,17 +263,6 @@ func VerifyDocument(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if err := config.DB.First(&user, uid).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-		return
-	}
-
-	if user.Role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
-		return
-	}
-
 	var req VerifyDocumentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
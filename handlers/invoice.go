@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"proj/auth"
+	"proj/config"
+	"proj/invoices"
+	"proj/models"
+	"proj/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetBookingInvoice downloads the invoice of a completed booking as a PDF
+// (the default), HTML page or JSON record, picked with ?format=.
+func GetBookingInvoice(c *gin.Context) {
+	bookingID := c.Param("id")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var booking models.Booking
+	if err := config.DB.First(&booking, bookingID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+
+	if booking.RenterID != uid && booking.OwnerID != uid && !auth.HasPermission(c.GetString("role"), auth.PermissionBookingsManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this booking"})
+		return
+	}
+
+	var invoice models.Invoice
+	if err := config.DB.Where("booking_id = ?", booking.ID).First(&invoice).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No invoice yet, invoices are issued when a booking completes"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invoice"})
+		return
+	}
+
+	filename := "invoice-" + strings.ReplaceAll(invoice.Number, "/", "-")
+
+	switch c.DefaultQuery("format", "pdf") {
+	case "json":
+		c.JSON(http.StatusOK, gin.H{"invoice": invoice})
+	case "html":
+		body, err := invoices.RenderHTML(&invoice)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render invoice"})
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", body)
+	case "pdf":
+		body, err := invoices.RenderPDF(&invoice)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render invoice"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, filename))
+		c.Data(http.StatusOK, "application/pdf", body)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be pdf, html or json"})
+	}
+}
+
+// issueInvoice numbers and stores the invoice for a booking that just
+// completed at price. It must run in the completing transaction before the
+// booking is settled in the ledger, since the amount paid is read from the
+// booking's prepayment. A booking is only invoiced once.
+func issueInvoice(tx *gorm.DB, booking *models.Booking, breakdown *models.PriceQuote, price int64) error {
+	var existing int64
+	if err := tx.Model(&models.Invoice{}).Where("booking_id = ?", booking.ID).Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
+	}
+
+	var renter, owner models.User
+	if err := tx.First(&renter, booking.RenterID).Error; err != nil {
+		return err
+	}
+	if err := tx.First(&owner, booking.OwnerID).Error; err != nil {
+		return err
+	}
+
+	var vehicle models.Vehicle
+	if err := tx.Unscoped().First(&vehicle, booking.VehicleID).Error; err != nil {
+		return err
+	}
+
+	prepaid, err := models.LedgerBalance(tx, models.LedgerAccountRenterPrepayments, nil, &booking.ID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	financialYear := utils.FinancialYear(now.In(config.GetTimeLocation()))
+	sequence, number, err := models.NextInvoiceNumber(tx, financialYear)
+	if err != nil {
+		return err
+	}
+
+	gstPercent := config.GetGSTPercent()
+	commission := price * int64(booking.CommissionPercent) / 100
+	taxable, cgst, sgst := utils.SplitInclusiveGST(commission, gstPercent)
+
+	return tx.Create(&models.Invoice{
+		BookingID:         booking.ID,
+		Number:            number,
+		FinancialYear:     financialYear,
+		Sequence:          sequence,
+		IssuedAt:          now,
+		RenterID:          renter.ID,
+		RenterName:        renter.Name,
+		RenterEmail:       renter.Email,
+		OwnerID:           owner.ID,
+		OwnerName:         owner.Name,
+		Vehicle:           fmt.Sprintf("%s %s (%s)", vehicle.Brand, vehicle.VehicleModel, vehicle.VehicleNumber),
+		StartTime:         booking.StartTime,
+		EndTime:           booking.EndTime,
+		PickupTime:        booking.PickupTime,
+		ReturnTime:        booking.ReturnTime,
+		LineItems:         breakdown,
+		Total:             price,
+		AmountPaid:        prepaid,
+		BalanceDue:        price - prepaid,
+		CommissionPercent: booking.CommissionPercent,
+		CommissionAmount:  commission,
+		CommissionTaxable: taxable,
+		GSTPercent:        gstPercent,
+		CGST:              cgst,
+		SGST:              sgst,
+		PlatformName:      config.GetPlatformName(),
+		PlatformGSTIN:     config.GetPlatformGSTIN(),
+	}).Error
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"proj/models"
+	"proj/notifications"
+	"proj/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+var otpStore utils.OTPStore = utils.NewMemoryOTPStore()
+
+func SetOTPStore(store utils.OTPStore) {
+	otpStore = store
+}
+
+func respondOTPIssueError(c *gin.Context, err error) {
+	var retryErr *utils.OTPRetryError
+	if !errors.As(err, &retryErr) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate OTP"})
+		return
+	}
+
+	retryAfter := int(math.Ceil(time.Until(retryErr.RetryAt).Seconds()))
+	c.Header("Retry-After", fmt.Sprint(retryAfter))
+
+	if errors.Is(err, utils.ErrOTPLocked) {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":               "OTP verification is locked after too many incorrect attempts",
+			"retry_after_seconds": retryAfter,
+		})
+		return
+	}
+
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":               "Please wait before generating a new OTP",
+		"retry_after_seconds": retryAfter,
+	})
+}
+
+func respondOTPVerifyError(c *gin.Context, booking *models.Booking, purpose string, actorID uint, err error) {
+	var retryErr *utils.OTPRetryError
+	if errors.As(err, &retryErr) && errors.Is(err, utils.ErrOTPLocked) {
+		if retryErr.LockoutTriggered {
+			handleOTPLockout(c, booking, purpose, actorID, retryErr.RetryAt)
+		}
+
+		retryAfter := int(math.Ceil(time.Until(retryErr.RetryAt).Seconds()))
+		c.Header("Retry-After", fmt.Sprint(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":               "Too many incorrect attempts. OTP verification is locked",
+			"retry_after_seconds": retryAfter,
+		})
+		return
+	}
+
+	switch {
+	case errors.Is(err, utils.ErrOTPInvalid), errors.Is(err, utils.ErrOTPExpired), errors.Is(err, utils.ErrOTPNotFound):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired OTP"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify OTP"})
+	}
+}
+
+func handleOTPLockout(c *gin.Context, booking *models.Booking, purpose string, actorID uint, lockedUntil time.Time) {
+	recordAudit(c, &actorID, models.AuditActionOTPLockout, "booking", booking.ID, map[string]interface{}{
+		"purpose":      purpose,
+		"attempts":     utils.OTPMaxAttempts,
+		"locked_until": lockedUntil,
+	})
+
+	// The owner enters the code and the renter holds it, so both hear about
+	// a lockout.
+	for _, userID := range []uint{booking.OwnerID, booking.RenterID} {
+		if _, err := notifications.Notify(userID, notifications.Notification{
+			Kind:  notifications.KindSecurity,
+			Title: fmt.Sprintf("OTP locked for booking #%d", booking.ID),
+			Body: fmt.Sprintf("The %s OTP for booking #%d was entered incorrectly %d times, so verification is locked until %s. If you didn't expect this, contact support before handing over the vehicle.",
+				purpose, booking.ID, utils.OTPMaxAttempts, lockedUntil.Format(time.RFC1123)),
+		}); err != nil {
+			log.Printf("failed to notify user %d about OTP lockout on booking %d: %v", userID, booking.ID, err)
+		}
+	}
+}
+
+// deliverOTP sends the code to the renter out of band. The owner who
+// generated it never sees it and has to type in what the renter reads out
+// at handover, which proves both parties are present.
+func deliverOTP(booking *models.Booking, purpose string, otp string) ([]string, error) {
+	deliveredVia, err := notifications.Notify(booking.RenterID, notifications.Notification{
+		Kind:      notifications.KindOTP,
+		Title:     fmt.Sprintf("Your %s OTP for booking #%d", purpose, booking.ID),
+		Body:      fmt.Sprintf("Your %s code is %s. It expires in %d minutes. Only share it with the owner at handover.", purpose, otp, int(utils.OTPTTL.Minutes())),
+		Sensitive: true,
+	})
+	if err != nil {
+		log.Printf("failed to deliver %s OTP for booking %d: %v", purpose, booking.ID, err)
+		otpStore.Clear(booking.ID, purpose)
+		return nil, err
+	}
+
+	return deliveredVia, nil
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"proj/config"
+	"proj/models"
+	"proj/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetOverdueBookings lists the caller's vehicles that are past their return
+// time plus grace period.
+func GetOverdueBookings(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	now := time.Now()
+	var bookings []models.Booking
+	if err := overdueBookingsQuery(now).Where("owner_id = ?", uid).Find(&bookings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch overdue bookings"})
+		return
+	}
+
+	respondOverdueBookings(c, bookings, now)
+}
+
+func AdminGetOverdueBookings(c *gin.Context) {
+	now := time.Now()
+	var bookings []models.Booking
+	if err := overdueBookingsQuery(now).Preload("Owner").Find(&bookings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch overdue bookings"})
+		return
+	}
+
+	respondOverdueBookings(c, bookings, now)
+}
+
+func overdueBookingsQuery(now time.Time) *gorm.DB {
+	return config.DB.Preload("Vehicle").Preload("Renter").
+		Where("status = ? AND end_time + late_grace_minutes * INTERVAL '1 minute' < ?", models.BookingStatusOngoing, now).
+		Order("end_time ASC")
+}
+
+func respondOverdueBookings(c *gin.Context, bookings []models.Booking, now time.Time) {
+	overdue := make([]gin.H, 0, len(bookings))
+	for i := range bookings {
+		overdue = append(overdue, gin.H{
+			"booking":     bookings[i],
+			"late_return": utils.CalculateLateReturn(&bookings[i], now),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":    len(overdue),
+		"bookings": overdue,
+	})
+}
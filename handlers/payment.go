@@ -0,0 +1,594 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"proj/auth"
+	"proj/config"
+	"proj/models"
+	"proj/notifications"
+	"proj/payments"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errPaymentWindowClosed = errors.New("payment window has closed")
+	errPaymentInProgress   = errors.New("a payment for this booking is already being processed")
+	errNothingOwed         = errors.New("nothing is owed on this booking")
+	errPaymentOrder        = errors.New("failed to create payment order")
+)
+
+const (
+	paymentOutcomeConfirmed = "confirmed"
+	paymentOutcomeFailed    = "failed"
+	paymentOutcomeRefunded  = "refunded"
+	paymentOutcomeToppedUp  = "topped_up"
+	paymentOutcomeMismatch  = "amount_mismatch"
+)
+
+type CreatePaymentRequest struct {
+	// Simulate is passed to the mock provider: success, failure, async or
+	// async_failure.
+	Simulate string `json:"simulate"`
+}
+
+// amountDue is what the renter pays up front: the estimated price plus the
+// security deposit, which is held against the captured payment.
+func amountDue(booking *models.Booking) int64 {
+	return booking.EstimatedPrice + booking.SecurityDeposit
+}
+
+// topUpDue is what the renter still owes on a booking they already paid for,
+// after an approved change raised its price or, once completed, a final
+// price above what they paid.
+func topUpDue(tx *gorm.DB, booking *models.Booking) (int64, error) {
+	if booking.Status == models.BookingStatusCompleted {
+		return models.ShortfallDue(tx, booking)
+	}
+
+	prepaid, err := models.LedgerBalance(tx, models.LedgerAccountRenterPrepayments, nil, &booking.ID)
+	if err != nil {
+		return 0, err
+	}
+	if due := booking.EstimatedPrice - prepaid; due > 0 {
+		return due, nil
+	}
+	return 0, nil
+}
+
+func isPaidBookingStatus(status string) bool {
+	return status == models.BookingStatusConfirmed || status == models.BookingStatusOngoing
+}
+
+// acceptsTopUp reports whether a booking in status is paid for with top-ups
+// rather than its up-front payment.
+func acceptsTopUp(status string) bool {
+	return isPaidBookingStatus(status) || status == models.BookingStatusCompleted
+}
+
+// paymentDeadline gives the renter the payment window to pay, but never
+// beyond the start of the booking.
+func paymentDeadline(booking *models.Booking, now time.Time) time.Time {
+	deadline := now.Add(config.GetPaymentWindow())
+	if booking.StartTime.Before(deadline) {
+		return booking.StartTime
+	}
+	return deadline
+}
+
+func CreateBookingPayment(c *gin.Context) {
+	bookingID := c.Param("id")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var req CreatePaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var booking models.Booking
+	if err := config.DB.First(&booking, bookingID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+
+	if booking.RenterID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the renter can pay for this booking"})
+		return
+	}
+
+	if booking.Status != models.BookingStatusAwaitingPayment && booking.Status != models.BookingStatusPaymentFailed && !acceptsTopUp(booking.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Booking is not awaiting payment"})
+		return
+	}
+
+	payment, order, err := openPayment(&booking, uid, req.Simulate)
+	if err != nil {
+		if respondTransitionError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, errNothingOwed):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing is owed on this booking"})
+		case errors.Is(err, errPaymentOrder):
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to create payment order"})
+		case errors.Is(err, errPaymentWindowClosed):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The payment window for this booking has closed"})
+		case errors.Is(err, errPaymentInProgress):
+			c.JSON(http.StatusConflict, gin.H{"error": "A payment for this booking is already being processed"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Payment order created",
+		"payment": payment,
+		"order":   order,
+	})
+}
+
+// openPayment creates a provider order and its payment row. Bookings waiting
+// for payment are charged the price and deposit; confirmed and ongoing
+// bookings are charged whatever an approved change left unpaid.
+func openPayment(booking *models.Booking, uid uint, simulate string) (*models.Payment, *payments.Order, error) {
+	kind := models.PaymentKindBooking
+	amount := amountDue(booking)
+	if acceptsTopUp(booking.Status) {
+		due, err := topUpDue(config.DB, booking)
+		if err != nil {
+			return nil, nil, err
+		}
+		if due == 0 {
+			return nil, nil, errNothingOwed
+		}
+		kind, amount = models.PaymentKindTopUp, due
+	}
+
+	order, err := payments.Default.CreateOrder(payments.OrderRequest{
+		BookingID: booking.ID,
+		Amount:    amount,
+		Currency:  payments.Currency,
+		Receipt:   fmt.Sprintf("booking-%d", booking.ID),
+		Simulate:  simulate,
+	})
+	if err != nil {
+		log.Printf("failed to create payment order for booking %d: %v", booking.ID, err)
+		return nil, nil, errPaymentOrder
+	}
+
+	payment := models.Payment{
+		BookingID:       booking.ID,
+		UserID:          uid,
+		Kind:            kind,
+		Provider:        payments.Default.Name(),
+		ProviderOrderID: order.ID,
+		Amount:          order.Amount,
+		Currency:        order.Currency,
+		Status:          models.PaymentStatusCreated,
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(booking, booking.ID).Error; err != nil {
+			return err
+		}
+
+		var inFlight int64
+		if err := tx.Model(&models.Payment{}).
+			Where("booking_id = ? AND status = ?", booking.ID, models.PaymentStatusPending).
+			Count(&inFlight).Error; err != nil {
+			return err
+		}
+		if inFlight > 0 {
+			return errPaymentInProgress
+		}
+
+		if kind == models.PaymentKindTopUp {
+			if !acceptsTopUp(booking.Status) {
+				return models.ErrBookingStatusChanged
+			}
+			return tx.Create(&payment).Error
+		}
+
+		if booking.PaymentDueAt != nil && time.Now().After(*booking.PaymentDueAt) {
+			return errPaymentWindowClosed
+		}
+
+		if booking.Status == models.BookingStatusPaymentFailed {
+			if err := models.TransitionBooking(tx, booking, models.BookingStatusAwaitingPayment, models.BookingActorRenter, &uid, "payment retried", nil); err != nil {
+				return err
+			}
+		} else if booking.Status != models.BookingStatusAwaitingPayment {
+			return models.ErrBookingStatusChanged
+		}
+
+		return tx.Create(&payment).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &payment, order, nil
+}
+
+// CapturePayment completes a payment order on behalf of the renter. Providers
+// that settle asynchronously answer with pending, and the outcome arrives
+// later through PaymentWebhook.
+func CapturePayment(c *gin.Context) {
+	paymentID := c.Param("id")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var payment models.Payment
+	if err := config.DB.First(&payment, paymentID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+
+	if payment.UserID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this payment"})
+		return
+	}
+
+	if payment.Status != models.PaymentStatusCreated {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Payment is already %s", payment.Status)})
+		return
+	}
+
+	result, err := payments.Default.Capture(payment.ProviderOrderID)
+	if err != nil {
+		log.Printf("failed to capture payment %d: %v", payment.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to capture payment"})
+		return
+	}
+
+	if result.Status == payments.StatusPending {
+		if err := config.DB.Model(&models.Payment{}).
+			Where("id = ? AND status = ?", payment.ID, models.PaymentStatusCreated).
+			Updates(map[string]interface{}{
+				"status":              models.PaymentStatusPending,
+				"provider_payment_id": result.PaymentID,
+			}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment"})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":    "Payment is being processed",
+			"payment_id": payment.ID,
+			"status":     models.PaymentStatusPending,
+		})
+		return
+	}
+
+	updated, booking, err := completePayment(result.OrderID, result.PaymentID, result.Status == payments.StatusCaptured, result.Amount, result.FailureReason)
+	if err != nil {
+		log.Printf("failed to complete payment %d: %v", payment.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete payment"})
+		return
+	}
+
+	if updated.Status == models.PaymentStatusFailed {
+		c.JSON(http.StatusPaymentRequired, gin.H{
+			"error":   "Payment failed: " + updated.FailureReason,
+			"payment": updated,
+			"booking": booking,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payment captured",
+		"payment": updated,
+		"booking": booking,
+	})
+}
+
+// PaymentWebhook receives asynchronous payment outcomes from the provider.
+// Deliveries are verified with the provider's signature and may be repeated;
+// completePayment ignores payments that are already settled.
+func PaymentWebhook(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	event, err := payments.Default.VerifyWebhook(body, c.GetHeader(payments.SignatureHeader))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook signature"})
+		return
+	}
+
+	switch event.Type {
+	case payments.EventPaymentCaptured, payments.EventPaymentFailed:
+	default:
+		c.JSON(http.StatusOK, gin.H{"received": true})
+		return
+	}
+
+	_, _, err = completePayment(event.OrderID, event.PaymentID, event.Type == payments.EventPaymentCaptured, event.Amount, event.FailureReason)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+			return
+		}
+		log.Printf("failed to process payment webhook for order %s: %v", event.OrderID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
+
+func GetBookingPayments(c *gin.Context) {
+	bookingID := c.Param("id")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var booking models.Booking
+	if err := config.DB.First(&booking, bookingID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+
+	if booking.RenterID != uid && booking.OwnerID != uid && !auth.HasPermission(c.GetString("role"), auth.PermissionBookingsManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this booking"})
+		return
+	}
+
+	due := amountDue(&booking)
+	if acceptsTopUp(booking.Status) {
+		var err error
+		if due, err = topUpDue(config.DB, &booking); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payments"})
+			return
+		}
+	}
+
+	var records []models.Payment
+	if err := config.DB.Preload("Refunds").Where("booking_id = ?", booking.ID).Order("created_at ASC").Find(&records).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"booking_id":     booking.ID,
+		"status":         booking.Status,
+		"amount_due":     due,
+		"amount_paid":    booking.AmountPaid,
+		"payment_due_at": booking.PaymentDueAt,
+		"payments":       records,
+	})
+}
+
+// completePayment settles a payment once the provider reports its outcome. A
+// captured payment confirms the booking and holds the deposit; a failed one
+// moves the booking to payment_failed so the renter can retry. A payment
+// captured after the booking stopped waiting for it, e.g. because it expired
+// or another attempt went through first, is refunded in full, and so is one
+// whose captured amount doesn't match the order. Payments that are already
+// settled are left untouched, so repeated deliveries are safe.
+func completePayment(orderID string, providerPaymentID string, captured bool, amount int64, failureReason string) (*models.Payment, *models.Booking, error) {
+	var payment models.Payment
+	var booking models.Booking
+	outcome := ""
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider_order_id = ?", orderID).
+			First(&payment).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, payment.BookingID).Error; err != nil {
+			return err
+		}
+
+		if payment.Status != models.PaymentStatusCreated && payment.Status != models.PaymentStatusPending {
+			return nil
+		}
+
+		now := time.Now()
+		if providerPaymentID != "" {
+			payment.ProviderPaymentID = providerPaymentID
+		}
+
+		if !captured {
+			payment.Status = models.PaymentStatusFailed
+			payment.FailureReason = failureReason
+			payment.FailedAt = &now
+			if err := tx.Save(&payment).Error; err != nil {
+				return err
+			}
+
+			if booking.Status != models.BookingStatusAwaitingPayment {
+				return nil
+			}
+			outcome = paymentOutcomeFailed
+			return models.TransitionBooking(tx, &booking, models.BookingStatusPaymentFailed, models.BookingActorSystem, nil, failureReason, nil)
+		}
+
+		// Whatever the provider actually took is what gets recorded, so the
+		// ledger and any refund match the money held. A capture that doesn't
+		// cover the order exactly never pays for the booking.
+		mismatch := amount != payment.Amount
+		if mismatch {
+			payment.FailureReason = fmt.Sprintf("captured %d but the order was for %d", amount, payment.Amount)
+			payment.Amount = amount
+		}
+
+		payment.Status = models.PaymentStatusCaptured
+		payment.CapturedAt = &now
+		if err := tx.Save(&payment).Error; err != nil {
+			return err
+		}
+
+		stillAwaiting := booking.Status == models.BookingStatusAwaitingPayment || booking.Status == models.BookingStatusPaymentFailed
+		if mismatch {
+			stillAwaiting = false
+		} else if payment.Kind == models.PaymentKindTopUp {
+			// A top-up is only kept while the booking still owes at least
+			// that much; otherwise it was overtaken and goes back.
+			due, err := topUpDue(tx, &booking)
+			if err != nil {
+				return err
+			}
+			stillAwaiting = acceptsTopUp(booking.Status) && due >= payment.Amount
+		}
+		if err := models.PostPaymentCaptured(tx, &booking, &payment, stillAwaiting); err != nil {
+			return err
+		}
+
+		// The capture went to refunds_due above and is paid back once this
+		// transaction commits.
+		if !stillAwaiting {
+			outcome = paymentOutcomeRefunded
+			if mismatch {
+				outcome = paymentOutcomeMismatch
+			}
+			return nil
+		}
+
+		if payment.Kind == models.PaymentKindTopUp {
+			outcome = paymentOutcomeToppedUp
+			booking.AmountPaid += payment.Amount
+			return tx.Model(&booking).Update("amount_paid", booking.AmountPaid).Error
+		}
+
+		outcome = paymentOutcomeConfirmed
+		if err := models.TransitionBooking(tx, &booking, models.BookingStatusConfirmed, models.BookingActorSystem, nil, "payment received", map[string]interface{}{
+			"amount_paid": payment.Amount,
+		}); err != nil {
+			return err
+		}
+		booking.AmountPaid = payment.Amount
+
+		return models.HoldDeposit(tx, &booking, nil, models.BookingActorSystem)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if outcome == paymentOutcomeRefunded || outcome == paymentOutcomeMismatch {
+		if _, err := payments.RefundBooking(config.DB, booking.ID, "payment not applied to booking"); err != nil {
+			log.Printf("failed to refund payment %d on booking %d: %v", payment.ID, booking.ID, err)
+		}
+	}
+
+	notifyPaymentOutcome(&booking, &payment, outcome)
+	return &payment, &booking, nil
+}
+
+func notifyPaymentDue(booking *models.Booking) {
+	deadline := booking.StartTime
+	if booking.PaymentDueAt != nil {
+		deadline = *booking.PaymentDueAt
+	}
+
+	if _, err := notifications.Notify(booking.RenterID, notifications.Notification{
+		Kind:  notifications.KindBooking,
+		Title: fmt.Sprintf("Booking #%d accepted", booking.ID),
+		Body: fmt.Sprintf("The owner accepted your booking. Pay %d by %s to confirm it.",
+			amountDue(booking), deadline.Format(time.RFC1123)),
+	}); err != nil {
+		log.Printf("failed to notify user %d about payment due on booking %d: %v", booking.RenterID, booking.ID, err)
+	}
+}
+
+// chargeShortfall opens a top-up payment for whatever the deposit didn't
+// cover of a final price above what the renter paid, and tells the renter.
+func chargeShortfall(booking *models.Booking) {
+	payment, _, err := openPayment(booking, booking.RenterID, "")
+	if err != nil {
+		if !errors.Is(err, errNothingOwed) {
+			log.Printf("failed to open shortfall payment for booking %d: %v", booking.ID, err)
+		}
+		return
+	}
+
+	if _, err := notifications.Notify(booking.RenterID, notifications.Notification{
+		Kind:  notifications.KindBooking,
+		Title: fmt.Sprintf("Booking #%d has %d left to pay", booking.ID, payment.Amount),
+		Body:  fmt.Sprintf("The final price of booking #%d came to more than you paid. Pay the remaining %d from the booking's payments.", booking.ID, payment.Amount),
+	}); err != nil {
+		log.Printf("failed to notify user %d about shortfall on booking %d: %v", booking.RenterID, booking.ID, err)
+	}
+}
+
+func notifyPaymentOutcome(booking *models.Booking, payment *models.Payment, outcome string) {
+	var recipients []uint
+	var title, body string
+
+	switch outcome {
+	case paymentOutcomeConfirmed:
+		recipients = []uint{booking.RenterID, booking.OwnerID}
+		title = fmt.Sprintf("Booking #%d confirmed", booking.ID)
+		body = fmt.Sprintf("Payment of %d was received and booking #%d is confirmed.", payment.Amount, booking.ID)
+	case paymentOutcomeToppedUp:
+		recipients = []uint{booking.RenterID, booking.OwnerID}
+		title = fmt.Sprintf("Booking #%d change paid", booking.ID)
+		body = fmt.Sprintf("Payment of %d for the change to booking #%d was received.", payment.Amount, booking.ID)
+	case paymentOutcomeFailed:
+		recipients = []uint{booking.RenterID}
+		title = fmt.Sprintf("Payment failed for booking #%d", booking.ID)
+		body = fmt.Sprintf("Your payment of %d failed (%s). You can retry until the payment deadline.", payment.Amount, payment.FailureReason)
+	case paymentOutcomeRefunded:
+		recipients = []uint{booking.RenterID}
+		title = fmt.Sprintf("Payment refunded for booking #%d", booking.ID)
+		body = fmt.Sprintf("Your payment of %d arrived after booking #%d stopped waiting for it and has been refunded.", payment.Amount, booking.ID)
+	case paymentOutcomeMismatch:
+		recipients = []uint{booking.RenterID}
+		title = fmt.Sprintf("Payment refunded for booking #%d", booking.ID)
+		body = fmt.Sprintf("Your payment of %d didn't match the amount due for booking #%d and has been refunded. Please pay again.", payment.Amount, booking.ID)
+	default:
+		return
+	}
+
+	for _, userID := range recipients {
+		if _, err := notifications.Notify(userID, notifications.Notification{
+			Kind:  notifications.KindBooking,
+			Title: title,
+			Body:  body,
+		}); err != nil {
+			log.Printf("failed to notify user %d about payment on booking %d: %v", userID, booking.ID, err)
+		}
+	}
+}
@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"proj/config"
+	"proj/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	upiIDPattern       = regexp.MustCompile(`^[a-zA-Z0-9.\-_]{2,256}@[a-zA-Z]{2,64}$`)
+	bankAccountPattern = regexp.MustCompile(`^[0-9]{9,18}$`)
+
+	errPayoutNotPending = errors.New("payout is not pending")
+)
+
+type UpdatePayoutDetailsRequest struct {
+	UpiID       *string `json:"upi_id"`
+	BankAccount *string `json:"bank_account"`
+}
+
+type MarkPayoutPaidRequest struct {
+	Reference string `json:"reference" binding:"required"`
+}
+
+type MarkPayoutFailedRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type statementLine struct {
+	Date        time.Time `json:"date"`
+	Type        string    `json:"type"`
+	Description string    `json:"description"`
+	BookingID   *uint     `json:"booking_id"`
+	Debit       int64     `json:"debit"`
+	Credit      int64     `json:"credit"`
+	Balance     int64     `json:"balance"`
+}
+
+// UpdatePayoutDetails sets where the owner's payouts go. Payouts use the UPI
+// ID when one is set and fall back to the bank account.
+func UpdatePayoutDetails(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var req UpdatePayoutDetailsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.UpiID != nil {
+		if *req.UpiID != "" && !upiIDPattern.MatchString(*req.UpiID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UPI ID"})
+			return
+		}
+		updates["upi_id"] = *req.UpiID
+	}
+	if req.BankAccount != nil {
+		if *req.BankAccount != "" && !bankAccountPattern.MatchString(*req.BankAccount) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Bank account must be 9 to 18 digits"})
+			return
+		}
+		updates["bank_account"] = *req.BankAccount
+	}
+
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, uid).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := config.DB.Model(&user).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payout details"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Payout details updated",
+		"upi_id":       user.UpiID,
+		"bank_account": user.BankAccount,
+	})
+}
+
+func GetEarningsBalance(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	available, err := models.LedgerBalance(config.DB, models.LedgerAccountOwnerPayable, &uid, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch balance"})
+		return
+	}
+
+	inTransit, err := models.LedgerBalance(config.DB, models.LedgerAccountPayoutsInTransit, &uid, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch balance"})
+		return
+	}
+
+	var paidOut int64
+	if err := config.DB.Model(&models.Payout{}).
+		Where("owner_id = ? AND status = ?", uid, models.PayoutStatusPaid).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&paidOut).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch balance"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"available":      available,
+		"in_transit":     inTransit,
+		"paid_out":       paidOut,
+		"payout_minimum": config.GetPayoutMinimum(),
+	})
+}
+
+// GetEarningsStatement lists every movement on the owner's balance between
+// from and to (RFC3339, default the last 30 days) with a running balance.
+func GetEarningsStatement(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	if fromStr := c.Query("from"); fromStr != "" {
+		parsed, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from, expected RFC3339"})
+			return
+		}
+		from = parsed
+	}
+	if toStr := c.Query("to"); toStr != "" {
+		parsed, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to, expected RFC3339"})
+			return
+		}
+		to = parsed
+	}
+
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	var opening int64
+	if err := config.DB.Model(&models.LedgerPosting{}).
+		Where("account = ? AND user_id = ? AND created_at < ?", models.LedgerAccountOwnerPayable, uid, from).
+		Select("COALESCE(SUM(credit - debit), 0)").
+		Scan(&opening).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch statement"})
+		return
+	}
+
+	var postings []models.LedgerPosting
+	if err := config.DB.
+		Where("account = ? AND user_id = ? AND created_at >= ? AND created_at < ?", models.LedgerAccountOwnerPayable, uid, from, to).
+		Order("created_at ASC, id ASC").
+		Find(&postings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch statement"})
+		return
+	}
+
+	entryIDs := make([]uint, 0, len(postings))
+	for _, p := range postings {
+		entryIDs = append(entryIDs, p.EntryID)
+	}
+
+	var entries []models.LedgerEntry
+	if len(entryIDs) > 0 {
+		if err := config.DB.Where("id IN ?", entryIDs).Find(&entries).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch statement"})
+			return
+		}
+	}
+
+	entriesByID := make(map[uint]models.LedgerEntry, len(entries))
+	for _, e := range entries {
+		entriesByID[e.ID] = e
+	}
+
+	balance := opening
+	lines := make([]statementLine, 0, len(postings))
+	for _, p := range postings {
+		balance += p.Credit - p.Debit
+		entry := entriesByID[p.EntryID]
+		lines = append(lines, statementLine{
+			Date:        p.CreatedAt,
+			Type:        entry.Type,
+			Description: entry.Description,
+			BookingID:   p.BookingID,
+			Debit:       p.Debit,
+			Credit:      p.Credit,
+			Balance:     balance,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":            from,
+		"to":              to,
+		"opening_balance": opening,
+		"closing_balance": balance,
+		"lines":           lines,
+	})
+}
+
+func GetMyPayouts(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var payouts []models.Payout
+	if err := config.DB.Where("owner_id = ?", uid).Order("created_at DESC").Find(&payouts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payouts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":   len(payouts),
+		"payouts": payouts,
+	})
+}
+
+func AdminGetPayouts(c *gin.Context) {
+	query := config.DB.Model(&models.Payout{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if batchID := c.Query("batch_id"); batchID != "" {
+		query = query.Where("batch_id = ?", batchID)
+	}
+
+	var payouts []models.Payout
+	if err := query.Order("created_at DESC").Find(&payouts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payouts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":   len(payouts),
+		"payouts": payouts,
+	})
+}
+
+// MarkPayoutPaid records that a batched payout was transferred, with the
+// bank or UPI reference for reconciliation.
+func MarkPayoutPaid(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var req MarkPayoutPaidRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payout, err := settlePayout(c.Param("id"), func(tx *gorm.DB, payout *models.Payout) error {
+		now := time.Now()
+		payout.Status = models.PayoutStatusPaid
+		payout.Reference = req.Reference
+		payout.PaidAt = &now
+		if err := tx.Save(payout).Error; err != nil {
+			return err
+		}
+		return models.PostPayoutPaid(tx, payout)
+	})
+	if err != nil {
+		respondPayoutError(c, err)
+		return
+	}
+
+	recordAudit(c, &uid, models.AuditActionPayoutPaid, "payout", payout.ID, map[string]interface{}{
+		"amount":    payout.Amount,
+		"reference": payout.Reference,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payout marked as paid",
+		"payout":  payout,
+	})
+}
+
+// MarkPayoutFailed returns a payout that could not be transferred to the
+// owner's balance, so the next batch picks it up again.
+func MarkPayoutFailed(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var req MarkPayoutFailedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payout, err := settlePayout(c.Param("id"), func(tx *gorm.DB, payout *models.Payout) error {
+		payout.Status = models.PayoutStatusFailed
+		payout.FailureReason = req.Reason
+		if err := tx.Save(payout).Error; err != nil {
+			return err
+		}
+		return models.PostPayoutFailed(tx, payout)
+	})
+	if err != nil {
+		respondPayoutError(c, err)
+		return
+	}
+
+	recordAudit(c, &uid, models.AuditActionPayoutFailed, "payout", payout.ID, map[string]interface{}{
+		"amount": payout.Amount,
+		"reason": payout.FailureReason,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payout marked as failed",
+		"payout":  payout,
+	})
+}
+
+func settlePayout(payoutID string, settle func(tx *gorm.DB, payout *models.Payout) error) (*models.Payout, error) {
+	var payout models.Payout
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payout, payoutID).Error; err != nil {
+			return err
+		}
+		if payout.Status != models.PayoutStatusPending {
+			return errPayoutNotPending
+		}
+		return settle(tx, &payout)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &payout, nil
+}
+
+func respondPayoutError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payout not found"})
+	case errors.Is(err, errPayoutNotPending):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending payouts can be settled"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payout"})
+	}
+}
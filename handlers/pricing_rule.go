@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"proj/config"
+	"proj/models"
+	"proj/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PricingRuleRequest struct {
+	Name              string     `json:"name" binding:"required"`
+	Type              string     `json:"type" binding:"required"`
+	AdjustmentPercent int        `json:"adjustment_percent" binding:"required"`
+	StartsAt          *time.Time `json:"starts_at"`
+	EndsAt            *time.Time `json:"ends_at"`
+	StartHour         int        `json:"start_hour"`
+	EndHour           int        `json:"end_hour"`
+	LeadHours         int        `json:"lead_hours"`
+}
+
+type UpdatePricingRuleRequest struct {
+	Name              *string    `json:"name"`
+	AdjustmentPercent *int       `json:"adjustment_percent"`
+	StartsAt          *time.Time `json:"starts_at"`
+	EndsAt            *time.Time `json:"ends_at"`
+	StartHour         *int       `json:"start_hour"`
+	EndHour           *int       `json:"end_hour"`
+	LeadHours         *int       `json:"lead_hours"`
+	IsActive          *bool      `json:"is_active"`
+}
+
+func CreatePricingRule(c *gin.Context) {
+	vehicleID := c.Param("id")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var vehicle models.Vehicle
+	if err := config.DB.First(&vehicle, vehicleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+
+	if vehicle.OwnerID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't own this vehicle"})
+		return
+	}
+
+	var req PricingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := models.PricingRule{
+		VehicleID:         vehicle.ID,
+		Name:              req.Name,
+		Type:              req.Type,
+		AdjustmentPercent: req.AdjustmentPercent,
+		StartsAt:          req.StartsAt,
+		EndsAt:            req.EndsAt,
+		StartHour:         req.StartHour,
+		EndHour:           req.EndHour,
+		LeadHours:         req.LeadHours,
+		IsActive:          true,
+	}
+
+	if err := utils.ValidatePricingRule(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create pricing rule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Pricing rule created successfully",
+		"pricing_rule": rule,
+	})
+}
+
+func GetPricingRules(c *gin.Context) {
+	vehicleID := c.Param("id")
+
+	var vehicle models.Vehicle
+	if err := config.DB.First(&vehicle, vehicleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+
+	var rules []models.PricingRule
+	if err := config.DB.Where("vehicle_id = ?", vehicle.ID).Order("created_at ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pricing rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"vehicle_id":    vehicle.ID,
+		"total":         len(rules),
+		"pricing_rules": rules,
+	})
+}
+
+func UpdatePricingRule(c *gin.Context) {
+	rule, ok := loadOwnedPricingRule(c)
+	if !ok {
+		return
+	}
+
+	var req UpdatePricingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Name != nil {
+		rule.Name = *req.Name
+	}
+	if req.AdjustmentPercent != nil {
+		rule.AdjustmentPercent = *req.AdjustmentPercent
+	}
+	if req.StartsAt != nil {
+		rule.StartsAt = req.StartsAt
+	}
+	if req.EndsAt != nil {
+		rule.EndsAt = req.EndsAt
+	}
+	if req.StartHour != nil {
+		rule.StartHour = *req.StartHour
+	}
+	if req.EndHour != nil {
+		rule.EndHour = *req.EndHour
+	}
+	if req.LeadHours != nil {
+		rule.LeadHours = *req.LeadHours
+	}
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+
+	if err := utils.ValidatePricingRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Omit("Vehicle").Save(rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update pricing rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Pricing rule updated successfully",
+		"pricing_rule": rule,
+	})
+}
+
+func DeletePricingRule(c *gin.Context) {
+	rule, ok := loadOwnedPricingRule(c)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Delete(rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete pricing rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pricing rule deleted successfully",
+	})
+}
+
+func loadOwnedPricingRule(c *gin.Context) (*models.PricingRule, bool) {
+	ruleID := c.Param("id")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return nil, false
+	}
+
+	var rule models.PricingRule
+	if err := config.DB.Preload("Vehicle").First(&rule, ruleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pricing rule not found"})
+		return nil, false
+	}
+
+	if rule.Vehicle.OwnerID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't own this vehicle"})
+		return nil, false
+	}
+
+	return &rule, true
+}
+
+func activePricingRules(db *gorm.DB, vehicleID uint) ([]models.PricingRule, error) {
+	var rules []models.PricingRule
+	err := db.Where("vehicle_id = ? AND is_active = ?", vehicleID, true).Order("id ASC").Find(&rules).Error
+	return rules, err
+}
@@ -0,0 +1,502 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"proj/config"
+	"proj/models"
+	"proj/notifications"
+	"proj/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errReferralCodeInvalid = errors.New("invalid referral code")
+
+type CreatePromoCodeRequest struct {
+	Code             string     `json:"code" binding:"required"`
+	Description      string     `json:"description"`
+	DiscountType     string     `json:"discount_type" binding:"required"`
+	DiscountValue    int64      `json:"discount_value" binding:"required"`
+	MaxDiscount      int64      `json:"max_discount"`
+	MinBookingAmount int64      `json:"min_booking_amount"`
+	UsageLimit       int        `json:"usage_limit"`
+	PerUserLimit     *int       `json:"per_user_limit"`
+	FirstRideOnly    bool       `json:"first_ride_only"`
+	Department       string     `json:"department"`
+	StartsAt         *time.Time `json:"starts_at"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+}
+
+type UpdatePromoCodeRequest struct {
+	Description      *string    `json:"description"`
+	MaxDiscount      *int64     `json:"max_discount"`
+	MinBookingAmount *int64     `json:"min_booking_amount"`
+	UsageLimit       *int       `json:"usage_limit"`
+	PerUserLimit     *int       `json:"per_user_limit"`
+	FirstRideOnly    *bool      `json:"first_ride_only"`
+	Department       *string    `json:"department"`
+	StartsAt         *time.Time `json:"starts_at"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	IsActive         *bool      `json:"is_active"`
+}
+
+func CreatePromoCode(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var req CreatePromoCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	perUserLimit := 1
+	if req.PerUserLimit != nil {
+		perUserLimit = *req.PerUserLimit
+	}
+
+	promo := models.PromoCode{
+		Code:             utils.NormalizePromoCode(req.Code),
+		Description:      req.Description,
+		DiscountType:     req.DiscountType,
+		DiscountValue:    req.DiscountValue,
+		MaxDiscount:      req.MaxDiscount,
+		MinBookingAmount: req.MinBookingAmount,
+		UsageLimit:       req.UsageLimit,
+		PerUserLimit:     perUserLimit,
+		FirstRideOnly:    req.FirstRideOnly,
+		Department:       req.Department,
+		StartsAt:         req.StartsAt,
+		ExpiresAt:        req.ExpiresAt,
+		IsActive:         true,
+		CreatedByID:      uid,
+	}
+
+	if err := utils.ValidatePromoCode(&promo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Create(&promo).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Promo code already exists"})
+		return
+	}
+
+	// Per-user limit 0 means unlimited, which the column default would
+	// otherwise turn back into 1.
+	if perUserLimit == 0 {
+		config.DB.Model(&promo).Update("per_user_limit", 0)
+	}
+
+	recordAudit(c, &uid, models.AuditActionPromoCreated, "promo_code", promo.ID, map[string]interface{}{
+		"code":           promo.Code,
+		"discount_type":  promo.DiscountType,
+		"discount_value": promo.DiscountValue,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Promo code created successfully",
+		"promo_code": promo,
+	})
+}
+
+func GetPromoCodes(c *gin.Context) {
+	query := config.DB.Model(&models.PromoCode{})
+
+	if active := c.Query("active"); active != "" {
+		query = query.Where("is_active = ?", active == "true")
+	}
+
+	var promos []models.PromoCode
+	if err := query.Order("created_at DESC").Find(&promos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch promo codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":       len(promos),
+		"promo_codes": promos,
+	})
+}
+
+func UpdatePromoCode(c *gin.Context) {
+	promoID := c.Param("id")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var promo models.PromoCode
+	if err := config.DB.First(&promo, promoID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promo code not found"})
+		return
+	}
+
+	var req UpdatePromoCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Description != nil {
+		promo.Description = *req.Description
+		updates["description"] = *req.Description
+	}
+	if req.MaxDiscount != nil {
+		promo.MaxDiscount = *req.MaxDiscount
+		updates["max_discount"] = *req.MaxDiscount
+	}
+	if req.MinBookingAmount != nil {
+		promo.MinBookingAmount = *req.MinBookingAmount
+		updates["min_booking_amount"] = *req.MinBookingAmount
+	}
+	if req.UsageLimit != nil {
+		promo.UsageLimit = *req.UsageLimit
+		updates["usage_limit"] = *req.UsageLimit
+	}
+	if req.PerUserLimit != nil {
+		promo.PerUserLimit = *req.PerUserLimit
+		updates["per_user_limit"] = *req.PerUserLimit
+	}
+	if req.FirstRideOnly != nil {
+		promo.FirstRideOnly = *req.FirstRideOnly
+		updates["first_ride_only"] = *req.FirstRideOnly
+	}
+	if req.Department != nil {
+		promo.Department = *req.Department
+		updates["department"] = *req.Department
+	}
+	if req.StartsAt != nil {
+		promo.StartsAt = req.StartsAt
+		updates["starts_at"] = req.StartsAt
+	}
+	if req.ExpiresAt != nil {
+		promo.ExpiresAt = req.ExpiresAt
+		updates["expires_at"] = req.ExpiresAt
+	}
+	if req.IsActive != nil {
+		promo.IsActive = *req.IsActive
+		updates["is_active"] = *req.IsActive
+	}
+
+	if err := utils.ValidatePromoCode(&promo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(updates) > 0 {
+		if err := config.DB.Model(&promo).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update promo code"})
+			return
+		}
+		recordAudit(c, &uid, models.AuditActionPromoUpdated, "promo_code", promo.ID, updates)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Promo code updated successfully",
+		"promo_code": promo,
+	})
+}
+
+func GetPromoRedemptions(c *gin.Context) {
+	promoID := c.Param("id")
+
+	var promo models.PromoCode
+	if err := config.DB.First(&promo, promoID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promo code not found"})
+		return
+	}
+
+	var redemptions []models.PromoRedemption
+	if err := config.DB.Where("promo_code_id = ?", promo.ID).
+		Order("created_at DESC").
+		Find(&redemptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch redemptions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"promo_code":  promo,
+		"total":       len(redemptions),
+		"redemptions": redemptions,
+	})
+}
+
+func GetReferral(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, uid).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Accounts created before referrals existed get their code on first use.
+	if user.ReferralCode == nil {
+		code, err := utils.GenerateReferralCode()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate referral code"})
+			return
+		}
+		if err := config.DB.Model(&user).Update("referral_code", code).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate referral code"})
+			return
+		}
+		user.ReferralCode = &code
+	}
+
+	var referrals int64
+	config.DB.Model(&models.User{}).Where("referred_by_id = ?", user.ID).Count(&referrals)
+
+	c.JSON(http.StatusOK, gin.H{
+		"referral_code":   user.ReferralCode,
+		"referrals":       referrals,
+		"credit_balance":  user.CreditBalance,
+		"signup_credit":   config.GetReferralSignupCredit(),
+		"referral_reward": config.GetReferralRewardCredit(),
+	})
+}
+
+func GetCreditHistory(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	var transactions []models.CreditTransaction
+	if err := config.DB.Where("user_id = ?", uid).
+		Order("created_at DESC").
+		Find(&transactions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch credit history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":        len(transactions),
+		"transactions": transactions,
+	})
+}
+
+// applyBookingPromo locks the promo code, checks the renter may use it and
+// records the discount on the booking. The redemption itself is written by
+// redeemBookingDiscounts once the booking has an ID.
+func applyBookingPromo(tx *gorm.DB, booking *models.Booking, subtotal int64, code string) error {
+	var promo models.PromoCode
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ?", utils.NormalizePromoCode(code)).
+		First(&promo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &utils.PromoIneligibleError{Reason: "this code does not exist"}
+		}
+		return err
+	}
+
+	var renter models.User
+	if err := tx.First(&renter, booking.RenterID).Error; err != nil {
+		return err
+	}
+
+	// Any booking that is live or went ahead counts as a ride, so a renter
+	// can't stack first-ride codes on several bookings before finishing one.
+	var priorBookings int64
+	if err := tx.Model(&models.Booking{}).
+		Where("renter_id = ? AND status NOT IN ?", booking.RenterID, []string{models.BookingStatusCancelled, models.BookingStatusExpired}).
+		Count(&priorBookings).Error; err != nil {
+		return err
+	}
+
+	var userRedemptions int64
+	if err := tx.Model(&models.PromoRedemption{}).
+		Where("promo_code_id = ? AND user_id = ? AND status = ?", promo.ID, booking.RenterID, models.PromoRedemptionApplied).
+		Count(&userRedemptions).Error; err != nil {
+		return err
+	}
+
+	if err := utils.CheckPromoEligibility(&promo, &renter, subtotal, priorBookings, userRedemptions, time.Now()); err != nil {
+		return err
+	}
+
+	booking.PromoCodeID = &promo.ID
+	booking.PromoCode = promo.Code
+	booking.PromoDiscount = utils.PromoDiscount(&promo, subtotal)
+	return nil
+}
+
+// applyBookingCredits spends as much of the renter's credit balance as the
+// remaining price allows.
+func applyBookingCredits(tx *gorm.DB, booking *models.Booking, remaining int64) error {
+	var renter models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&renter, booking.RenterID).Error; err != nil {
+		return err
+	}
+
+	credits := renter.CreditBalance
+	if credits > remaining {
+		credits = remaining
+	}
+	if credits > 0 {
+		booking.CreditsApplied = credits
+	}
+	return nil
+}
+
+func redeemBookingDiscounts(tx *gorm.DB, booking *models.Booking) error {
+	if booking.PromoCodeID != nil {
+		if err := tx.Create(&models.PromoRedemption{
+			PromoCodeID:    *booking.PromoCodeID,
+			UserID:         booking.RenterID,
+			BookingID:      booking.ID,
+			Code:           booking.PromoCode,
+			DiscountAmount: booking.PromoDiscount,
+			Status:         models.PromoRedemptionApplied,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.PromoCode{}).
+			Where("id = ?", *booking.PromoCodeID).
+			Update("used_count", gorm.Expr("used_count + 1")).Error; err != nil {
+			return err
+		}
+	}
+
+	if booking.CreditsApplied > 0 {
+		return models.AdjustCredit(tx, &models.CreditTransaction{
+			UserID:    booking.RenterID,
+			Type:      models.CreditTypeBookingSpend,
+			Amount:    -booking.CreditsApplied,
+			BookingID: &booking.ID,
+			Note:      fmt.Sprintf("applied to booking #%d", booking.ID),
+		})
+	}
+
+	return nil
+}
+
+// applyReferralCode links a new user to their referrer and grants the signup
+// credit. It runs in the registration transaction.
+func applyReferralCode(tx *gorm.DB, user *models.User, code string) error {
+	var referrer models.User
+	if err := tx.Where("referral_code = ?", utils.NormalizePromoCode(code)).First(&referrer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errReferralCodeInvalid
+		}
+		return err
+	}
+
+	if err := tx.Model(user).Update("referred_by_id", referrer.ID).Error; err != nil {
+		return err
+	}
+	user.ReferredByID = &referrer.ID
+
+	credit := config.GetReferralSignupCredit()
+	if credit == 0 {
+		return nil
+	}
+
+	return models.AdjustCredit(tx, &models.CreditTransaction{
+		UserID:         user.ID,
+		Type:           models.CreditTypeReferralSignup,
+		Amount:         credit,
+		ReferredUserID: &user.ID,
+		Note:           "signed up with a referral code",
+	})
+}
+
+// grantReferralReward credits the referrer once the referred renter has
+// completed a ride. The reward is paid at most once per referred user.
+func grantReferralReward(renterID uint) {
+	var renter models.User
+	if err := config.DB.First(&renter, renterID).Error; err != nil || renter.ReferredByID == nil {
+		return
+	}
+
+	credit := config.GetReferralRewardCredit()
+	if credit == 0 {
+		return
+	}
+
+	granted := false
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Locking the referrer serializes concurrent completions, so the
+		// existence check below can't race.
+		var referrer models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&referrer, *renter.ReferredByID).Error; err != nil {
+			return err
+		}
+
+		var existing int64
+		if err := tx.Model(&models.CreditTransaction{}).
+			Where("user_id = ? AND type = ? AND referred_user_id = ?", referrer.ID, models.CreditTypeReferralReward, renter.ID).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+
+		granted = true
+		return models.AdjustCredit(tx, &models.CreditTransaction{
+			UserID:         referrer.ID,
+			Type:           models.CreditTypeReferralReward,
+			Amount:         credit,
+			ReferredUserID: &renter.ID,
+			Note:           fmt.Sprintf("%s completed their first ride", renter.Name),
+		})
+	})
+	if err != nil {
+		log.Printf("failed to grant referral reward for user %d: %v", renter.ID, err)
+		return
+	}
+
+	if granted {
+		if _, err := notifications.Notify(*renter.ReferredByID, notifications.Notification{
+			Kind:  notifications.KindBooking,
+			Title: "Referral reward",
+			Body:  fmt.Sprintf("%s completed their first ride. %d credits have been added to your account.", renter.Name, credit),
+		}); err != nil {
+			log.Printf("failed to notify user %d about referral reward: %v", *renter.ReferredByID, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"proj/config"
+	"proj/models"
+	"proj/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+var quotedPricingModels = []string{
+	models.PricingModelDistance,
+	models.PricingModelTime,
+	models.PricingModelHybrid,
+}
+
+// GetVehicleQuote prices a prospective trip under every pricing model so
+// renters can compare them before booking. The daily model is only quoted
+// for vehicles with a day rate.
+func GetVehicleQuote(c *gin.Context) {
+	vehicleID := c.Param("id")
+	startTimeStr := c.Query("start_time")
+	endTimeStr := c.Query("end_time")
+
+	if startTimeStr == "" || endTimeStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_time and end_time are required"})
+		return
+	}
+
+	startTime, err := time.Parse(time.RFC3339, startTimeStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_time format. Use RFC3339 (e.g., 2026-01-10T09:00:00Z)"})
+		return
+	}
+
+	endTime, err := time.Parse(time.RFC3339, endTimeStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_time format. Use RFC3339 (e.g., 2026-01-10T18:00:00Z)"})
+		return
+	}
+
+	if !startTime.Before(endTime) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_time must be before end_time"})
+		return
+	}
+
+	var distanceKm float64
+	if value := c.Query("estimated_distance_km"); value != "" {
+		distanceKm, err = strconv.ParseFloat(value, 64)
+		if err != nil || distanceKm < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "estimated_distance_km must be a non-negative number"})
+			return
+		}
+	}
+
+	var vehicle models.Vehicle
+	if err := config.DB.Where("is_active = ?", true).First(&vehicle, vehicleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+
+	pricingRules, err := activePricingRules(config.DB, vehicle.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load pricing rules"})
+		return
+	}
+
+	durationHours := int(math.Ceil(endTime.Sub(startTime).Hours()))
+	now := time.Now()
+
+	pricingModels := quotedPricingModels
+	if vehicle.PricePerDay > 0 {
+		pricingModels = append(pricingModels[:len(pricingModels):len(pricingModels)], models.PricingModelDaily)
+	}
+
+	quotes := make(map[string]models.PriceQuote, len(pricingModels))
+	for _, pricingModel := range pricingModels {
+		quote := utils.QuoteEstimate(&vehicle, distanceKm, durationHours, pricingModel)
+		utils.ApplyPricingRules(&quote, pricingRules, startTime, endTime, now)
+		quotes[pricingModel] = quote
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"vehicle_id":            vehicle.ID,
+		"start_time":            startTime,
+		"end_time":              endTime,
+		"duration_hours":        durationHours,
+		"estimated_distance_km": distanceKm,
+		"security_deposit":      vehicle.PricePerDay,
+		"quotes":                quotes,
+	})
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"proj/auth"
 	"proj/config"
@@ -9,8 +11,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+var errUserExists = errors.New("user already exists")
+
 type RegisterRequest struct {
 	Name       string `json:"name" binding:"required"`
 	Email      string `json:"email" binding:"required,email"`
@@ -21,6 +26,7 @@ type RegisterRequest struct {
 	Department string `json:"department" binding:"required"`
 	Age        int    `json:"age" binding:"required,min=18"`
 	Gender     string `json:"gender"`
+	ReferralCode string `json:"referral_code"`
 }
 
 type LoginRequest struct {
@@ -53,33 +59,63 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	referralCode, err := utils.GenerateReferralCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate referral code"})
+		return
+	}
+
 	user := models.User{
-		Name:       req.Name,
-		Email:      req.Email,
-		Password:   string(hashedPassword),
-		Phone:      encryptedPhone,
-		StudentID:  encryptedStudentID,
-		Course:     req.Course,
-		Department: req.Department,
-		Age:        req.Age,
-		Gender:     req.Gender,
-		Role:       "user",
+		Name:         req.Name,
+		Email:        req.Email,
+		Password:     string(hashedPassword),
+		Phone:        encryptedPhone,
+		StudentID:    encryptedStudentID,
+		Course:       req.Course,
+		Department:   req.Department,
+		Age:          req.Age,
+		Gender:       req.Gender,
+		Role:         auth.RoleRenter,
+		ReferralCode: &referralCode,
 	}
 
-	if err := config.DB.Create(&user).Error; err != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return errUserExists
+		}
+
+		if req.ReferralCode == "" {
+			return nil
+		}
+		return applyReferralCode(tx, &user, req.ReferralCode)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errUserExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+		case errors.Is(err, errReferralCodeInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid referral code"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
+		}
 		return
 	}
 
-	token, err := auth.GenerateToken(user.ID, user.Email, user.Role)
+	if err := sendVerificationEmail(&user); err != nil {
+		log.Printf("failed to send verification email to user %d: %v", user.ID, err)
+	}
+
+	tokens, err := auth.StartSession(&user, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "User registered successfully",
-		"token":   token,
+		"message":       "User registered successfully",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 		"user": gin.H{
 			"id":    user.ID,
 			"name":  user.Name,
@@ -107,15 +143,24 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	token, err := auth.GenerateToken(user.ID, user.Email, user.Role)
+	// Refreshing is refused for deactivated accounts, so don't hand them a
+	// new session here either.
+	if !user.IsActive {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is deactivated"})
+		return
+	}
+
+	tokens, err := auth.StartSession(&user, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"token":   token,
+		"message":       "Login successful",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 		"user": gin.H{
 			"id":    user.ID,
 			"name":  user.Name,
@@ -125,6 +170,83 @@ func Login(c *gin.Context) {
 	})
 }
 
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+func RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := auth.RefreshSession(req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrRefreshTokenReused):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has already been used. Session revoked, please log in again"})
+		case errors.Is(err, auth.ErrSessionRevoked), errors.Is(err, auth.ErrInvalidRefreshToken):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	})
+}
+
+func Logout(c *gin.Context) {
+	sessionID, exists := c.Get("session_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sid, ok := sessionID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session_id in context"})
+		return
+	}
+
+	if err := auth.RevokeSession(sid, auth.RevokeReasonLogout); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logged out successfully",
+	})
+}
+
+func LogoutAll(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user_id in context"})
+		return
+	}
+
+	if err := auth.RevokeAllSessions(uid, auth.RevokeReasonLogoutAll); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logged out of all sessions",
+	})
+}
+
 func GetUsers(c *gin.Context) {
 	var users []models.User
 
@@ -146,9 +268,57 @@ func GetProfile(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"id":    user.ID,
-		"name":  user.Name,
-		"email": user.Email,
-		"role":  user.Role,
+		"id":             user.ID,
+		"name":           user.Name,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"role":           user.Role,
+	})
+}
+
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+func UpdateUserRole(c *gin.Context) {
+	targetID := c.Param("id")
+
+	var req UpdateUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Role == auth.RoleUser || !auth.IsValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, targetID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := config.DB.Model(&user).Update("role", req.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	// Access tokens carry the role, so sign the user out everywhere to stop
+	// the old role from being used until those tokens expire.
+	if err := auth.RevokeAllSessions(user.ID, auth.RevokeReasonRoleChange); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Role updated but failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Role updated successfully",
+		"user": gin.H{
+			"id":    user.ID,
+			"name":  user.Name,
+			"email": user.Email,
+			"role":  user.Role,
+		},
 	})
 }
@@ -4,51 +4,78 @@ import (
 	"net/http"
 	"strconv"
 
+	"proj/auth"
 	"proj/config"
 	"proj/models"
+	"proj/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 type CreateVehicleRequest struct {
-	VehicleType     string  `json:"vehicle_type" binding:"required"`
-	Brand           string  `json:"brand" binding:"required"`
-	VehicleModel    string  `json:"vehicle_model" binding:"required"`
-	Year            int     `json:"year" binding:"required"`
-	Color           string  `json:"color"`
-	VehicleNumber   string  `json:"vehicle_number" binding:"required"`
-	PricePerKm      int64   `json:"price_per_km"`
-	PricePerHour    int64   `json:"price_per_hour"`
-	PricePerDay     int64   `json:"price_per_day"`
-	BasePrice       int64   `json:"base_price"`
-	MinRentalHours  int     `json:"min_rental_hours"`
-	MaxRentalDays   int     `json:"max_rental_days"`
-	HasHelmet       bool    `json:"has_helmet"`
-	FuelType        string  `json:"fuel_type"`
-	Transmission    string  `json:"transmission"`
-	Mileage         float64 `json:"mileage"`
-	SeatingCapacity int     `json:"seating_capacity"`
-	Location        string  `json:"location" binding:"required"`
-	Latitude        float64 `json:"latitude"`
-	Longitude       float64 `json:"longitude"`
-	Description     string  `json:"description"`
-	Rules           string  `json:"rules"`
+	VehicleType           string  `json:"vehicle_type" binding:"required"`
+	Brand                 string  `json:"brand" binding:"required"`
+	VehicleModel          string  `json:"vehicle_model" binding:"required"`
+	Year                  int     `json:"year" binding:"required"`
+	Color                 string  `json:"color"`
+	VehicleNumber         string  `json:"vehicle_number" binding:"required"`
+	PricePerKm            int64   `json:"price_per_km"`
+	PricePerHour          int64   `json:"price_per_hour"`
+	PricePerDay           int64   `json:"price_per_day"`
+	BasePrice             int64   `json:"base_price"`
+	WeeklyDiscountPercent int     `json:"weekly_discount_percent"`
+	MinRentalHours        *int    `json:"min_rental_hours"`
+	MaxRentalDays         *int    `json:"max_rental_days"`
+	CancellationPolicy    string  `json:"cancellation_policy"`
+	LateGraceMinutes      int     `json:"late_grace_minutes"`
+	LateFeePerHour        int64   `json:"late_fee_per_hour"`
+	BufferMinutes         *int    `json:"buffer_minutes"`
+	MinNoticeHours        *int    `json:"min_notice_hours"`
+	MaxAdvanceDays        *int    `json:"max_advance_days"`
+	HasHelmet             bool    `json:"has_helmet"`
+	RequireHelmet         bool    `json:"require_helmet"`
+	AllowedRenterYears    string  `json:"allowed_renter_years"`
+	GenderRestriction     string  `json:"gender_restriction"`
+	FuelType              string  `json:"fuel_type"`
+	Transmission          string  `json:"transmission"`
+	Mileage               float64 `json:"mileage"`
+	TankCapacityLiters    float64 `json:"tank_capacity_liters"`
+	FuelPolicy            string  `json:"fuel_policy"`
+	SeatingCapacity       int     `json:"seating_capacity"`
+	Location              string  `json:"location" binding:"required"`
+	Latitude              float64 `json:"latitude"`
+	Longitude             float64 `json:"longitude"`
+	Description           string  `json:"description"`
+	Rules                 string  `json:"rules"`
 }
 
 type UpdateVehicleRequest struct {
-	PricePerKm      *int64   `json:"price_per_km"`
-	PricePerHour    *int64   `json:"price_per_hour"`
-	PricePerDay     *int64   `json:"price_per_day"`
-	BasePrice       *int64   `json:"base_price"`
-	MinRentalHours  *int     `json:"min_rental_hours"`
-	MaxRentalDays   *int     `json:"max_rental_days"`
-	HasHelmet       *bool    `json:"has_helmet"`
-	Location        *string  `json:"location"`
-	Latitude        *float64 `json:"latitude"`
-	Longitude       *float64 `json:"longitude"`
-	Description     *string  `json:"description"`
-	Rules           *string  `json:"rules"`
-	IsAvailable     *bool    `json:"is_available"`
+	PricePerKm               *int64   `json:"price_per_km"`
+	PricePerHour             *int64   `json:"price_per_hour"`
+	PricePerDay              *int64   `json:"price_per_day"`
+	BasePrice                *int64   `json:"base_price"`
+	WeeklyDiscountPercent    *int     `json:"weekly_discount_percent"`
+	MinRentalHours           *int     `json:"min_rental_hours"`
+	MaxRentalDays            *int     `json:"max_rental_days"`
+	CancellationPolicy       *string  `json:"cancellation_policy"`
+	RequireAmendmentApproval *bool    `json:"require_amendment_approval"`
+	LateGraceMinutes         *int     `json:"late_grace_minutes"`
+	LateFeePerHour           *int64   `json:"late_fee_per_hour"`
+	BufferMinutes            *int     `json:"buffer_minutes"`
+	MinNoticeHours           *int     `json:"min_notice_hours"`
+	MaxAdvanceDays           *int     `json:"max_advance_days"`
+	TankCapacityLiters       *float64 `json:"tank_capacity_liters"`
+	FuelPolicy               *string  `json:"fuel_policy"`
+	HasHelmet                *bool    `json:"has_helmet"`
+	RequireHelmet            *bool    `json:"require_helmet"`
+	AllowedRenterYears       *string  `json:"allowed_renter_years"`
+	GenderRestriction        *string  `json:"gender_restriction"`
+	Location                 *string  `json:"location"`
+	Latitude                 *float64 `json:"latitude"`
+	Longitude                *float64 `json:"longitude"`
+	Description              *string  `json:"description"`
+	Rules                    *string  `json:"rules"`
+	IsAvailable              *bool    `json:"is_available"`
 }
 
 func CreateVehicle(c *gin.Context) {
@@ -70,6 +97,67 @@ func CreateVehicle(c *gin.Context) {
 		return
 	}
 
+	cancellationPolicy := req.CancellationPolicy
+	if cancellationPolicy == "" {
+		cancellationPolicy = models.CancellationPolicyModerate
+	}
+
+	if !utils.IsValidCancellationPolicy(cancellationPolicy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cancellation_policy must be flexible, moderate or strict"})
+		return
+	}
+
+	if req.LateGraceMinutes < 0 || req.LateFeePerHour < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "late_grace_minutes and late_fee_per_hour cannot be negative"})
+		return
+	}
+
+	fuelPolicy := req.FuelPolicy
+	if fuelPolicy == "" {
+		fuelPolicy = models.FuelPolicySameLevel
+	}
+
+	if !utils.IsValidFuelPolicy(fuelPolicy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fuel_policy must be same_level or return_full"})
+		return
+	}
+
+	minRentalHours := intOrDefault(req.MinRentalHours, models.DefaultMinRentalHours)
+	maxRentalDays := intOrDefault(req.MaxRentalDays, models.DefaultMaxRentalDays)
+	if minRentalHours < 0 || maxRentalDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_rental_hours and max_rental_days cannot be negative"})
+		return
+	}
+
+	allowedRenterYears, err := utils.NormalizeRenterYears(req.AllowedRenterYears)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !utils.IsValidGenderRestriction(req.GenderRestriction) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "gender_restriction must be male, female or empty"})
+		return
+	}
+
+	bufferMinutes := intOrDefault(req.BufferMinutes, models.DefaultBufferMinutes)
+	minNoticeHours := intOrDefault(req.MinNoticeHours, models.DefaultMinNoticeHours)
+	maxAdvanceDays := intOrDefault(req.MaxAdvanceDays, models.DefaultMaxAdvanceDays)
+	if bufferMinutes < 0 || minNoticeHours < 0 || maxAdvanceDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "buffer_minutes, min_notice_hours and max_advance_days cannot be negative"})
+		return
+	}
+
+	if req.WeeklyDiscountPercent < 0 || req.WeeklyDiscountPercent > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "weekly_discount_percent must be between 0 and 100"})
+		return
+	}
+
+	if req.TankCapacityLiters < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tank_capacity_liters cannot be negative"})
+		return
+	}
+
 	var user models.User
 	if err := config.DB.First(&user, uid).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
@@ -99,19 +187,32 @@ func CreateVehicle(c *gin.Context) {
 		PricePerHour:    req.PricePerHour,
 		PricePerDay:     req.PricePerDay,
 		BasePrice:       req.BasePrice,
-		MinRentalHours:  req.MinRentalHours,
-		HasHelmet:       req.HasHelmet,
-		FuelType:        req.FuelType,
-		Transmission:    req.Transmission,
-		Mileage:         req.Mileage,
-		SeatingCapacity: req.SeatingCapacity,
-		Location:        req.Location,
-		Latitude:        req.Latitude,
-		Longitude:       req.Longitude,
-		Description:     req.Description,
-		Rules:           req.Rules,
-		IsAvailable:     true,
-		IsActive:        true,
+		WeeklyDiscountPercent: req.WeeklyDiscountPercent,
+		MinRentalHours:        minRentalHours,
+		MaxRentalDays:         maxRentalDays,
+		CancellationPolicy:    cancellationPolicy,
+		LateGraceMinutes:      req.LateGraceMinutes,
+		LateFeePerHour:        req.LateFeePerHour,
+		BufferMinutes:         bufferMinutes,
+		MinNoticeHours:        minNoticeHours,
+		MaxAdvanceDays:        maxAdvanceDays,
+		HasHelmet:             req.HasHelmet,
+		RequireHelmet:         req.RequireHelmet,
+		AllowedRenterYears:    allowedRenterYears,
+		GenderRestriction:     req.GenderRestriction,
+		FuelType:              req.FuelType,
+		Transmission:          req.Transmission,
+		Mileage:               req.Mileage,
+		TankCapacityLiters:    req.TankCapacityLiters,
+		FuelPolicy:            fuelPolicy,
+		SeatingCapacity:       req.SeatingCapacity,
+		Location:              req.Location,
+		Latitude:              req.Latitude,
+		Longitude:             req.Longitude,
+		Description:           req.Description,
+		Rules:                 req.Rules,
+		IsAvailable:           true,
+		IsActive:              true,
 	}
 
 	if err := config.DB.Create(&vehicle).Error; err != nil {
@@ -119,18 +220,37 @@ func CreateVehicle(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Model(&user).Updates(map[string]interface{}{
+	userUpdates := map[string]interface{}{
 		"is_owner":       true,
 		"total_vehicles": user.TotalVehicles + 1,
-	}).Error; err != nil {
+	}
+	promoted := auth.NormalizeRole(user.Role) == auth.RoleRenter
+	if promoted {
+		userUpdates["role"] = auth.RoleOwner
+	}
+
+	if err := config.DB.Model(&user).Updates(userUpdates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user stats"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	response := gin.H{
 		"message": "Vehicle listed successfully",
 		"vehicle": vehicle,
-	})
+	}
+
+	// The caller's access token still says renter, which can't manage the
+	// vehicle it just listed, so hand back one with the owner role.
+	if promoted {
+		token, err := auth.GenerateToken(uid, user.Email, auth.RoleOwner, c.GetUint("session_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Vehicle listed but failed to issue a new token"})
+			return
+		}
+		response["token"] = token
+	}
+
+	c.JSON(http.StatusCreated, response)
 }
 
 func GetVehicles(c *gin.Context) {
@@ -240,14 +360,106 @@ func UpdateVehicle(c *gin.Context) {
 		updates["base_price"] = *req.BasePrice
 	}
 	if req.MinRentalHours != nil {
+		if *req.MinRentalHours < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_rental_hours cannot be negative"})
+			return
+		}
 		updates["min_rental_hours"] = *req.MinRentalHours
 	}
 	if req.MaxRentalDays != nil {
+		if *req.MaxRentalDays < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_rental_days cannot be negative"})
+			return
+		}
 		updates["max_rental_days"] = *req.MaxRentalDays
 	}
+	if req.CancellationPolicy != nil {
+		if !utils.IsValidCancellationPolicy(*req.CancellationPolicy) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cancellation_policy must be flexible, moderate or strict"})
+			return
+		}
+		updates["cancellation_policy"] = *req.CancellationPolicy
+	}
+	if req.WeeklyDiscountPercent != nil {
+		if *req.WeeklyDiscountPercent < 0 || *req.WeeklyDiscountPercent > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "weekly_discount_percent must be between 0 and 100"})
+			return
+		}
+		updates["weekly_discount_percent"] = *req.WeeklyDiscountPercent
+	}
+	if req.RequireAmendmentApproval != nil {
+		updates["require_amendment_approval"] = *req.RequireAmendmentApproval
+	}
+	if req.LateGraceMinutes != nil {
+		if *req.LateGraceMinutes < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "late_grace_minutes cannot be negative"})
+			return
+		}
+		updates["late_grace_minutes"] = *req.LateGraceMinutes
+	}
+	if req.LateFeePerHour != nil {
+		if *req.LateFeePerHour < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "late_fee_per_hour cannot be negative"})
+			return
+		}
+		updates["late_fee_per_hour"] = *req.LateFeePerHour
+	}
+	if req.BufferMinutes != nil {
+		if *req.BufferMinutes < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "buffer_minutes cannot be negative"})
+			return
+		}
+		updates["buffer_minutes"] = *req.BufferMinutes
+	}
+	if req.MinNoticeHours != nil {
+		if *req.MinNoticeHours < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_notice_hours cannot be negative"})
+			return
+		}
+		updates["min_notice_hours"] = *req.MinNoticeHours
+	}
+	if req.MaxAdvanceDays != nil {
+		if *req.MaxAdvanceDays < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_advance_days cannot be negative"})
+			return
+		}
+		updates["max_advance_days"] = *req.MaxAdvanceDays
+	}
+	if req.TankCapacityLiters != nil {
+		if *req.TankCapacityLiters < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tank_capacity_liters cannot be negative"})
+			return
+		}
+		updates["tank_capacity_liters"] = *req.TankCapacityLiters
+	}
+	if req.FuelPolicy != nil {
+		if !utils.IsValidFuelPolicy(*req.FuelPolicy) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fuel_policy must be same_level or return_full"})
+			return
+		}
+		updates["fuel_policy"] = *req.FuelPolicy
+	}
 	if req.HasHelmet != nil {
 		updates["has_helmet"] = *req.HasHelmet
 	}
+	if req.RequireHelmet != nil {
+		updates["require_helmet"] = *req.RequireHelmet
+	}
+	if req.AllowedRenterYears != nil {
+		allowedRenterYears, err := utils.NormalizeRenterYears(*req.AllowedRenterYears)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updates["allowed_renter_years"] = allowedRenterYears
+	}
+	if req.GenderRestriction != nil {
+		if !utils.IsValidGenderRestriction(*req.GenderRestriction) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "gender_restriction must be male, female or empty"})
+			return
+		}
+		updates["gender_restriction"] = *req.GenderRestriction
+	}
 	if req.Location != nil {
 		updates["location"] = *req.Location
 	}
@@ -305,10 +517,7 @@ func DeleteVehicle(c *gin.Context) {
 
 	var activeBookings int64
 	config.DB.Model(&models.Booking{}).
-		Where("vehicle_id = ? AND status IN ?", vehicleID, []string{
-			models.BookingStatusConfirmed,
-			models.BookingStatusOngoing,
-		}).
+		Where("vehicle_id = ? AND status IN ?", vehicleID, models.BookingStatusesHoldingVehicle).
 		Count(&activeBookings)
 
 	if activeBookings > 0 {
@@ -357,3 +566,12 @@ func GetMyVehicles(c *gin.Context) {
 		"vehicles": vehicles,
 	})
 }
+
+// intOrDefault reads an optional request field, so that an explicit 0 is
+// kept rather than replaced by the default.
+func intOrDefault(value *int, fallback int) int {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
@@ -0,0 +1,269 @@
+package invoices
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strconv"
+	"time"
+
+	"proj/config"
+	"proj/models"
+)
+
+type lineView struct {
+	Description string
+	Quantity    string
+	UnitPrice   string
+	Amount      string
+}
+
+// invoiceView is an invoice with every value formatted for display, shared
+// by the HTML and PDF renderers so both show the same thing.
+type invoiceView struct {
+	PlatformName  string
+	PlatformGSTIN string
+	Number        string
+	IssuedAt      string
+	BookingID     uint
+	Void          string
+
+	RenterName  string
+	RenterEmail string
+	OwnerName   string
+	Vehicle     string
+	Period      string
+	Trip        string
+
+	Lines          []lineView
+	Total          string
+	AmountPaid     string
+	BalanceLabel   string
+	Balance        string
+	ShowBalance    bool
+	Commission     string
+	CommissionNote string
+	CGST           string
+	SGST           string
+	GSTPercentHalf string
+}
+
+func newView(inv *models.Invoice) invoiceView {
+	loc := config.GetTimeLocation()
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.In(loc).Format("02 Jan 2006 15:04")
+	}
+
+	v := invoiceView{
+		PlatformName:  inv.PlatformName,
+		PlatformGSTIN: inv.PlatformGSTIN,
+		Number:        inv.Number,
+		IssuedAt:      inv.IssuedAt.In(loc).Format("02 Jan 2006"),
+		BookingID:     inv.BookingID,
+		RenterName:    inv.RenterName,
+		RenterEmail:   inv.RenterEmail,
+		OwnerName:     inv.OwnerName,
+		Vehicle:       inv.Vehicle,
+		Period:        formatTime(inv.StartTime) + " to " + formatTime(inv.EndTime),
+		Trip:          formatTime(inv.PickupTime) + " to " + formatTime(inv.ReturnTime),
+		Total:         formatAmount(inv.Total),
+		AmountPaid:    formatAmount(inv.AmountPaid),
+		Commission:    formatAmount(inv.CommissionAmount),
+		CGST:          formatAmount(inv.CGST),
+		SGST:          formatAmount(inv.SGST),
+	}
+
+	if inv.VoidedAt != nil {
+		v.Void = fmt.Sprintf("VOID since %s: %s", inv.VoidedAt.In(loc).Format("02 Jan 2006"), inv.VoidReason)
+	}
+
+	if inv.GSTPercent%2 == 0 {
+		v.GSTPercentHalf = strconv.Itoa(inv.GSTPercent / 2)
+	} else {
+		v.GSTPercentHalf = strconv.FormatFloat(float64(inv.GSTPercent)/2, 'f', 1, 64)
+	}
+
+	v.CommissionNote = fmt.Sprintf("Platform commission (%d%%, paid by the owner) includes GST on a taxable value of %s",
+		inv.CommissionPercent, formatAmount(inv.CommissionTaxable))
+
+	switch {
+	case inv.BalanceDue > 0:
+		v.ShowBalance = true
+		v.BalanceLabel = "Balance due"
+		v.Balance = formatAmount(inv.BalanceDue)
+	case inv.BalanceDue < 0:
+		v.ShowBalance = true
+		v.BalanceLabel = "Refunded"
+		v.Balance = formatAmount(-inv.BalanceDue)
+	}
+
+	if inv.LineItems != nil {
+		for _, item := range inv.LineItems.LineItems {
+			line := lineView{Description: item.Description, Amount: formatAmount(item.Amount)}
+			if item.Quantity != 0 {
+				line.Quantity = strconv.FormatFloat(item.Quantity, 'f', -1, 64) + " " + item.Unit
+			}
+			if item.UnitPrice != 0 {
+				line.UnitPrice = formatAmount(item.UnitPrice)
+			}
+			v.Lines = append(v.Lines, line)
+		}
+	}
+
+	return v
+}
+
+// formatAmount formats whole rupees with Indian digit grouping, e.g.
+// "Rs. 1,23,456".
+func formatAmount(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	if len(digits) > 3 {
+		head, tail := digits[:len(digits)-3], digits[len(digits)-3:]
+		var grouped []byte
+		for i, d := range []byte(head) {
+			if i > 0 && (len(head)-i)%2 == 0 {
+				grouped = append(grouped, ',')
+			}
+			grouped = append(grouped, d)
+		}
+		digits = string(grouped) + "," + tail
+	}
+
+	return sign + "Rs. " + digits
+}
+
+var htmlTemplate = template.Must(template.New("invoice").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Number}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #222; max-width: 760px; margin: 40px auto; }
+h1 { font-size: 22px; margin-bottom: 4px; }
+table { width: 100%; border-collapse: collapse; margin: 16px 0; }
+th, td { text-align: left; padding: 6px 4px; border-bottom: 1px solid #ddd; }
+td.amount, th.amount { text-align: right; }
+.muted { color: #666; font-size: 13px; }
+.totals td { border: none; }
+</style>
+</head>
+<body>
+<h1>{{.PlatformName}}</h1>
+{{if .PlatformGSTIN}}<div class="muted">GSTIN: {{.PlatformGSTIN}}</div>{{end}}
+<h2>Tax Invoice {{.Number}}</h2>
+<div class="muted">Issued {{.IssuedAt}} &middot; Booking #{{.BookingID}}</div>
+{{if .Void}}<p><strong>{{.Void}}</strong></p>{{end}}
+
+<table>
+<tr><th>Billed to</th><td>{{.RenterName}}<br><span class="muted">{{.RenterEmail}}</span></td></tr>
+<tr><th>Vehicle owner</th><td>{{.OwnerName}}</td></tr>
+<tr><th>Vehicle</th><td>{{.Vehicle}}</td></tr>
+<tr><th>Booked</th><td>{{.Period}}</td></tr>
+<tr><th>Trip</th><td>{{.Trip}}</td></tr>
+</table>
+
+<table>
+<tr><th>Description</th><th>Quantity</th><th class="amount">Rate</th><th class="amount">Amount</th></tr>
+{{range .Lines}}<tr><td>{{.Description}}</td><td>{{.Quantity}}</td><td class="amount">{{.UnitPrice}}</td><td class="amount">{{.Amount}}</td></tr>
+{{end}}</table>
+
+<table class="totals">
+<tr><th>Total</th><td class="amount"><strong>{{.Total}}</strong></td></tr>
+<tr><th>Amount paid</th><td class="amount">{{.AmountPaid}}</td></tr>
+{{if .ShowBalance}}<tr><th>{{.BalanceLabel}}</th><td class="amount">{{.Balance}}</td></tr>{{end}}
+</table>
+
+<p class="muted">{{.CommissionNote}}: {{.Commission}} (CGST {{.GSTPercentHalf}}% {{.CGST}}, SGST {{.GSTPercentHalf}}% {{.SGST}}).</p>
+</body>
+</html>
+`))
+
+func RenderHTML(inv *models.Invoice) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, newView(inv)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func RenderPDF(inv *models.Invoice) ([]byte, error) {
+	v := newView(inv)
+	d := newPDFDocument()
+
+	d.text(pageMargin, 18, true, v.PlatformName)
+	d.advance(16)
+	if v.PlatformGSTIN != "" {
+		d.text(pageMargin, 9, false, "GSTIN: "+v.PlatformGSTIN)
+		d.advance(14)
+	}
+	d.advance(10)
+	d.text(pageMargin, 14, true, "Tax Invoice "+v.Number)
+	d.advance(14)
+	d.text(pageMargin, 9, false, fmt.Sprintf("Issued %s - Booking #%d", v.IssuedAt, v.BookingID))
+	d.advance(24)
+	if v.Void != "" {
+		d.text(pageMargin, 12, true, v.Void)
+		d.advance(24)
+	}
+
+	for _, row := range [][2]string{
+		{"Billed to", v.RenterName + " <" + v.RenterEmail + ">"},
+		{"Vehicle owner", v.OwnerName},
+		{"Vehicle", v.Vehicle},
+		{"Booked", v.Period},
+		{"Trip", v.Trip},
+	} {
+		d.text(pageMargin, 10, true, row[0])
+		d.text(pageMargin+110, 10, false, row[1])
+		d.advance(15)
+	}
+	d.advance(10)
+
+	d.text(pageMargin, 10, true, "Description")
+	d.text(300, 10, true, "Quantity")
+	d.text(390, 10, true, "Rate")
+	d.text(470, 10, true, "Amount")
+	d.advance(6)
+	d.rule()
+	d.advance(14)
+
+	for _, line := range v.Lines {
+		d.ensure(16)
+		d.text(pageMargin, 10, false, line.Description)
+		d.text(300, 10, false, line.Quantity)
+		d.text(390, 10, false, line.UnitPrice)
+		d.text(470, 10, false, line.Amount)
+		d.advance(16)
+	}
+
+	d.ensure(90)
+	d.rule()
+	d.advance(16)
+	d.text(390, 11, true, "Total")
+	d.text(470, 11, true, v.Total)
+	d.advance(16)
+	d.text(390, 10, false, "Amount paid")
+	d.text(470, 10, false, v.AmountPaid)
+	d.advance(16)
+	if v.ShowBalance {
+		d.text(390, 10, false, v.BalanceLabel)
+		d.text(470, 10, false, v.Balance)
+		d.advance(16)
+	}
+
+	d.advance(14)
+	d.text(pageMargin, 8, false, v.CommissionNote+":")
+	d.advance(11)
+	d.text(pageMargin, 8, false, fmt.Sprintf("%s (CGST %s%% %s, SGST %s%% %s)", v.Commission, v.GSTPercentHalf, v.CGST, v.GSTPercentHalf, v.SGST))
+
+	return d.bytes(), nil
+}
@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"proj/config"
+	"proj/models"
+	"proj/notifications"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const expiryBatchSize = 100
+
+// ExpirePendingBookings moves pending bookings to expired once the owner's
+// confirmation window has passed or the booking's start time has arrived,
+// and accepted bookings once their payment deadline passes unpaid.
+// Rows are claimed with FOR UPDATE SKIP LOCKED, so instances running the job
+// at the same time work on disjoint batches and each booking is expired and
+// notified exactly once.
+func ExpirePendingBookings() error {
+	for {
+		expired, err := expirePendingBatch()
+		if err != nil {
+			return err
+		}
+
+		for i := range expired {
+			notifyBookingExpired(&expired[i])
+		}
+
+		if len(expired) < expiryBatchSize {
+			return nil
+		}
+	}
+}
+
+func expirePendingBatch() ([]models.Booking, error) {
+	now := time.Now()
+	cutoff := now.Add(-config.GetBookingConfirmationWindow())
+
+	var expired []models.Booking
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var bookings []models.Booking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND (created_at <= ? OR start_time <= ?)) OR (status IN ? AND payment_due_at <= ?)",
+				models.BookingStatusPending, cutoff, now,
+				[]string{models.BookingStatusAwaitingPayment, models.BookingStatusPaymentFailed}, now).
+			Order("id ASC").
+			Limit(expiryBatchSize).
+			Find(&bookings).Error; err != nil {
+			return err
+		}
+
+		for i := range bookings {
+			reason := "owner did not confirm within the confirmation window"
+			if bookings[i].Status != models.BookingStatusPending {
+				reason = "renter did not pay before the payment deadline"
+			} else if !bookings[i].StartTime.After(now) {
+				reason = "start time passed before the owner confirmed"
+			}
+
+			if err := models.TransitionBooking(tx, &bookings[i], models.BookingStatusExpired, models.BookingActorSystem, nil, reason, nil); err != nil {
+				return err
+			}
+			if err := models.ReleaseBookingDiscounts(tx, &bookings[i]); err != nil {
+				return err
+			}
+			expired = append(expired, bookings[i])
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return expired, nil
+}
+
+func notifyBookingExpired(booking *models.Booking) {
+	for _, userID := range []uint{booking.RenterID, booking.OwnerID} {
+		if _, err := notifications.Notify(userID, notifications.Notification{
+			Kind:  notifications.KindBooking,
+			Title: fmt.Sprintf("Booking #%d expired", booking.ID),
+			Body: fmt.Sprintf("Booking #%d for %s to %s expired because it wasn't confirmed and paid in time.",
+				booking.ID, booking.StartTime.Format(time.RFC1123), booking.EndTime.Format(time.RFC1123)),
+		}); err != nil {
+			log.Printf("failed to notify user %d about expired booking %d: %v", userID, booking.ID, err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"proj/config"
+	"proj/models"
+	"proj/notifications"
+	"proj/payments"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const depositReleaseBatchSize = 100
+
+// ReleaseDeposits returns what is left of held deposits on completed
+// bookings once the claim window after return has passed. Disputed bookings
+// are left alone until an admin resolves them.
+func ReleaseDeposits() error {
+	for {
+		released, err := releaseDepositBatch()
+		if err != nil {
+			return err
+		}
+
+		for i := range released {
+			if _, err := payments.RefundBooking(config.DB, released[i].ID, "security deposit released"); err != nil {
+				log.Printf("failed to refund deposit for booking %d: %v", released[i].ID, err)
+			}
+			notifyDepositReleased(&released[i])
+		}
+
+		if len(released) < depositReleaseBatchSize {
+			return nil
+		}
+	}
+}
+
+func releaseDepositBatch() ([]models.Booking, error) {
+	now := time.Now()
+
+	var released []models.Booking
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var bookings []models.Booking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND deposit_status = ? AND deposit_release_after <= ?",
+				models.BookingStatusCompleted, models.DepositStatusHeld, now).
+			Order("id ASC").
+			Limit(depositReleaseBatchSize).
+			Find(&bookings).Error; err != nil {
+			return err
+		}
+
+		for i := range bookings {
+			if err := models.ReleaseDeposit(tx, &bookings[i], nil, models.BookingActorSystem, "claim window ended"); err != nil {
+				return err
+			}
+			released = append(released, bookings[i])
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return released, nil
+}
+
+func notifyDepositReleased(booking *models.Booking) {
+	if _, err := notifications.Notify(booking.RenterID, notifications.Notification{
+		Kind:  notifications.KindBooking,
+		Title: fmt.Sprintf("Deposit released for booking #%d", booking.ID),
+		Body: fmt.Sprintf("%d of your %d security deposit has been released (%d claimed by the owner).",
+			booking.DepositReleased, booking.DepositHeld, booking.DepositCaptured),
+	}); err != nil {
+		log.Printf("failed to notify user %d about deposit release on booking %d: %v", booking.RenterID, booking.ID, err)
+	}
+}
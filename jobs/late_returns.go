@@ -0,0 +1,114 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"proj/config"
+	"proj/models"
+	"proj/notifications"
+	"proj/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const lateReturnBatchSize = 100
+
+// NotifyLateReturns warns renters whose ongoing booking is about to end and
+// flags bookings that are past their end time plus grace period to both
+// parties. Each booking gets at most one warning and one overdue notice per
+// end time; the *_notified_at columns are set in the same transaction that
+// claims the rows.
+func NotifyLateReturns() error {
+	if err := processLateReturnBatches(claimEndingSoon, notifyEndingSoon); err != nil {
+		return err
+	}
+	return processLateReturnBatches(claimOverdue, notifyOverdue)
+}
+
+func processLateReturnBatches(claim func(now time.Time) ([]models.Booking, error), notify func(*models.Booking)) error {
+	for {
+		bookings, err := claim(time.Now())
+		if err != nil {
+			return err
+		}
+
+		for i := range bookings {
+			notify(&bookings[i])
+		}
+
+		if len(bookings) < lateReturnBatchSize {
+			return nil
+		}
+	}
+}
+
+func claimEndingSoon(now time.Time) ([]models.Booking, error) {
+	return claimLateReturnBatch("ending_soon_notified_at", now,
+		"end_time > ? AND end_time <= ?", now, now.Add(config.GetLateReturnWarningLead()))
+}
+
+func claimOverdue(now time.Time) ([]models.Booking, error) {
+	return claimLateReturnBatch("overdue_notified_at", now,
+		"end_time + late_grace_minutes * INTERVAL '1 minute' < ?", now)
+}
+
+func claimLateReturnBatch(notifiedColumn string, now time.Time, condition string, args ...interface{}) ([]models.Booking, error) {
+	var bookings []models.Booking
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND "+notifiedColumn+" IS NULL", models.BookingStatusOngoing).
+			Where(condition, args...).
+			Order("id ASC").
+			Limit(lateReturnBatchSize).
+			Find(&bookings).Error; err != nil {
+			return err
+		}
+
+		if len(bookings) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(bookings))
+		for i := range bookings {
+			ids[i] = bookings[i].ID
+		}
+
+		return tx.Model(&models.Booking{}).Where("id IN ?", ids).Update(notifiedColumn, now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bookings, nil
+}
+
+func notifyEndingSoon(booking *models.Booking) {
+	notifyLateReturnUser(booking.RenterID, booking, notifications.Notification{
+		Kind:  notifications.KindBooking,
+		Title: fmt.Sprintf("Booking #%d ends soon", booking.ID),
+		Body: fmt.Sprintf("Booking #%d ends at %s. Returns more than %d minutes late are charged %d per started hour.",
+			booking.ID, booking.EndTime.Format(time.RFC1123), booking.LateGraceMinutes, utils.LateFeeRate(booking)),
+	})
+}
+
+func notifyOverdue(booking *models.Booking) {
+	body := fmt.Sprintf("Booking #%d was due back at %s and has not been returned. Late fees of %d per started hour apply.",
+		booking.ID, booking.EndTime.Format(time.RFC1123), utils.LateFeeRate(booking))
+
+	for _, userID := range []uint{booking.RenterID, booking.OwnerID} {
+		notifyLateReturnUser(userID, booking, notifications.Notification{
+			Kind:  notifications.KindBooking,
+			Title: fmt.Sprintf("Booking #%d is overdue", booking.ID),
+			Body:  body,
+		})
+	}
+}
+
+func notifyLateReturnUser(userID uint, booking *models.Booking, n notifications.Notification) {
+	if _, err := notifications.Notify(userID, n); err != nil {
+		log.Printf("failed to notify user %d about late return on booking %d: %v", userID, booking.ID, err)
+	}
+}
@@ -0,0 +1,111 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"proj/config"
+	"proj/models"
+	"proj/notifications"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ownerBalance struct {
+	UserID  uint
+	Balance int64
+}
+
+// BatchPayouts sweeps every owner balance of at least PAYOUT_MINIMUM into a
+// pending payout to the owner's UPI ID, or bank account if no UPI ID is set.
+// Each owner is handled in its own transaction holding a SKIP LOCKED lock on
+// the owner's user row, so concurrent runs never pay the same balance twice.
+func BatchPayouts() error {
+	minimum := config.GetPayoutMinimum()
+	if minimum < 1 {
+		minimum = 1
+	}
+
+	var owners []ownerBalance
+	if err := config.DB.Model(&models.LedgerPosting{}).
+		Select("user_id, SUM(credit - debit) AS balance").
+		Where("account = ? AND user_id IS NOT NULL", models.LedgerAccountOwnerPayable).
+		Group("user_id").
+		Having("SUM(credit - debit) >= ?", minimum).
+		Scan(&owners).Error; err != nil {
+		return err
+	}
+
+	batchID := "batch_" + time.Now().UTC().Format("20060102T150405")
+	for _, owner := range owners {
+		payout, err := batchOwnerPayout(owner.UserID, batchID, minimum)
+		if err != nil {
+			log.Printf("failed to batch payout for owner %d: %v", owner.UserID, err)
+			continue
+		}
+		if payout != nil {
+			notifyPayoutBatched(payout)
+		}
+	}
+
+	return nil
+}
+
+func batchOwnerPayout(ownerID uint, batchID string, minimum int64) (*models.Payout, error) {
+	var payout *models.Payout
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var owner models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id = ?", ownerID).
+			Limit(1).
+			Find(&owner).Error
+		if err != nil || owner.ID == 0 {
+			return err
+		}
+
+		balance, err := models.LedgerBalance(tx, models.LedgerAccountOwnerPayable, &ownerID, nil)
+		if err != nil || balance < minimum {
+			return err
+		}
+
+		method, destination := models.PayoutMethodUPI, owner.UpiID
+		if destination == "" {
+			method, destination = models.PayoutMethodBank, owner.BankAccount
+		}
+		if destination == "" {
+			log.Printf("owner %d has %d to pay out but no UPI ID or bank account", ownerID, balance)
+			return nil
+		}
+
+		payout = &models.Payout{
+			OwnerID:     ownerID,
+			BatchID:     batchID,
+			Amount:      balance,
+			Method:      method,
+			Destination: destination,
+			Status:      models.PayoutStatusPending,
+		}
+		if err := tx.Create(payout).Error; err != nil {
+			return err
+		}
+
+		return models.PostPayoutBatched(tx, payout)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return payout, nil
+}
+
+func notifyPayoutBatched(payout *models.Payout) {
+	if _, err := notifications.Notify(payout.OwnerID, notifications.Notification{
+		Kind:  notifications.KindPayout,
+		Title: "Payout on its way",
+		Body:  fmt.Sprintf("A payout of %d to your %s %s has been scheduled.", payout.Amount, payout.Method, payout.Destination),
+	}); err != nil {
+		log.Printf("failed to notify user %d about payout %d: %v", payout.OwnerID, payout.ID, err)
+	}
+}
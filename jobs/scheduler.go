@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"log"
+	"time"
+)
+
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func() error
+}
+
+// Start runs every job on its own ticker in the background. Jobs must be
+// safe to run concurrently on several instances; they coordinate through
+// row locks in the database rather than through this scheduler.
+func Start(jobs ...Job) {
+	for _, job := range jobs {
+		go run(job)
+	}
+}
+
+func run(job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		runOnce(job)
+		<-ticker.C
+	}
+}
+
+func runOnce(job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[job] %s panicked: %v", job.Name, r)
+		}
+	}()
+
+	if err := job.Run(); err != nil {
+		log.Printf("[job] %s failed: %v", job.Name, err)
+	}
+}
@@ -0,0 +1,80 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+
+	// Sensitive messages carry a secret such as an OTP or a reset link, so
+	// LogMailer leaves their body out of the log. Use MAIL_OUTBOX_DIR to read
+	// them during local development.
+	Sensitive bool
+}
+
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var Default Mailer = LogMailer{}
+
+// Init picks the mailer for this process. Setting MAIL_OUTBOX_DIR writes
+// every message to that directory instead of the log, which is handy for
+// clicking through verification and reset links during local development.
+func Init() {
+	if dir := os.Getenv("MAIL_OUTBOX_DIR"); dir != "" {
+		Default = FileMailer{Dir: dir}
+	}
+}
+
+func Send(msg Message) error {
+	return Default.Send(msg)
+}
+
+type LogMailer struct{}
+
+func (LogMailer) Send(msg Message) error {
+	if msg.Sensitive {
+		log.Printf("[mail] to=%s subject=%q (sensitive body not logged)", msg.To, msg.Subject)
+		return nil
+	}
+	log.Printf("[mail] to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+type FileMailer struct {
+	Dir string
+}
+
+func (m FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), sanitizeFileName(msg.To))
+	content := fmt.Sprintf("To: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s\r\n",
+		msg.To, msg.Subject, time.Now().Format(time.RFC1123Z), msg.Body)
+
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(content), 0o644)
+}
+
+func sanitizeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		case r == '@':
+			return '_'
+		default:
+			return -1
+		}
+	}, s)
+}
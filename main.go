@@ -1,11 +1,19 @@
 package main
 
 import (
+	"log"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"proj/config"
+	"proj/handlers"
+	"proj/jobs"
+	"proj/mailer"
 	"proj/middleware"
 	"proj/models"
+	"proj/payments"
 	"proj/routes"
+	"proj/utils"
 )
 
 func main(){
@@ -13,14 +21,19 @@ func main(){
 	config.ConnectDB()
 	
 
-	config.DB.AutoMigrate(
-		&models.User{},
-		&models.Vehicle{},
-		&models.Availability{},
-		&models.Booking{},
-		&models.OBDTracker{},
-		&models.OBDReading{},
-		&models.Document{},
+	if err := models.Migrate(config.DB); err != nil {
+		log.Fatalf("database migration failed: %v", err)
+	}
+
+	mailer.Init()
+	payments.Init()
+	handlers.SetOTPStore(utils.NewDBOTPStore(config.DB))
+
+	jobs.Start(
+		jobs.Job{Name: "expire-pending-bookings", Interval: time.Minute, Run: jobs.ExpirePendingBookings},
+		jobs.Job{Name: "notify-late-returns", Interval: time.Minute, Run: jobs.NotifyLateReturns},
+		jobs.Job{Name: "release-deposits", Interval: 10 * time.Minute, Run: jobs.ReleaseDeposits},
+		jobs.Job{Name: "batch-payouts", Interval: config.GetPayoutInterval(), Run: jobs.BatchPayouts},
 	)
 
 	r := gin.Default()
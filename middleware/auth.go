@@ -27,6 +27,26 @@ func AuthRequired() gin.HandlerFunc {
 
 		c.Set("user_id", claims.UserID)
 		c.Set("email", claims.Email)
+		c.Set("role", auth.NormalizeRole(claims.Role))
+		c.Set("session_id", claims.SessionID)
+		c.Next()
+	}
+}
+
+// OptionalAuth identifies the caller like AuthRequired when a valid bearer
+// token is sent, and lets the request through anonymously otherwise.
+func OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if strings.HasPrefix(authHeader, "Bearer ") {
+			claims, err := auth.ValidateToken(strings.TrimPrefix(authHeader, "Bearer "))
+			if err == nil {
+				c.Set("user_id", claims.UserID)
+				c.Set("email", claims.Email)
+				c.Set("role", auth.NormalizeRole(claims.Role))
+				c.Set("session_id", claims.SessionID)
+			}
+		}
 		c.Next()
 	}
 }
@@ -34,7 +54,7 @@ func AuthRequired() gin.HandlerFunc {
 func AdminOnly() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, exists := c.Get("role")
-		if !exists || role != "admin" {
+		if !exists || role != auth.RoleAdmin {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access only"})
 			c.Abort()
 			return
@@ -43,6 +63,19 @@ func AdminOnly() gin.HandlerFunc {
 	}
 }
 
+func RequirePermission(permission auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, exists := c.Get("role")
+		roleStr, ok := role.(string)
+		if !exists || !ok || !auth.HasPermission(roleStr, permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to perform this action"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 func CheckHeader() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.GetHeader("X-USER")
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"proj/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serveWithRole(role string, handlers ...gin.HandlerFunc) int {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	chain := []gin.HandlerFunc{func(c *gin.Context) {
+		if role != "" {
+			c.Set("role", role)
+		}
+	}}
+	chain = append(chain, handlers...)
+	chain = append(chain, func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/", chain...)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w.Code
+}
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission auth.Permission
+		want       int
+	}{
+		{auth.RoleRenter, auth.PermissionBookingsCreate, http.StatusOK},
+		{auth.RoleRenter, auth.PermissionVehiclesManage, http.StatusForbidden},
+		{auth.RoleOwner, auth.PermissionVehiclesManage, http.StatusOK},
+		{auth.RoleOwner, auth.PermissionAdminAccess, http.StatusForbidden},
+		{auth.RoleModerator, auth.PermissionDocumentsReview, http.StatusOK},
+		{"", auth.PermissionBookingsCreate, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		if got := serveWithRole(tt.role, RequirePermission(tt.permission)); got != tt.want {
+			t.Errorf("RequirePermission(%q) for role %q = %d, want %d", tt.permission, tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestAdminOnly(t *testing.T) {
+	tests := map[string]int{
+		auth.RoleAdmin:     http.StatusOK,
+		auth.RoleModerator: http.StatusForbidden,
+		auth.RoleOwner:     http.StatusForbidden,
+		auth.RoleRenter:    http.StatusForbidden,
+		"":                 http.StatusForbidden,
+	}
+
+	for role, want := range tests {
+		if got := serveWithRole(role, AdminOnly()); got != want {
+			t.Errorf("AdminOnly for role %q = %d, want %d", role, got, want)
+		}
+	}
+}
+
+func TestAuthRequiredRejectsMissingToken(t *testing.T) {
+	for _, header := range []string{"", "Basic abc", "Bearer"} {
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.GET("/", AuthRequired(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: got %d, want 401", header, w.Code)
+		}
+	}
+}
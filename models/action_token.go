@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ActionTokenPurposeEmailVerification = "email_verification"
+	ActionTokenPurposePasswordReset     = "password_reset"
+)
+
+type ActionToken struct {
+	gorm.Model
+	UserID uint `json:"user_id" gorm:"not null;index"`
+	User   User `json:"-" gorm:"foreignKey:UserID"`
+
+	Purpose   string     `json:"purpose" gorm:"not null"`
+	TokenID   string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	AuditActionOTPLockout    = "otp_lockout"
+	AuditActionPromoCreated  = "promo_created"
+	AuditActionPromoUpdated  = "promo_updated"
+	AuditActionPromoRedeemed = "promo_redeemed"
+	AuditActionPayoutPaid    = "payout_paid"
+	AuditActionPayoutFailed  = "payout_failed"
+)
+
+type AuditLog struct {
+	gorm.Model
+	ActorID *uint `json:"actor_id"`
+
+	Action     string `json:"action" gorm:"not null;index"`
+	EntityType string `json:"entity_type" gorm:"not null"`
+	EntityID   uint   `json:"entity_id" gorm:"index"`
+
+	Details   string `json:"details" gorm:"type:text"`
+	IPAddress string `json:"ip_address"`
+}
@@ -12,11 +12,12 @@ type Availability struct {
 	
 	AvailableFrom time.Time `json:"available_from"`
 	AvailableTo   time.Time `json:"available_to"`
-	
-	IsRecurring   bool   `json:"is_recurring" gorm:"default:false"`
-	DaysOfWeek    string `json:"days_of_week"`
-	StartTime     string `json:"start_time"`
-	EndTime       string `json:"end_time"`
-	
-	Status        string `json:"status" gorm:"default:'available'"`
+
+	IsRecurring bool   `json:"is_recurring" gorm:"default:false"`
+	DaysOfWeek  string `json:"days_of_week"`
+	StartTime   string `json:"start_time"`
+	EndTime     string `json:"end_time"`
+	ExceptDates string `json:"except_dates"`
+
+	Status string `json:"status" gorm:"default:'available'"`
 }
@@ -20,23 +20,54 @@ type Booking struct {
 	DurationHours int       `json:"duration_hours"`
 	
 	PricingModel  string `json:"pricing_model" gorm:"default:'distance'"` 
-	
-	PricePerKm      int64 `json:"price_per_km"`
-	PricePerHour    int64 `json:"price_per_hour"`
-	BasePrice       int64 `json:"base_price"`
-	EstimatedPrice  int64 `json:"estimated_price"`
-	FinalPrice      int64 `json:"final_price"`
-	SecurityDeposit int64 `json:"security_deposit"`
-	
-	PickupLocation  string    `json:"pickup_location"`
-	ReturnLocation  string    `json:"return_location"`
-	PickupTime      time.Time `json:"pickup_time"`
-	ReturnTime      time.Time `json:"return_time"`
-	
-	Status        string `json:"status" gorm:"default:'pending'"`
-	
-	PickupOTP     string `json:"pickup_otp"`
-	ReturnOTP     string `json:"return_otp"`
+	EstimatedDistanceKm float64 `json:"estimated_distance_km"`
+
+	PricePerKm            int64        `json:"price_per_km"`
+	PricePerHour          int64        `json:"price_per_hour"`
+	PricePerDay           int64        `json:"price_per_day"`
+	BasePrice             int64        `json:"base_price"`
+	WeeklyDiscountPercent int          `json:"weekly_discount_percent"`
+	EstimatedPrice        int64        `json:"estimated_price"`
+	FinalPrice            int64        `json:"final_price"`
+	SecurityDeposit       int64        `json:"security_deposit"`
+	DepositStatus         string       `json:"deposit_status"`
+	DepositHeld           int64        `json:"deposit_held"`
+	DepositCaptured       int64        `json:"deposit_captured"`
+	DepositReleased       int64        `json:"deposit_released"`
+	DepositReleaseAfter   *time.Time   `json:"deposit_release_after,omitempty"`
+	DepositReleasedAt     *time.Time   `json:"deposit_released_at,omitempty"`
+	PriceBreakdown        *PriceQuote  `json:"price_breakdown,omitempty" gorm:"type:text"`
+	PricingRules          PricingRules `json:"pricing_rules,omitempty" gorm:"type:text"`
+	PromoCodeID           *uint        `json:"promo_code_id"`
+	PromoCode             string       `json:"promo_code"`
+	PromoDiscount         int64        `json:"promo_discount"`
+	CreditsApplied        int64        `json:"credits_applied"`
+	AmountPaid            int64        `json:"amount_paid"`
+	CommissionPercent     int          `json:"commission_percent"`
+	PaymentDueAt          *time.Time   `json:"payment_due_at,omitempty"`
+
+	PickupLocation string    `json:"pickup_location"`
+	ReturnLocation string    `json:"return_location"`
+	PickupTime     time.Time `json:"pickup_time"`
+	ReturnTime     time.Time `json:"return_time"`
+
+	Status string `json:"status" gorm:"default:'pending'"`
+
+	CancellationPolicy string     `json:"cancellation_policy"`
+	CancelledAt        *time.Time `json:"cancelled_at,omitempty"`
+	CancelledByID      *uint      `json:"cancelled_by_id"`
+	CancelledByRole    string     `json:"cancelled_by_role"`
+	CancellationReason string     `json:"cancellation_reason" gorm:"type:text"`
+	CancellationFee    int64      `json:"cancellation_fee"`
+	CancellationRefund int64      `json:"cancellation_refund"`
+	OwnerPenalty       int64      `json:"owner_penalty"`
+
+	LateGraceMinutes     int        `json:"late_grace_minutes"`
+	LateFeePerHour       int64      `json:"late_fee_per_hour"`
+	LateMinutes          int        `json:"late_minutes"`
+	LateFee              int64      `json:"late_fee"`
+	EndingSoonNotifiedAt *time.Time `json:"ending_soon_notified_at,omitempty"`
+	OverdueNotifiedAt    *time.Time `json:"overdue_notified_at,omitempty"`
 	
 	OdometerStartKm       int     `json:"odometer_start_km"`
 	OdometerEndKm         int     `json:"odometer_end_km"`
@@ -46,6 +77,10 @@ type Booking struct {
 	FuelLevelEndPercent   int     `json:"fuel_level_end_percent"`
 	FuelConsumedLiters    float64 `json:"fuel_consumed_liters"`
 	FuelCostCharged       int64   `json:"fuel_cost_charged"`
+	FuelPolicy            string  `json:"fuel_policy"`
+	TankCapacityLiters    float64 `json:"tank_capacity_liters"`
+	FuelRefillLiters      float64 `json:"fuel_refill_liters"`
+	FuelPricePerLiter     int64   `json:"fuel_price_per_liter"`
 	
 	DamageReportStart     string `json:"damage_report_start" gorm:"type:text"`
 	DamageReportEnd       string `json:"damage_report_end" gorm:"type:text"`
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AmendmentTypeExtend = "extend"
+	AmendmentTypeModify = "modify"
+
+	AmendmentStatusPending  = "pending"
+	AmendmentStatusApplied  = "applied"
+	AmendmentStatusRejected = "rejected"
+	AmendmentStatusExpired  = "expired"
+)
+
+type BookingAmendment struct {
+	gorm.Model
+	BookingID     uint `json:"booking_id" gorm:"not null;index"`
+	RequestedByID uint `json:"requested_by_id" gorm:"not null"`
+
+	Type   string `json:"type" gorm:"not null"`
+	Status string `json:"status" gorm:"default:'pending'"`
+	Reason string `json:"reason" gorm:"type:text"`
+
+	OldStartTime time.Time `json:"old_start_time"`
+	OldEndTime   time.Time `json:"old_end_time"`
+	NewStartTime time.Time `json:"new_start_time"`
+	NewEndTime   time.Time `json:"new_end_time"`
+
+	OldPickupLocation string `json:"old_pickup_location"`
+	NewPickupLocation string `json:"new_pickup_location"`
+	OldReturnLocation string `json:"old_return_location"`
+	NewReturnLocation string `json:"new_return_location"`
+
+	OldPricingModel        string  `json:"old_pricing_model"`
+	NewPricingModel        string  `json:"new_pricing_model"`
+	OldEstimatedDistanceKm float64 `json:"old_estimated_distance_km"`
+	NewEstimatedDistanceKm float64 `json:"new_estimated_distance_km"`
+
+	OldEstimatedPrice int64 `json:"old_estimated_price"`
+	NewEstimatedPrice int64 `json:"new_estimated_price"`
+
+	DecidedByID  *uint      `json:"decided_by_id"`
+	DecidedAt    *time.Time `json:"decided_at,omitempty"`
+	DecisionNote string     `json:"decision_note" gorm:"type:text"`
+}
+
+// ExpireOpenAmendments closes any amendment still waiting for the owner once
+// the booking can no longer change.
+func ExpireOpenAmendments(tx *gorm.DB, bookingID uint) error {
+	return tx.Model(&BookingAmendment{}).
+		Where("booking_id = ? AND status = ?", bookingID, AmendmentStatusPending).
+		Update("status", AmendmentStatusExpired).Error
+}
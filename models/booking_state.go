@@ -0,0 +1,162 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	BookingActorRenter = "renter"
+	BookingActorOwner  = "owner"
+	BookingActorAdmin  = "admin"
+	BookingActorSystem = "system"
+)
+
+var (
+	ErrInvalidBookingTransition = errors.New("invalid booking status transition")
+	ErrBookingTransitionDenied  = errors.New("not allowed to perform this booking transition")
+	ErrBookingStatusChanged     = errors.New("booking status changed concurrently")
+)
+
+type BookingTransition struct {
+	From   string
+	To     string
+	Actors []string
+}
+
+// BookingTransitions is the single source of truth for how a booking moves
+// through its lifecycle and who may move it. Handlers and background jobs go
+// through TransitionBooking instead of writing the status column directly.
+var BookingTransitions = []BookingTransition{
+	{From: BookingStatusPending, To: BookingStatusConfirmed, Actors: []string{BookingActorOwner}},
+	{From: BookingStatusPending, To: BookingStatusAwaitingPayment, Actors: []string{BookingActorOwner}},
+	{From: BookingStatusPending, To: BookingStatusCancelled, Actors: []string{BookingActorRenter, BookingActorOwner, BookingActorAdmin}},
+	{From: BookingStatusPending, To: BookingStatusExpired, Actors: []string{BookingActorSystem}},
+	{From: BookingStatusAwaitingPayment, To: BookingStatusConfirmed, Actors: []string{BookingActorSystem}},
+	{From: BookingStatusAwaitingPayment, To: BookingStatusPaymentFailed, Actors: []string{BookingActorSystem}},
+	{From: BookingStatusAwaitingPayment, To: BookingStatusCancelled, Actors: []string{BookingActorRenter, BookingActorOwner, BookingActorAdmin}},
+	{From: BookingStatusAwaitingPayment, To: BookingStatusExpired, Actors: []string{BookingActorSystem}},
+	{From: BookingStatusPaymentFailed, To: BookingStatusAwaitingPayment, Actors: []string{BookingActorRenter}},
+	{From: BookingStatusPaymentFailed, To: BookingStatusConfirmed, Actors: []string{BookingActorSystem}},
+	{From: BookingStatusPaymentFailed, To: BookingStatusCancelled, Actors: []string{BookingActorRenter, BookingActorOwner, BookingActorAdmin}},
+	{From: BookingStatusPaymentFailed, To: BookingStatusExpired, Actors: []string{BookingActorSystem}},
+	{From: BookingStatusConfirmed, To: BookingStatusCancelled, Actors: []string{BookingActorRenter, BookingActorOwner, BookingActorAdmin}},
+	{From: BookingStatusConfirmed, To: BookingStatusOngoing, Actors: []string{BookingActorOwner}},
+	{From: BookingStatusOngoing, To: BookingStatusCompleted, Actors: []string{BookingActorOwner}},
+	{From: BookingStatusOngoing, To: BookingStatusDisputed, Actors: []string{BookingActorRenter, BookingActorOwner}},
+	{From: BookingStatusCompleted, To: BookingStatusDisputed, Actors: []string{BookingActorRenter, BookingActorOwner}},
+	{From: BookingStatusDisputed, To: BookingStatusCompleted, Actors: []string{BookingActorAdmin}},
+	{From: BookingStatusDisputed, To: BookingStatusCancelled, Actors: []string{BookingActorAdmin}},
+}
+
+// BookingStatusesFinal are the statuses after which a booking's times,
+// locations and price can no longer be amended.
+var BookingStatusesFinal = []string{
+	BookingStatusCompleted,
+	BookingStatusCancelled,
+	BookingStatusExpired,
+}
+
+// BookingStatusesHoldingVehicle are the statuses in which a booking blocks
+// its vehicle for other renters. Bookings waiting for payment hold the slot
+// until their payment deadline.
+var BookingStatusesHoldingVehicle = []string{
+	BookingStatusAwaitingPayment,
+	BookingStatusPaymentFailed,
+	BookingStatusConfirmed,
+	BookingStatusOngoing,
+}
+
+type BookingEvent struct {
+	gorm.Model
+	BookingID uint `json:"booking_id" gorm:"not null;index"`
+
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+
+	ActorID   *uint  `json:"actor_id"`
+	ActorRole string `json:"actor_role"`
+	Reason    string `json:"reason" gorm:"type:text"`
+
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func CanTransitionBooking(from string, to string, actor string) error {
+	for _, t := range BookingTransitions {
+		if t.From != from || t.To != to {
+			continue
+		}
+		for _, a := range t.Actors {
+			if a == actor {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s cannot move booking from %s to %s", ErrBookingTransitionDenied, actor, from, to)
+	}
+	return fmt.Errorf("%w: %s to %s", ErrInvalidBookingTransition, from, to)
+}
+
+// TransitionBooking validates and applies a status change together with any
+// extra column updates, and records it in booking_events. The update is
+// conditional on the status the caller loaded, so if another request moved
+// the booking first this returns ErrBookingStatusChanged instead of
+// overwriting it.
+func TransitionBooking(tx *gorm.DB, booking *Booking, to string, actor string, actorID *uint, reason string, updates map[string]interface{}) error {
+	from := booking.Status
+	if err := CanTransitionBooking(from, to, actor); err != nil {
+		return err
+	}
+
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["status"] = to
+
+	result := tx.Model(&Booking{}).
+		Where("id = ? AND status = ?", booking.ID, from).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrBookingStatusChanged
+	}
+
+	if err := tx.Create(&BookingEvent{
+		BookingID:  booking.ID,
+		FromStatus: from,
+		ToStatus:   to,
+		ActorID:    actorID,
+		ActorRole:  actor,
+		Reason:     reason,
+		OccurredAt: time.Now(),
+	}).Error; err != nil {
+		return err
+	}
+
+	for _, status := range BookingStatusesFinal {
+		if status == to {
+			if err := ExpireOpenAmendments(tx, booking.ID); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	booking.Status = to
+	return nil
+}
+
+func RecordBookingCreated(tx *gorm.DB, booking *Booking, actorID *uint) error {
+	return tx.Create(&BookingEvent{
+		BookingID:  booking.ID,
+		ToStatus:   booking.Status,
+		ActorID:    actorID,
+		ActorRole:  BookingActorRenter,
+		Reason:     "booking created",
+		OccurredAt: time.Now(),
+	}).Error
+}
@@ -9,15 +9,60 @@ const (
 
 const (
 	BookingStatusPending   = "pending"
-	BookingStatusConfirmed = "confirmed"
-	BookingStatusOngoing   = "ongoing"
-	BookingStatusCompleted = "completed"
-	BookingStatusCancelled = "cancelled"
-	BookingStatusDisputed  = "disputed"
+	BookingStatusAwaitingPayment = "awaiting_payment"
+	BookingStatusPaymentFailed   = "payment_failed"
+	BookingStatusConfirmed       = "confirmed"
+	BookingStatusOngoing         = "ongoing"
+	BookingStatusCompleted       = "completed"
+	BookingStatusCancelled       = "cancelled"
+	BookingStatusDisputed        = "disputed"
+	BookingStatusExpired         = "expired"
 )
 
 const (
 	PricingModelDistance = "distance"
 	PricingModelTime     = "time"
 	PricingModelHybrid   = "hybrid"
+	PricingModelDaily    = "daily"
+)
+
+const (
+	FuelTypePetrol   = "petrol"
+	FuelTypeDiesel   = "diesel"
+	FuelTypeCNG      = "cng"
+	FuelTypeElectric = "electric"
+)
+
+const (
+	FuelPolicySameLevel  = "same_level"
+	FuelPolicyReturnFull = "return_full"
+)
+
+const (
+	CancellationPolicyFlexible = "flexible"
+	CancellationPolicyModerate = "moderate"
+	CancellationPolicyStrict   = "strict"
+)
+
+// Defaults for vehicle booking and turnaround rules the owner leaves out when
+// listing a vehicle. An explicit 0 turns the rule off.
+const (
+	DefaultMinRentalHours = 1
+	DefaultMaxRentalDays  = 7
+	DefaultBufferMinutes  = 30
+	DefaultMinNoticeHours = 2
+	DefaultMaxAdvanceDays = 90
+)
+
+const (
+	GenderMale   = "male"
+	GenderFemale = "female"
+)
+
+const (
+	BookingRuleMinRentalHours = "min_rental_hours"
+	BookingRuleMaxRentalDays  = "max_rental_days"
+	BookingRuleRenterYear     = "renter_year"
+	BookingRuleGender         = "gender"
+	BookingRuleHelmet         = "helmet"
 )
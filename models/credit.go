@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	CreditTypeReferralSignup = "referral_signup"
+	CreditTypeReferralReward = "referral_reward"
+	CreditTypeBookingSpend   = "booking_spend"
+	CreditTypeBookingRefund  = "booking_refund"
+)
+
+var ErrInsufficientCredit = errors.New("insufficient credit balance")
+
+// CreditTransaction is one entry in a user's credit ledger. Amount is
+// positive for credits granted and negative for credits spent; the sum of a
+// user's entries always equals User.CreditBalance.
+type CreditTransaction struct {
+	gorm.Model
+	UserID uint `json:"user_id" gorm:"not null;index"`
+
+	Type         string `json:"type" gorm:"not null"`
+	Amount       int64  `json:"amount"`
+	BalanceAfter int64  `json:"balance_after"`
+
+	BookingID      *uint  `json:"booking_id,omitempty" gorm:"index"`
+	ReferredUserID *uint  `json:"referred_user_id,omitempty" gorm:"index"`
+	Note           string `json:"note"`
+}
+
+// AdjustCredit changes a user's credit balance and records the entry. The
+// user row is locked for the rest of the transaction so concurrent spends
+// can't take the balance below zero.
+func AdjustCredit(tx *gorm.DB, entry *CreditTransaction) error {
+	var user User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, entry.UserID).Error; err != nil {
+		return err
+	}
+
+	balance := user.CreditBalance + entry.Amount
+	if balance < 0 {
+		return ErrInsufficientCredit
+	}
+
+	if err := tx.Model(&user).Update("credit_balance", balance).Error; err != nil {
+		return err
+	}
+
+	entry.BalanceAfter = balance
+	return tx.Create(entry).Error
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DepositStatusHeld     = "held"
+	DepositStatusReleased = "released"
+
+	DepositEntryHold    = "hold"
+	DepositEntryCapture = "capture"
+	DepositEntryRelease = "release"
+
+	// Fuel and late returns are billed in the final price, so they are not
+	// claimable against the deposit.
+	DepositReasonDamage   = "damage"
+	DepositReasonCleaning = "cleaning"
+	DepositReasonOther    = "other"
+
+	// DepositReasonShortfall is only used by the system, to collect a final
+	// price that came to more than the renter paid.
+	DepositReasonShortfall = "final_price_shortfall"
+)
+
+var (
+	ErrDepositNotHeld      = errors.New("security deposit is not held")
+	ErrDepositInsufficient = errors.New("capture exceeds the remaining security deposit")
+)
+
+// DepositTransaction is one movement of a booking's security deposit. A
+// deposit is held once, captured any number of times for itemized reasons
+// and released once; what is released is whatever was not captured.
+type DepositTransaction struct {
+	gorm.Model
+	BookingID uint `json:"booking_id" gorm:"not null;index"`
+
+	Type        string `json:"type" gorm:"not null"`
+	Amount      int64  `json:"amount"`
+	Reason      string `json:"reason"`
+	Description string `json:"description" gorm:"type:text"`
+
+	ActorID   *uint  `json:"actor_id"`
+	ActorRole string `json:"actor_role"`
+}
+
+func DepositRemaining(booking *Booking) int64 {
+	return booking.DepositHeld - booking.DepositCaptured
+}
+
+func HoldDeposit(tx *gorm.DB, booking *Booking, actorID *uint, actorRole string) error {
+	if booking.SecurityDeposit <= 0 || booking.DepositStatus != "" {
+		return nil
+	}
+
+	if err := tx.Model(booking).Updates(map[string]interface{}{
+		"deposit_status": DepositStatusHeld,
+		"deposit_held":   booking.SecurityDeposit,
+	}).Error; err != nil {
+		return err
+	}
+
+	booking.DepositStatus = DepositStatusHeld
+	booking.DepositHeld = booking.SecurityDeposit
+
+	return tx.Create(&DepositTransaction{
+		BookingID: booking.ID,
+		Type:      DepositEntryHold,
+		Amount:    booking.SecurityDeposit,
+		ActorID:   actorID,
+		ActorRole: actorRole,
+	}).Error
+}
+
+// CaptureDeposit deducts amount from a held deposit. The update is
+// conditional on the remaining balance, so concurrent captures can't take
+// more than was held.
+func CaptureDeposit(tx *gorm.DB, booking *Booking, amount int64, reason string, description string, actorID *uint, actorRole string) error {
+	if booking.DepositStatus != DepositStatusHeld {
+		return ErrDepositNotHeld
+	}
+
+	result := tx.Model(&Booking{}).
+		Where("id = ? AND deposit_status = ? AND deposit_held - deposit_captured >= ?", booking.ID, DepositStatusHeld, amount).
+		Update("deposit_captured", gorm.Expr("deposit_captured + ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDepositInsufficient
+	}
+
+	booking.DepositCaptured += amount
+
+	entry := DepositTransaction{
+		BookingID:   booking.ID,
+		Type:        DepositEntryCapture,
+		Amount:      amount,
+		Reason:      reason,
+		Description: description,
+		ActorID:     actorID,
+		ActorRole:   actorRole,
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return err
+	}
+
+	return postDepositMovement(tx, booking, &entry)
+}
+
+// CollectShortfallFromDeposit covers what the renter still owes on the final
+// price from their held deposit, as far as it goes. Whatever is left is
+// collected through a top-up payment.
+func CollectShortfallFromDeposit(tx *gorm.DB, booking *Booking) error {
+	due, err := ShortfallDue(tx, booking)
+	if err != nil {
+		return err
+	}
+
+	// Only a deposit paid through the platform can cover it.
+	held, err := LedgerBalance(tx, LedgerAccountDepositsHeld, nil, &booking.ID)
+	if err != nil {
+		return err
+	}
+
+	amount := DepositRemaining(booking)
+	if held < amount {
+		amount = held
+	}
+	if due < amount {
+		amount = due
+	}
+	if amount <= 0 || booking.DepositStatus != DepositStatusHeld {
+		return nil
+	}
+
+	return CaptureDeposit(tx, booking, amount, DepositReasonShortfall,
+		fmt.Sprintf("Final price of booking #%d above the amount paid", booking.ID), nil, BookingActorSystem)
+}
+
+// ReleaseDeposit returns whatever was not captured to the renter and closes
+// the deposit. Releasing a deposit that isn't held is a no-op.
+func ReleaseDeposit(tx *gorm.DB, booking *Booking, actorID *uint, actorRole string, description string) error {
+	now := time.Now()
+	result := tx.Model(&Booking{}).
+		Where("id = ? AND deposit_status = ?", booking.ID, DepositStatusHeld).
+		Updates(map[string]interface{}{
+			"deposit_status":      DepositStatusReleased,
+			"deposit_released":    gorm.Expr("deposit_held - deposit_captured"),
+			"deposit_released_at": &now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	if err := tx.First(booking, booking.ID).Error; err != nil {
+		return err
+	}
+
+	entry := DepositTransaction{
+		BookingID:   booking.ID,
+		Type:        DepositEntryRelease,
+		Amount:      booking.DepositReleased,
+		Description: description,
+		ActorID:     actorID,
+		ActorRole:   actorRole,
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return err
+	}
+
+	return postDepositMovement(tx, booking, &entry)
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Invoice is the tax invoice issued when a booking completes. It snapshots
+// everything it shows so it reads the same however the booking, vehicle or
+// users change later. The platform commission is GST-inclusive; the GST
+// fields split it into its taxable value and CGST/SGST.
+type Invoice struct {
+	gorm.Model
+	BookingID     uint      `json:"booking_id" gorm:"uniqueIndex;not null"`
+	Number        string    `json:"number" gorm:"uniqueIndex;not null"`
+	FinancialYear string    `json:"financial_year" gorm:"not null;index"`
+	Sequence      int       `json:"sequence"`
+	IssuedAt      time.Time `json:"issued_at"`
+
+	// A booking cancelled after it completed has its invoice voided. The
+	// invoice keeps its number so the sequence stays without gaps.
+	VoidedAt   *time.Time `json:"voided_at,omitempty"`
+	VoidReason string     `json:"void_reason,omitempty"`
+
+	RenterID    uint   `json:"renter_id" gorm:"index"`
+	RenterName  string `json:"renter_name"`
+	RenterEmail string `json:"renter_email"`
+	OwnerID     uint   `json:"owner_id" gorm:"index"`
+	OwnerName   string `json:"owner_name"`
+	Vehicle     string `json:"vehicle"`
+
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	PickupTime time.Time `json:"pickup_time"`
+	ReturnTime time.Time `json:"return_time"`
+
+	LineItems  *PriceQuote `json:"line_items" gorm:"type:text"`
+	Total      int64       `json:"total"`
+	AmountPaid int64       `json:"amount_paid"`
+	// BalanceDue is what the renter still owes; negative when part of the
+	// payment is refunded.
+	BalanceDue int64 `json:"balance_due"`
+
+	CommissionPercent int    `json:"commission_percent"`
+	CommissionAmount  int64  `json:"commission_amount"`
+	CommissionTaxable int64  `json:"commission_taxable"`
+	GSTPercent        int    `json:"gst_percent"`
+	CGST              int64  `json:"cgst"`
+	SGST              int64  `json:"sgst"`
+	PlatformName      string `json:"platform_name"`
+	PlatformGSTIN     string `json:"platform_gstin"`
+}
+
+// VoidInvoice voids the invoice of a booking, if it has one that is still
+// valid.
+func VoidInvoice(tx *gorm.DB, bookingID uint, reason string) error {
+	now := time.Now()
+	return tx.Model(&Invoice{}).
+		Where("booking_id = ? AND voided_at IS NULL", bookingID).
+		Updates(map[string]interface{}{
+			"voided_at":   &now,
+			"void_reason": reason,
+		}).Error
+}
+
+// InvoiceSequence holds the last invoice number issued in a financial year.
+type InvoiceSequence struct {
+	gorm.Model
+	FinancialYear string `gorm:"uniqueIndex;not null"`
+	LastNumber    int
+}
+
+// NextInvoiceNumber reserves the next number in the financial year. The
+// sequence row stays locked until tx ends, and a rolled back invoice gives
+// its number back, so numbers are issued without gaps.
+func NextInvoiceNumber(tx *gorm.DB, financialYear string) (int, string, error) {
+	seq := InvoiceSequence{FinancialYear: financialYear}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "financial_year"}},
+		DoNothing: true,
+	}).Create(&seq).Error; err != nil {
+		return 0, "", err
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("financial_year = ?", financialYear).
+		First(&seq).Error; err != nil {
+		return 0, "", err
+	}
+
+	seq.LastNumber++
+	if err := tx.Model(&seq).Update("last_number", seq.LastNumber).Error; err != nil {
+		return 0, "", err
+	}
+
+	return seq.LastNumber, fmt.Sprintf("INV/%s/%06d", financialYear, seq.LastNumber), nil
+}
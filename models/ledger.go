@@ -0,0 +1,456 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Ledger accounts. Owner and renter accounts are kept per user through
+// LedgerPosting.UserID; balances are reported as credits minus debits, so
+// liabilities and revenue are positive and cash is negative.
+const (
+	LedgerAccountCash              = "cash"
+	LedgerAccountRenterPrepayments = "renter_prepayments"
+	LedgerAccountDepositsHeld      = "deposits_held"
+	LedgerAccountRefundsDue        = "refunds_due"
+	LedgerAccountRenterReceivable  = "renter_receivable"
+	LedgerAccountOwnerPayable      = "owner_payable"
+	LedgerAccountPayoutsInTransit  = "payouts_in_transit"
+	LedgerAccountCommission        = "commission_revenue"
+	LedgerAccountPenalties         = "penalty_revenue"
+
+	// Earnings on the part of a final price the renter hasn't paid yet are
+	// held here until it is collected, so they can't be paid out early.
+	LedgerAccountOwnerPending      = "owner_pending"
+	LedgerAccountCommissionPending = "commission_pending"
+)
+
+const (
+	LedgerEntryPaymentCaptured  = "payment_captured"
+	LedgerEntryRefund           = "refund"
+	LedgerEntryRefundFailed     = "refund_failed"
+	LedgerEntryBookingCompleted = "booking_completed"
+	LedgerEntryBookingCancelled = "booking_cancelled"
+	LedgerEntryBookingReversed  = "booking_settlement_reversed"
+	LedgerEntryDepositCaptured  = "deposit_captured"
+	LedgerEntryDepositReleased  = "deposit_released"
+	LedgerEntryPayoutBatched    = "payout_batched"
+	LedgerEntryPayoutPaid       = "payout_paid"
+	LedgerEntryPayoutFailed     = "payout_failed"
+)
+
+var ErrLedgerUnbalanced = errors.New("ledger entry debits and credits do not balance")
+
+// LedgerEntry is one balanced journal entry. Reference names the event the
+// entry records and is unique, so posting the same event twice is a no-op.
+type LedgerEntry struct {
+	gorm.Model
+	Reference   string `json:"reference" gorm:"uniqueIndex;not null"`
+	Type        string `json:"type" gorm:"not null;index"`
+	BookingID   *uint  `json:"booking_id" gorm:"index"`
+	Description string `json:"description"`
+
+	Postings []LedgerPosting `json:"postings,omitempty" gorm:"foreignKey:EntryID"`
+}
+
+type LedgerPosting struct {
+	gorm.Model
+	EntryID   uint   `json:"entry_id" gorm:"not null;index"`
+	Account   string `json:"account" gorm:"not null;index"`
+	UserID    *uint  `json:"user_id" gorm:"index"`
+	BookingID *uint  `json:"booking_id" gorm:"index"`
+	Debit     int64  `json:"debit"`
+	Credit    int64  `json:"credit"`
+}
+
+func LedgerDebit(account string, userID *uint, amount int64) LedgerPosting {
+	return LedgerPosting{Account: account, UserID: userID, Debit: amount}
+}
+
+func LedgerCredit(account string, userID *uint, amount int64) LedgerPosting {
+	return LedgerPosting{Account: account, UserID: userID, Credit: amount}
+}
+
+// PostLedgerEntry writes entry and its postings. Zero postings are dropped,
+// and an entry whose reference was already posted is skipped.
+func PostLedgerEntry(tx *gorm.DB, entry *LedgerEntry) error {
+	var postings []LedgerPosting
+	var debits, credits int64
+	for _, p := range entry.Postings {
+		if p.Debit < 0 || p.Credit < 0 || (p.Debit > 0 && p.Credit > 0) {
+			return fmt.Errorf("invalid posting to %s: %w", p.Account, ErrLedgerUnbalanced)
+		}
+		if p.Debit == 0 && p.Credit == 0 {
+			continue
+		}
+		if p.BookingID == nil {
+			p.BookingID = entry.BookingID
+		}
+		debits += p.Debit
+		credits += p.Credit
+		postings = append(postings, p)
+	}
+
+	if debits != credits {
+		return ErrLedgerUnbalanced
+	}
+	if len(postings) == 0 {
+		return nil
+	}
+
+	var existing int64
+	if err := tx.Model(&LedgerEntry{}).Where("reference = ?", entry.Reference).Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
+	}
+
+	entry.Postings = postings
+	return tx.Create(entry).Error
+}
+
+// LedgerBalance sums credits minus debits on account, optionally narrowed to
+// one user and one booking.
+func LedgerBalance(tx *gorm.DB, account string, userID *uint, bookingID *uint) (int64, error) {
+	query := tx.Model(&LedgerPosting{}).Where("account = ?", account)
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	if bookingID != nil {
+		query = query.Where("booking_id = ?", *bookingID)
+	}
+
+	var balance int64
+	err := query.Select("COALESCE(SUM(credit - debit), 0)").Scan(&balance).Error
+	return balance, err
+}
+
+// PostPaymentCaptured records money collected for a booking: the deposit part
+// is held and the rest is prepaid rent until the booking settles. Top-ups are
+// all rent, and a top-up on a completed booking pays off its shortfall. A
+// payment the booking no longer needs is owed straight back to the renter.
+func PostPaymentCaptured(tx *gorm.DB, booking *Booking, payment *Payment, forBooking bool) error {
+	entry := &LedgerEntry{
+		Reference:   fmt.Sprintf("payment:%d:captured", payment.ID),
+		Type:        LedgerEntryPaymentCaptured,
+		BookingID:   &booking.ID,
+		Description: fmt.Sprintf("Payment %s for booking #%d", payment.ProviderOrderID, booking.ID),
+		Postings:    []LedgerPosting{LedgerDebit(LedgerAccountCash, nil, payment.Amount)},
+	}
+
+	if !forBooking {
+		entry.Postings = append(entry.Postings, LedgerCredit(LedgerAccountRefundsDue, &booking.RenterID, payment.Amount))
+		return PostLedgerEntry(tx, entry)
+	}
+
+	if payment.Kind == PaymentKindTopUp && booking.Status == BookingStatusCompleted {
+		postings, err := shortfallCollectedPostings(tx, booking, payment.Amount)
+		if err != nil {
+			return err
+		}
+		entry.Postings = append(entry.Postings, postings...)
+		return PostLedgerEntry(tx, entry)
+	}
+
+	deposit := booking.SecurityDeposit
+	if payment.Kind == PaymentKindTopUp {
+		deposit = 0
+	}
+	if deposit > payment.Amount {
+		deposit = payment.Amount
+	}
+
+	entry.Postings = append(entry.Postings,
+		LedgerCredit(LedgerAccountDepositsHeld, &booking.RenterID, deposit),
+		LedgerCredit(LedgerAccountRenterPrepayments, &booking.RenterID, payment.Amount-deposit),
+	)
+	return PostLedgerEntry(tx, entry)
+}
+
+func PostRefund(tx *gorm.DB, payment *Payment, refund *PaymentRefund) error {
+	return PostLedgerEntry(tx, &LedgerEntry{
+		Reference:   fmt.Sprintf("refund:%d", refund.ID),
+		Type:        LedgerEntryRefund,
+		BookingID:   &payment.BookingID,
+		Description: fmt.Sprintf("Refund #%d: %s", refund.ID, refund.Reason),
+		Postings: []LedgerPosting{
+			LedgerDebit(LedgerAccountRefundsDue, &payment.UserID, refund.Amount),
+			LedgerCredit(LedgerAccountCash, nil, refund.Amount),
+		},
+	})
+}
+
+// PostRefundFailed puts a refund the provider turned down back into
+// refunds_due so the next RefundBooking tries again.
+func PostRefundFailed(tx *gorm.DB, payment *Payment, refund *PaymentRefund) error {
+	return PostLedgerEntry(tx, &LedgerEntry{
+		Reference:   fmt.Sprintf("refund:%d:failed", refund.ID),
+		Type:        LedgerEntryRefundFailed,
+		BookingID:   &payment.BookingID,
+		Description: fmt.Sprintf("Refund #%d failed: %s", refund.ID, refund.FailureReason),
+		Postings: []LedgerPosting{
+			LedgerDebit(LedgerAccountCash, nil, refund.Amount),
+			LedgerCredit(LedgerAccountRefundsDue, &payment.UserID, refund.Amount),
+		},
+	})
+}
+
+// PostBookingSettlement earns price for the owner, less the commission
+// captured on the booking. Whatever was prepaid above the price is owed back
+// to the renter. Anything above the prepayment is owed by the renter, and
+// the earnings on it are held back, from the owner's share first, until it
+// is collected from the deposit or a top-up payment.
+func PostBookingSettlement(tx *gorm.DB, booking *Booking, price int64) error {
+	prepaid, err := LedgerBalance(tx, LedgerAccountRenterPrepayments, nil, &booking.ID)
+	if err != nil {
+		return err
+	}
+
+	commission := price * int64(booking.CommissionPercent) / 100
+	ownerShare := price - commission
+
+	var shortfall, ownerHeld int64
+	if price > prepaid {
+		shortfall = price - prepaid
+		ownerHeld = shortfall
+		if ownerHeld > ownerShare {
+			ownerHeld = ownerShare
+		}
+	}
+	commissionHeld := shortfall - ownerHeld
+
+	entry := &LedgerEntry{
+		Reference:   bookingSettlementReference(booking.ID),
+		Type:        LedgerEntryBookingCompleted,
+		BookingID:   &booking.ID,
+		Description: fmt.Sprintf("Booking #%d completed", booking.ID),
+		Postings: []LedgerPosting{
+			LedgerDebit(LedgerAccountRenterPrepayments, &booking.RenterID, prepaid),
+			LedgerCredit(LedgerAccountOwnerPayable, &booking.OwnerID, ownerShare-ownerHeld),
+			LedgerCredit(LedgerAccountCommission, nil, commission-commissionHeld),
+			LedgerDebit(LedgerAccountRenterReceivable, &booking.RenterID, shortfall),
+			LedgerCredit(LedgerAccountOwnerPending, &booking.OwnerID, ownerHeld),
+			LedgerCredit(LedgerAccountCommissionPending, nil, commissionHeld),
+		},
+	}
+
+	if prepaid > price {
+		entry.Postings = append(entry.Postings, LedgerCredit(LedgerAccountRefundsDue, &booking.RenterID, prepaid-price))
+	}
+
+	return PostLedgerEntry(tx, entry)
+}
+
+// ShortfallDue is what the renter still owes on a booking whose final price
+// came to more than they had paid.
+func ShortfallDue(tx *gorm.DB, booking *Booking) (int64, error) {
+	balance, err := LedgerBalance(tx, LedgerAccountRenterReceivable, nil, &booking.ID)
+	if err != nil || balance >= 0 {
+		return 0, err
+	}
+	return -balance, nil
+}
+
+// shortfallCollectedPostings pays amount off the renter's shortfall and
+// releases the earnings held back on it, the owner's share first. The
+// caller posts the matching debit for wherever the money came from.
+func shortfallCollectedPostings(tx *gorm.DB, booking *Booking, amount int64) ([]LedgerPosting, error) {
+	ownerHeld, err := LedgerBalance(tx, LedgerAccountOwnerPending, nil, &booking.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	toOwner := amount
+	if toOwner > ownerHeld {
+		toOwner = ownerHeld
+	}
+
+	return []LedgerPosting{
+		LedgerCredit(LedgerAccountRenterReceivable, &booking.RenterID, amount),
+		LedgerDebit(LedgerAccountOwnerPending, &booking.OwnerID, toOwner),
+		LedgerCredit(LedgerAccountOwnerPayable, &booking.OwnerID, toOwner),
+		LedgerDebit(LedgerAccountCommissionPending, nil, amount-toOwner),
+		LedgerCredit(LedgerAccountCommission, nil, amount-toOwner),
+	}, nil
+}
+
+// PostBookingSettlementReversal undoes a booking's settlement so a completed
+// booking can be cancelled after a dispute: the owner's earnings and the
+// commission are taken back and the prepayment is held again for the
+// cancellation to refund. Bookings that never settled have nothing to undo.
+func PostBookingSettlementReversal(tx *gorm.DB, booking *Booking) error {
+	var settlement LedgerEntry
+	err := tx.Preload("Postings").Where("reference = ?", bookingSettlementReference(booking.ID)).First(&settlement).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	entry := &LedgerEntry{
+		Reference:   settlement.Reference + ":reversed",
+		Type:        LedgerEntryBookingReversed,
+		BookingID:   &booking.ID,
+		Description: fmt.Sprintf("Settlement of booking #%d reversed", booking.ID),
+	}
+	after := make(map[string]int64)
+	for _, p := range settlement.Postings {
+		entry.Postings = append(entry.Postings, LedgerPosting{
+			Account:   p.Account,
+			UserID:    p.UserID,
+			BookingID: p.BookingID,
+			Debit:     p.Credit,
+			Credit:    p.Debit,
+		})
+		after[p.Account] += p.Debit - p.Credit
+	}
+
+	// Any shortfall already collected is no longer owed: it goes back to
+	// the renter, and the earnings it released are taken back with it.
+	sweeps := []struct {
+		account, counter string
+		userID           *uint
+	}{
+		{LedgerAccountRenterReceivable, LedgerAccountRefundsDue, &booking.RenterID},
+		{LedgerAccountOwnerPending, LedgerAccountOwnerPayable, &booking.OwnerID},
+		{LedgerAccountCommissionPending, LedgerAccountCommission, nil},
+	}
+	for _, sweep := range sweeps {
+		balance, err := LedgerBalance(tx, sweep.account, nil, &booking.ID)
+		if err != nil {
+			return err
+		}
+		balance += after[sweep.account]
+		if balance > 0 {
+			entry.Postings = append(entry.Postings,
+				LedgerDebit(sweep.account, sweep.userID, balance),
+				LedgerCredit(sweep.counter, sweep.userID, balance),
+			)
+		} else if balance < 0 {
+			entry.Postings = append(entry.Postings,
+				LedgerCredit(sweep.account, sweep.userID, -balance),
+				LedgerDebit(sweep.counter, sweep.userID, -balance),
+			)
+		}
+	}
+
+	return PostLedgerEntry(tx, entry)
+}
+
+func bookingSettlementReference(bookingID uint) string {
+	return fmt.Sprintf("booking:%d:completed", bookingID)
+}
+
+// PostBookingCancellation releases the prepayment of a cancelled booking:
+// the renter's cancellation fee goes to the owner less commission, the rest
+// is owed back to the renter, and an owner penalty is charged against the
+// owner's balance.
+func PostBookingCancellation(tx *gorm.DB, booking *Booking, fee int64, ownerPenalty int64) error {
+	prepaid, err := LedgerBalance(tx, LedgerAccountRenterPrepayments, nil, &booking.ID)
+	if err != nil {
+		return err
+	}
+
+	if fee > prepaid {
+		fee = prepaid
+	}
+	commission := fee * int64(booking.CommissionPercent) / 100
+
+	return PostLedgerEntry(tx, &LedgerEntry{
+		Reference:   fmt.Sprintf("booking:%d:cancelled", booking.ID),
+		Type:        LedgerEntryBookingCancelled,
+		BookingID:   &booking.ID,
+		Description: fmt.Sprintf("Booking #%d cancelled", booking.ID),
+		Postings: []LedgerPosting{
+			LedgerDebit(LedgerAccountRenterPrepayments, &booking.RenterID, prepaid),
+			LedgerCredit(LedgerAccountRefundsDue, &booking.RenterID, prepaid-fee),
+			LedgerCredit(LedgerAccountOwnerPayable, &booking.OwnerID, fee-commission),
+			LedgerCredit(LedgerAccountCommission, nil, commission),
+			LedgerDebit(LedgerAccountOwnerPayable, &booking.OwnerID, ownerPenalty),
+			LedgerCredit(LedgerAccountPenalties, nil, ownerPenalty),
+		},
+	})
+}
+
+// postDepositMovement moves part of a paid deposit to the owner (a capture)
+// or back to the renter (a release). Deposits that were never paid through
+// the platform have nothing to move.
+func postDepositMovement(tx *gorm.DB, booking *Booking, entry *DepositTransaction) error {
+	held, err := LedgerBalance(tx, LedgerAccountDepositsHeld, nil, &booking.ID)
+	if err != nil {
+		return err
+	}
+
+	amount := entry.Amount
+	if amount > held {
+		amount = held
+	}
+
+	ledgerEntry := &LedgerEntry{
+		Reference: fmt.Sprintf("deposit:%d", entry.ID),
+		BookingID: &booking.ID,
+		Postings:  []LedgerPosting{LedgerDebit(LedgerAccountDepositsHeld, &booking.RenterID, amount)},
+	}
+
+	if entry.Type == DepositEntryCapture && entry.Reason == DepositReasonShortfall {
+		ledgerEntry.Type = LedgerEntryDepositCaptured
+		ledgerEntry.Description = fmt.Sprintf("Deposit applied to the final price of booking #%d", booking.ID)
+		postings, err := shortfallCollectedPostings(tx, booking, amount)
+		if err != nil {
+			return err
+		}
+		ledgerEntry.Postings = append(ledgerEntry.Postings, postings...)
+	} else if entry.Type == DepositEntryCapture {
+		ledgerEntry.Type = LedgerEntryDepositCaptured
+		ledgerEntry.Description = fmt.Sprintf("Deposit claim on booking #%d for %s", booking.ID, entry.Reason)
+		ledgerEntry.Postings = append(ledgerEntry.Postings, LedgerCredit(LedgerAccountOwnerPayable, &booking.OwnerID, amount))
+	} else {
+		ledgerEntry.Type = LedgerEntryDepositReleased
+		ledgerEntry.Description = fmt.Sprintf("Deposit released on booking #%d", booking.ID)
+		ledgerEntry.Postings = append(ledgerEntry.Postings, LedgerCredit(LedgerAccountRefundsDue, &booking.RenterID, amount))
+	}
+
+	return PostLedgerEntry(tx, ledgerEntry)
+}
+
+func PostPayoutBatched(tx *gorm.DB, payout *Payout) error {
+	return PostLedgerEntry(tx, &LedgerEntry{
+		Reference:   fmt.Sprintf("payout:%d:batched", payout.ID),
+		Type:        LedgerEntryPayoutBatched,
+		Description: fmt.Sprintf("Payout #%d in batch %s", payout.ID, payout.BatchID),
+		Postings: []LedgerPosting{
+			LedgerDebit(LedgerAccountOwnerPayable, &payout.OwnerID, payout.Amount),
+			LedgerCredit(LedgerAccountPayoutsInTransit, &payout.OwnerID, payout.Amount),
+		},
+	})
+}
+
+func PostPayoutPaid(tx *gorm.DB, payout *Payout) error {
+	return PostLedgerEntry(tx, &LedgerEntry{
+		Reference:   fmt.Sprintf("payout:%d:paid", payout.ID),
+		Type:        LedgerEntryPayoutPaid,
+		Description: fmt.Sprintf("Payout #%d paid to %s", payout.ID, payout.Method),
+		Postings: []LedgerPosting{
+			LedgerDebit(LedgerAccountPayoutsInTransit, &payout.OwnerID, payout.Amount),
+			LedgerCredit(LedgerAccountCash, nil, payout.Amount),
+		},
+	})
+}
+
+// PostPayoutFailed returns a failed payout to the owner's balance so it is
+// picked up by the next batch.
+func PostPayoutFailed(tx *gorm.DB, payout *Payout) error {
+	return PostLedgerEntry(tx, &LedgerEntry{
+		Reference:   fmt.Sprintf("payout:%d:failed", payout.ID),
+		Type:        LedgerEntryPayoutFailed,
+		Description: fmt.Sprintf("Payout #%d failed: %s", payout.ID, payout.FailureReason),
+		Postings: []LedgerPosting{
+			LedgerDebit(LedgerAccountPayoutsInTransit, &payout.OwnerID, payout.Amount),
+			LedgerCredit(LedgerAccountOwnerPayable, &payout.OwnerID, payout.Amount),
+		},
+	})
+}
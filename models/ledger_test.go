@@ -0,0 +1,272 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// openTestDB connects to TEST_DATABASE_URL and migrates it, skipping the
+// test when no database is configured.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func createLedgerTestUser(t *testing.T, db *gorm.DB, name string) User {
+	t.Helper()
+
+	suffix := fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+	user := User{
+		Name:          name,
+		Email:         suffix + "@example.com",
+		EmailVerified: true,
+		Password:      "x",
+		Phone:         suffix,
+		StudentID:     suffix,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user %s: %v", name, err)
+	}
+	return user
+}
+
+// createPaidBooking sets up a confirmed booking for 1000 with a 500 deposit
+// and 10% commission, paid in full through the platform.
+func createPaidBooking(t *testing.T, db *gorm.DB) (*Booking, User) {
+	t.Helper()
+
+	owner := createLedgerTestUser(t, db, "owner")
+	renter := createLedgerTestUser(t, db, "renter")
+
+	vehicle := Vehicle{
+		OwnerID:       owner.ID,
+		VehicleType:   "bike",
+		Brand:         "Test",
+		VehicleModel:  "Racer",
+		Year:          2024,
+		VehicleNumber: fmt.Sprintf("TEST-%d", time.Now().UnixNano()),
+		PricePerHour:  100,
+		PricePerKm:    5,
+		PricePerDay:   1000,
+		Location:      "Campus",
+		IsAvailable:   true,
+		IsActive:      true,
+	}
+	if err := db.Create(&vehicle).Error; err != nil {
+		t.Fatalf("create vehicle: %v", err)
+	}
+
+	start := time.Now().Add(-6 * time.Hour)
+	booking := &Booking{
+		VehicleID:         vehicle.ID,
+		RenterID:          renter.ID,
+		OwnerID:           owner.ID,
+		StartTime:         start,
+		EndTime:           start.Add(4 * time.Hour),
+		PickupLocation:    "Gate 1",
+		PricingModel:      PricingModelTime,
+		EstimatedPrice:    1000,
+		SecurityDeposit:   500,
+		CommissionPercent: 10,
+		Status:            BookingStatusConfirmed,
+	}
+	if err := db.Create(booking).Error; err != nil {
+		t.Fatalf("create booking: %v", err)
+	}
+
+	payment := createLedgerTestPayment(t, db, booking, PaymentKindBooking, 1500)
+	if err := PostPaymentCaptured(db, booking, payment, true); err != nil {
+		t.Fatalf("post payment: %v", err)
+	}
+	if err := HoldDeposit(db, booking, nil, BookingActorSystem); err != nil {
+		t.Fatalf("hold deposit: %v", err)
+	}
+
+	return booking, owner
+}
+
+func createLedgerTestPayment(t *testing.T, db *gorm.DB, booking *Booking, kind string, amount int64) *Payment {
+	t.Helper()
+
+	payment := &Payment{
+		BookingID:       booking.ID,
+		UserID:          booking.RenterID,
+		Kind:            kind,
+		Provider:        "mock",
+		ProviderOrderID: fmt.Sprintf("order_test_%d", time.Now().UnixNano()),
+		Amount:          amount,
+		Status:          PaymentStatusCaptured,
+	}
+	if err := db.Create(payment).Error; err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	return payment
+}
+
+func completeLedgerTestBooking(t *testing.T, db *gorm.DB, booking *Booking, price int64) {
+	t.Helper()
+
+	booking.Status = BookingStatusCompleted
+	booking.FinalPrice = price
+	if err := db.Model(booking).Updates(map[string]interface{}{"status": booking.Status, "final_price": price}).Error; err != nil {
+		t.Fatalf("complete booking: %v", err)
+	}
+	if err := PostBookingSettlement(db, booking, price); err != nil {
+		t.Fatalf("post settlement: %v", err)
+	}
+}
+
+func assertLedgerBalances(t *testing.T, db *gorm.DB, userID *uint, bookingID *uint, want map[string]int64) {
+	t.Helper()
+
+	for account, expected := range want {
+		got, err := LedgerBalance(db, account, userID, bookingID)
+		if err != nil {
+			t.Fatalf("balance of %s: %v", account, err)
+		}
+		if got != expected {
+			t.Errorf("%s balance = %d, want %d", account, got, expected)
+		}
+	}
+}
+
+func TestShortfallCollectedAndPaidOutSettlesToZero(t *testing.T) {
+	db := openTestDB(t)
+	booking, owner := createPaidBooking(t, db)
+
+	// Final price 1800 against 1000 prepaid: 800 short, and the owner's
+	// share of it is held back until it is collected.
+	completeLedgerTestBooking(t, db, booking, 1800)
+	assertLedgerBalances(t, db, nil, &booking.ID, map[string]int64{
+		LedgerAccountRenterReceivable:  -800,
+		LedgerAccountOwnerPending:      800,
+		LedgerAccountOwnerPayable:      820,
+		LedgerAccountCommission:        180,
+		LedgerAccountCommissionPending: 0,
+	})
+
+	if err := CollectShortfallFromDeposit(db, booking); err != nil {
+		t.Fatalf("collect from deposit: %v", err)
+	}
+	assertLedgerBalances(t, db, nil, &booking.ID, map[string]int64{
+		LedgerAccountDepositsHeld:     0,
+		LedgerAccountRenterReceivable: -300,
+		LedgerAccountOwnerPending:     300,
+		LedgerAccountOwnerPayable:     1320,
+	})
+
+	topUp := createLedgerTestPayment(t, db, booking, PaymentKindTopUp, 300)
+	if err := PostPaymentCaptured(db, booking, topUp, true); err != nil {
+		t.Fatalf("post top-up: %v", err)
+	}
+
+	payout := &Payout{OwnerID: owner.ID, BatchID: "batch_test", Amount: 1620, Status: PayoutStatusPending}
+	if err := db.Create(payout).Error; err != nil {
+		t.Fatalf("create payout: %v", err)
+	}
+	if err := PostPayoutBatched(db, payout); err != nil {
+		t.Fatalf("post payout batched: %v", err)
+	}
+	if err := PostPayoutPaid(db, payout); err != nil {
+		t.Fatalf("post payout paid: %v", err)
+	}
+
+	assertLedgerBalances(t, db, nil, &booking.ID, map[string]int64{
+		LedgerAccountRenterPrepayments: 0,
+		LedgerAccountDepositsHeld:      0,
+		LedgerAccountRefundsDue:        0,
+		LedgerAccountRenterReceivable:  0,
+		LedgerAccountOwnerPending:      0,
+		LedgerAccountCommissionPending: 0,
+		LedgerAccountCommission:        180,
+		LedgerAccountCash:              -1800,
+	})
+	assertLedgerBalances(t, db, &owner.ID, nil, map[string]int64{
+		LedgerAccountOwnerPayable:     0,
+		LedgerAccountPayoutsInTransit: 0,
+	})
+}
+
+func TestShortfallHeldBackFromOwnerUntilCollected(t *testing.T) {
+	tests := []struct {
+		name       string
+		price      int64
+		ownerPaid  int64
+		ownerHeld  int64
+		commission int64
+		commHeld   int64
+		receivable int64
+		refundsDue int64
+	}{
+		{name: "price below prepayment", price: 800, ownerPaid: 720, commission: 80, refundsDue: 200},
+		{name: "price equals prepayment", price: 1000, ownerPaid: 900, commission: 100},
+		{name: "shortfall within owner share", price: 1200, ownerPaid: 880, ownerHeld: 200, commission: 120, receivable: -200},
+		// The owner's share of 18000 can't cover a 19000 shortfall alone, so
+		// part of the commission is held back too.
+		{name: "shortfall above owner share", price: 20000, ownerHeld: 18000, commission: 1000, commHeld: 1000, receivable: -19000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			booking, _ := createPaidBooking(t, db)
+
+			completeLedgerTestBooking(t, db, booking, tt.price)
+			assertLedgerBalances(t, db, nil, &booking.ID, map[string]int64{
+				LedgerAccountOwnerPayable:      tt.ownerPaid,
+				LedgerAccountOwnerPending:      tt.ownerHeld,
+				LedgerAccountCommission:        tt.commission,
+				LedgerAccountCommissionPending: tt.commHeld,
+				LedgerAccountRenterReceivable:  tt.receivable,
+				LedgerAccountRefundsDue:        tt.refundsDue,
+			})
+		})
+	}
+}
+
+func TestSettlementReversalRefundsCollectedShortfall(t *testing.T) {
+	db := openTestDB(t)
+	booking, _ := createPaidBooking(t, db)
+
+	completeLedgerTestBooking(t, db, booking, 1800)
+	if err := CollectShortfallFromDeposit(db, booking); err != nil {
+		t.Fatalf("collect from deposit: %v", err)
+	}
+
+	if err := PostBookingSettlementReversal(db, booking); err != nil {
+		t.Fatalf("reverse settlement: %v", err)
+	}
+	if err := PostBookingCancellation(db, booking, 0, 0); err != nil {
+		t.Fatalf("post cancellation: %v", err)
+	}
+
+	// The 1000 prepaid and the 500 taken from the deposit all go back.
+	assertLedgerBalances(t, db, nil, &booking.ID, map[string]int64{
+		LedgerAccountRenterPrepayments: 0,
+		LedgerAccountDepositsHeld:      0,
+		LedgerAccountRenterReceivable:  0,
+		LedgerAccountOwnerPending:      0,
+		LedgerAccountCommissionPending: 0,
+		LedgerAccountOwnerPayable:      0,
+		LedgerAccountCommission:        0,
+		LedgerAccountRefundsDue:        1500,
+	})
+}
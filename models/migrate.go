@@ -0,0 +1,62 @@
+package models
+
+import "gorm.io/gorm"
+
+// Migrate brings the schema up to date and runs the one-off data changes
+// that go with it.
+func Migrate(db *gorm.DB) error {
+	// Accounts created before email verification existed never got a
+	// verification email, so treat them as verified rather than locking
+	// them out of booking. This only runs when the column is first added.
+	backfillEmailVerified := db.Migrator().HasTable(&User{}) && !db.Migrator().HasColumn(&User{}, "email_verified")
+
+	if err := db.AutoMigrate(
+		&User{},
+		&Vehicle{},
+		&Availability{},
+		&Booking{},
+		&OBDTracker{},
+		&OBDReading{},
+		&Document{},
+		&Session{},
+		&RefreshToken{},
+		&ActionToken{},
+		&OTPCode{},
+		&AuditLog{},
+		&Notification{},
+		&BookingEvent{},
+		&BookingAmendment{},
+		&PricingRule{},
+		&PromoCode{},
+		&PromoRedemption{},
+		&CreditTransaction{},
+		&DepositTransaction{},
+		&Payment{},
+		&PaymentRefund{},
+		&LedgerEntry{},
+		&LedgerPosting{},
+		&Payout{},
+		&Invoice{},
+		&InvoiceSequence{},
+	); err != nil {
+		return err
+	}
+
+	if backfillEmailVerified {
+		if err := db.Exec("UPDATE users SET email_verified = true, email_verified_at = created_at WHERE email_verified = false").Error; err != nil {
+			return err
+		}
+	}
+
+	// OTPs used to be stored in plaintext on the booking; they now live
+	// hashed in otp_codes, so drop the old columns.
+	for _, column := range []string{"pickup_otp", "return_otp"} {
+		if db.Migrator().HasColumn(&Booking{}, column) {
+			if err := db.Migrator().DropColumn(&Booking{}, column); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
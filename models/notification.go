@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Notification struct {
+	gorm.Model
+	UserID uint `json:"user_id" gorm:"not null;index"`
+	User   User `json:"-" gorm:"foreignKey:UserID"`
+
+	Kind  string `json:"kind"`
+	Title string `json:"title"`
+	Body  string `json:"body" gorm:"type:text"`
+
+	ReadAt *time.Time `json:"read_at,omitempty"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OTPPurposePickup = "pickup"
+	OTPPurposeReturn = "return"
+)
+
+type OTPCode struct {
+	gorm.Model
+	BookingID uint   `json:"booking_id" gorm:"not null;uniqueIndex:idx_otp_booking_purpose"`
+	Purpose   string `json:"purpose" gorm:"not null;uniqueIndex:idx_otp_booking_purpose"`
+
+	CodeHash   string     `json:"-"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty"`
+
+	Attempts    int        `json:"attempts" gorm:"default:0"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+
+	IssueCount   int        `json:"issue_count" gorm:"default:0"`
+	LastIssuedAt *time.Time `json:"last_issued_at,omitempty"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PaymentStatusCreated           = "created"
+	PaymentStatusPending           = "pending"
+	PaymentStatusCaptured          = "captured"
+	PaymentStatusFailed            = "failed"
+	PaymentStatusRefunded          = "refunded"
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+
+	PaymentKindBooking = "booking"
+	PaymentKindTopUp   = "top_up"
+
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+)
+
+// Payment is one attempt to collect a booking's price and security deposit
+// through a payment provider. A booking can have several failed attempts
+// but at most one captured booking payment. Top-up payments collect the
+// difference when an approved change raises the price of a paid booking.
+// RefundedAmount includes refunds still pending with the provider.
+type Payment struct {
+	gorm.Model
+	BookingID uint `json:"booking_id" gorm:"not null;index"`
+	UserID    uint `json:"user_id" gorm:"not null;index"`
+
+	Kind              string `json:"kind" gorm:"not null;default:'booking'"`
+	Provider          string `json:"provider" gorm:"not null"`
+	ProviderOrderID   string `json:"provider_order_id" gorm:"uniqueIndex;not null"`
+	ProviderPaymentID string `json:"provider_payment_id"`
+
+	Amount         int64  `json:"amount"`
+	Currency       string `json:"currency"`
+	Status         string `json:"status" gorm:"not null;index"`
+	FailureReason  string `json:"failure_reason"`
+	RefundedAmount int64  `json:"refunded_amount"`
+
+	CapturedAt *time.Time `json:"captured_at,omitempty"`
+	FailedAt   *time.Time `json:"failed_at,omitempty"`
+
+	Refunds []PaymentRefund `json:"refunds,omitempty" gorm:"foreignKey:PaymentID"`
+}
+
+// PaymentRefund is recorded as pending before the provider is asked for
+// the money back, and IdempotencyKey is sent with every attempt so a retry
+// after a crash or timeout can't refund twice.
+type PaymentRefund struct {
+	gorm.Model
+	PaymentID uint `json:"payment_id" gorm:"not null;index"`
+	BookingID uint `json:"booking_id" gorm:"not null;index"`
+
+	ProviderRefundID string `json:"provider_refund_id"`
+	IdempotencyKey   string `json:"-" gorm:"index"`
+	Amount           int64  `json:"amount"`
+	Reason           string `json:"reason"`
+	Status           string `json:"status" gorm:"not null;default:'succeeded';index"`
+	FailureReason    string `json:"failure_reason"`
+}
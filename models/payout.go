@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PayoutStatusPending = "pending"
+	PayoutStatusPaid    = "paid"
+	PayoutStatusFailed  = "failed"
+
+	PayoutMethodUPI  = "upi"
+	PayoutMethodBank = "bank_transfer"
+)
+
+// Payout is an owner's balance swept into a payout batch. The destination is
+// copied from the owner's UPI ID or bank account when the batch is made.
+type Payout struct {
+	gorm.Model
+	OwnerID uint   `json:"owner_id" gorm:"not null;index"`
+	BatchID string `json:"batch_id" gorm:"not null;index"`
+
+	Amount      int64  `json:"amount"`
+	Method      string `json:"method"`
+	Destination string `json:"destination"`
+
+	Status        string     `json:"status" gorm:"not null;index"`
+	Reference     string     `json:"reference"`
+	FailureReason string     `json:"failure_reason"`
+	PaidAt        *time.Time `json:"paid_at,omitempty"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+const (
+	PriceLineItemBase           = "base"
+	PriceLineItemDistance       = "distance"
+	PriceLineItemTime           = "time"
+	PriceLineItemDays           = "days"
+	PriceLineItemWeeklyDiscount = "weekly_discount"
+	PriceLineItemEstimate       = "estimate"
+	PriceLineItemFuel           = "fuel"
+	PriceLineItemLateFee        = "late_fee"
+	PriceLineItemPricingRule    = "pricing_rule"
+	PriceLineItemPromo          = "promo"
+	PriceLineItemCredits        = "credits"
+)
+
+type PriceLineItem struct {
+	Code        string  `json:"code"`
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity,omitempty"`
+	Unit        string  `json:"unit,omitempty"`
+	UnitPrice   int64   `json:"unit_price,omitempty"`
+	Amount      int64   `json:"amount"`
+	RuleID      uint    `json:"rule_id,omitempty"`
+}
+
+// PriceQuote is an itemized price. It is stored on bookings as JSON so the
+// breakdown shown to the renter stays the one the price was computed from.
+type PriceQuote struct {
+	PricingModel string          `json:"pricing_model"`
+	LineItems    []PriceLineItem `json:"line_items"`
+	Total        int64           `json:"total"`
+}
+
+func (q *PriceQuote) Add(item PriceLineItem) {
+	q.LineItems = append(q.LineItems, item)
+	q.Total += item.Amount
+}
+
+func (q PriceQuote) Value() (driver.Value, error) {
+	b, err := json.Marshal(q)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (q *PriceQuote) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*q = PriceQuote{}
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), q)
+	case []byte:
+		return json.Unmarshal(v, q)
+	default:
+		return errors.New("unsupported type for PriceQuote")
+	}
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PricingRuleWeekend    = "weekend"
+	PricingRuleDateRange  = "date_range"
+	PricingRuleTimeOfDay  = "time_of_day"
+	PricingRuleEarlyBird  = "early_bird"
+	PricingRuleLastMinute = "last_minute"
+)
+
+// PricingRule adjusts a vehicle's price by AdjustmentPercent (negative for
+// discounts) for the part of a rental it covers. Which fields matter depends
+// on Type: StartsAt/EndsAt for date ranges such as exam weeks or festivals,
+// StartHour/EndHour for times of day, LeadHours for early-bird and
+// last-minute bookings.
+type PricingRule struct {
+	gorm.Model
+	VehicleID uint    `json:"vehicle_id" gorm:"not null;index"`
+	Vehicle   Vehicle `json:"-" gorm:"foreignKey:VehicleID"`
+
+	Name              string `json:"name" gorm:"not null"`
+	Type              string `json:"type" gorm:"not null"`
+	AdjustmentPercent int    `json:"adjustment_percent"`
+
+	StartsAt  *time.Time `json:"starts_at,omitempty"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	StartHour int        `json:"start_hour"`
+	EndHour   int        `json:"end_hour"`
+	LeadHours int        `json:"lead_hours"`
+
+	IsActive bool `json:"is_active" gorm:"default:true"`
+}
+
+// PricingRules is the set of rules captured on a booking when it is made, so
+// the final price uses the rules the renter was quoted.
+type PricingRules []PricingRule
+
+func (r PricingRules) Value() (driver.Value, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (r *PricingRules) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	case []byte:
+		return json.Unmarshal(v, r)
+	default:
+		return errors.New("unsupported type for PricingRules")
+	}
+}
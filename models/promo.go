@@ -0,0 +1,141 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PromoDiscountPercent = "percent"
+	PromoDiscountFlat    = "flat"
+
+	PromoRedemptionApplied  = "applied"
+	PromoRedemptionReversed = "reversed"
+)
+
+type PromoCode struct {
+	gorm.Model
+	Code        string `json:"code" gorm:"uniqueIndex;not null"`
+	Description string `json:"description"`
+
+	DiscountType     string `json:"discount_type" gorm:"not null"`
+	DiscountValue    int64  `json:"discount_value"`
+	MaxDiscount      int64  `json:"max_discount"`
+	MinBookingAmount int64  `json:"min_booking_amount"`
+
+	UsageLimit   int `json:"usage_limit"`
+	PerUserLimit int `json:"per_user_limit" gorm:"default:1"`
+	UsedCount    int `json:"used_count" gorm:"default:0"`
+
+	FirstRideOnly bool   `json:"first_ride_only" gorm:"default:false"`
+	Department    string `json:"department"`
+
+	StartsAt  *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	IsActive  bool       `json:"is_active" gorm:"default:true"`
+
+	CreatedByID uint `json:"created_by_id"`
+}
+
+type PromoRedemption struct {
+	gorm.Model
+	PromoCodeID uint `json:"promo_code_id" gorm:"not null;index"`
+	UserID      uint `json:"user_id" gorm:"not null;index"`
+	BookingID   uint `json:"booking_id" gorm:"not null;index"`
+
+	Code           string     `json:"code"`
+	DiscountAmount int64      `json:"discount_amount"`
+	Status         string     `json:"status" gorm:"default:'applied'"`
+	ReversedAt     *time.Time `json:"reversed_at,omitempty"`
+}
+
+// ReleaseBookingDiscounts gives back what a booking that never happened
+// used: the promo redemption is reversed so it no longer counts towards the
+// code's limits, and credits spent on it are returned to the renter, less
+// any ReturnUnusedCredits already gave back when it completed. It is meant
+// to run in the same transaction as the cancel or expire transition, which
+// only succeeds once per booking.
+func ReleaseBookingDiscounts(tx *gorm.DB, booking *Booking) error {
+	if booking.PromoCodeID != nil {
+		now := time.Now()
+		result := tx.Model(&PromoRedemption{}).
+			Where("booking_id = ? AND status = ?", booking.ID, PromoRedemptionApplied).
+			Updates(map[string]interface{}{
+				"status":      PromoRedemptionReversed,
+				"reversed_at": &now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			if err := tx.Model(&PromoCode{}).
+				Where("id = ? AND used_count > 0", *booking.PromoCodeID).
+				Update("used_count", gorm.Expr("used_count - 1")).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	if booking.CreditsApplied <= 0 {
+		return nil
+	}
+
+	var returned int64
+	if err := tx.Model(&CreditTransaction{}).
+		Where("booking_id = ? AND type = ?", booking.ID, CreditTypeBookingRefund).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&returned).Error; err != nil {
+		return err
+	}
+	if returned >= booking.CreditsApplied {
+		return nil
+	}
+
+	return AdjustCredit(tx, &CreditTransaction{
+		UserID:    booking.RenterID,
+		Type:      CreditTypeBookingRefund,
+		Amount:    booking.CreditsApplied - returned,
+		BookingID: &booking.ID,
+		Note:      "credits returned for booking that did not go ahead",
+	})
+}
+
+// ReturnUnusedCredits gives back the part of a booking's credits that its
+// final quote didn't use because the price came in below them. Credits are
+// returned at most once per booking.
+func ReturnUnusedCredits(tx *gorm.DB, booking *Booking, quote *PriceQuote) error {
+	if booking.CreditsApplied <= 0 || quote == nil {
+		return nil
+	}
+
+	var used int64
+	for _, item := range quote.LineItems {
+		if item.Code == PriceLineItemCredits {
+			used -= item.Amount
+		}
+	}
+	unused := booking.CreditsApplied - used
+	if unused <= 0 {
+		return nil
+	}
+
+	var returned int64
+	if err := tx.Model(&CreditTransaction{}).
+		Where("booking_id = ? AND type = ?", booking.ID, CreditTypeBookingRefund).
+		Count(&returned).Error; err != nil {
+		return err
+	}
+	if returned > 0 {
+		return nil
+	}
+
+	return AdjustCredit(tx, &CreditTransaction{
+		UserID:    booking.RenterID,
+		Type:      CreditTypeBookingRefund,
+		Amount:    unused,
+		BookingID: &booking.ID,
+		Note:      fmt.Sprintf("unused credits returned for booking #%d", booking.ID),
+	})
+}
@@ -0,0 +1,51 @@
+package models
+
+import "testing"
+
+func TestReleaseBookingDiscountsReturnsCreditsOnce(t *testing.T) {
+	tests := []struct {
+		name        string
+		creditsUsed *int64
+		want        int64
+	}{
+		{name: "cancelled before completion", want: 300},
+		{name: "cancelled after completion used part of the credits", creditsUsed: int64Ptr(100), want: 300},
+		{name: "cancelled after completion used all the credits", creditsUsed: int64Ptr(300), want: 300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			booking, _ := createPaidBooking(t, db)
+
+			booking.CreditsApplied = 300
+			if err := db.Model(booking).Update("credits_applied", booking.CreditsApplied).Error; err != nil {
+				t.Fatalf("apply credits: %v", err)
+			}
+
+			if tt.creditsUsed != nil {
+				quote := &PriceQuote{}
+				quote.Add(PriceLineItem{Code: PriceLineItemCredits, Amount: -*tt.creditsUsed})
+				if err := ReturnUnusedCredits(db, booking, quote); err != nil {
+					t.Fatalf("return unused credits: %v", err)
+				}
+			}
+
+			if err := ReleaseBookingDiscounts(db, booking); err != nil {
+				t.Fatalf("release discounts: %v", err)
+			}
+
+			var renter User
+			if err := db.First(&renter, booking.RenterID).Error; err != nil {
+				t.Fatalf("load renter: %v", err)
+			}
+			if renter.CreditBalance != tt.want {
+				t.Errorf("credit balance = %d, want %d", renter.CreditBalance, tt.want)
+			}
+		})
+	}
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Session struct {
+	gorm.Model
+	UserID uint `json:"user_id" gorm:"not null;index"`
+	User   User `json:"-" gorm:"foreignKey:UserID"`
+
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason string     `json:"revoked_reason"`
+}
+
+type RefreshToken struct {
+	gorm.Model
+	SessionID uint    `json:"session_id" gorm:"not null;index"`
+	Session   Session `json:"-" gorm:"foreignKey:SessionID"`
+
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
@@ -17,46 +17,59 @@ type Vehicle struct {
 	Year          int    `json:"year"`
 	Color         string `json:"color"`
 	VehicleNumber string `json:"vehicle_number" gorm:"unique;not null"`
-	
-	PricePerKm    int64 `json:"price_per_km"`
-	PricePerHour  int64 `json:"price_per_hour"`
-	PricePerDay   int64 `json:"price_per_day"`
-	BasePrice     int64 `json:"base_price"`
-	MinRentalHours int  `json:"min_rental_hours" gorm:"default:1"`
-	MaxRentalDays  int  `json:"max_rental_days" gorm:"default:7"`
-	
-	HasHelmet     bool    `json:"has_helmet"`
-	FuelType      string  `json:"fuel_type"`
-	Transmission  string  `json:"transmission"`
-	Mileage       float64 `json:"mileage"`
-	SeatingCapacity int   `json:"seating_capacity"`
-	
-	RCDocument      string     `json:"rc_document"`
-	RCNumber        string     `json:"rc_number"`
-	RCVerified      bool       `json:"rc_verified" gorm:"default:false"`
-	
-	Insurance       string     `json:"insurance"`
-	InsuranceNumber string     `json:"insurance_number"`
-	InsuranceExpiry *time.Time `json:"insurance_expiry,omitempty"`
-	InsuranceVerified bool     `json:"insurance_verified" gorm:"default:false"`
-	
-	PUCDocument     string     `json:"puc_document"`
-	PUCExpiry       *time.Time `json:"puc_expiry,omitempty"`
-	PUCVerified     bool       `json:"puc_verified" gorm:"default:false"`
-	
-	IsVerified      bool       `json:"is_verified" gorm:"default:false"`
-	VerifiedAt      *time.Time `json:"verified_at,omitempty"`
-	
-	Location      string  `json:"location"`
-	Latitude      float64 `json:"latitude"`
-	Longitude     float64 `json:"longitude"`
-	
-	HasOBDTracker bool   `json:"has_obd_tracker" gorm:"default:false"`
-	OBDTrackerID  *uint  `json:"obd_tracker_id"`
-	
-	IsAvailable   bool    `json:"is_available" gorm:"default:true"`
-	IsActive      bool    `json:"is_active" gorm:"default:true"`
-	
+
+	PricePerKm               int64  `json:"price_per_km"`
+	PricePerHour             int64  `json:"price_per_hour"`
+	PricePerDay              int64  `json:"price_per_day"`
+	BasePrice                int64  `json:"base_price"`
+	WeeklyDiscountPercent    int    `json:"weekly_discount_percent"`
+	MinRentalHours           int    `json:"min_rental_hours"`
+	MaxRentalDays            int    `json:"max_rental_days"`
+	CancellationPolicy       string `json:"cancellation_policy" gorm:"default:'moderate'"`
+	RequireAmendmentApproval bool   `json:"require_amendment_approval" gorm:"default:true"`
+	LateGraceMinutes         int    `json:"late_grace_minutes" gorm:"default:30"`
+	LateFeePerHour           int64  `json:"late_fee_per_hour"`
+	BufferMinutes            int    `json:"buffer_minutes"`
+	MinNoticeHours           int    `json:"min_notice_hours"`
+	MaxAdvanceDays           int    `json:"max_advance_days"`
+
+	HasHelmet          bool    `json:"has_helmet"`
+	RequireHelmet      bool    `json:"require_helmet" gorm:"default:false"`
+	AllowedRenterYears string  `json:"allowed_renter_years"`
+	GenderRestriction  string  `json:"gender_restriction"`
+	FuelType           string  `json:"fuel_type"`
+	Transmission       string  `json:"transmission"`
+	Mileage            float64 `json:"mileage"`
+	TankCapacityLiters float64 `json:"tank_capacity_liters"`
+	FuelPolicy         string  `json:"fuel_policy" gorm:"default:'same_level'"`
+	SeatingCapacity    int     `json:"seating_capacity"`
+
+	RCDocument string `json:"rc_document"`
+	RCNumber   string `json:"rc_number"`
+	RCVerified bool   `json:"rc_verified" gorm:"default:false"`
+
+	Insurance         string     `json:"insurance"`
+	InsuranceNumber   string     `json:"insurance_number"`
+	InsuranceExpiry   *time.Time `json:"insurance_expiry,omitempty"`
+	InsuranceVerified bool       `json:"insurance_verified" gorm:"default:false"`
+
+	PUCDocument string     `json:"puc_document"`
+	PUCExpiry   *time.Time `json:"puc_expiry,omitempty"`
+	PUCVerified bool       `json:"puc_verified" gorm:"default:false"`
+
+	IsVerified bool       `json:"is_verified" gorm:"default:false"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+
+	Location  string  `json:"location"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+
+	HasOBDTracker bool  `json:"has_obd_tracker" gorm:"default:false"`
+	OBDTrackerID  *uint `json:"obd_tracker_id"`
+
+	IsAvailable bool `json:"is_available" gorm:"default:true"`
+	IsActive    bool `json:"is_active" gorm:"default:true"`
+
 	Rating        float64 `json:"rating" gorm:"default:0"`
 	TotalBookings int     `json:"total_bookings" gorm:"default:0"`
 	TotalKmDriven int     `json:"total_km_driven" gorm:"default:0"`
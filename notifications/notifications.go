@@ -0,0 +1,164 @@
+package notifications
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"proj/config"
+	"proj/mailer"
+	"proj/models"
+	"proj/utils"
+)
+
+const (
+	KindOTP      = "otp"
+	KindBooking  = "booking"
+	KindSecurity = "security"
+	KindPayout   = "payout"
+)
+
+type Notification struct {
+	Kind  string
+	Title string
+	Body  string
+
+	// Sensitive notifications (OTPs and the like) are never persisted, so
+	// channels that store messages skip them.
+	Sensitive bool
+}
+
+type Channel interface {
+	Name() string
+	Send(user *models.User, n Notification) error
+}
+
+var ErrNoChannelDelivered = errors.New("notification could not be delivered on any channel")
+
+type Notifier struct {
+	Channels []Channel
+}
+
+var Default = &Notifier{
+	Channels: []Channel{InAppChannel{}, EmailChannel{}, SMSChannel{Sender: LogSMSSender{}}},
+}
+
+// Notify loads the user and sends the notification on every channel that
+// accepts it. It returns the names of the channels that delivered it and only
+// fails when none did.
+func (n *Notifier) Notify(userID uint, notification Notification) ([]string, error) {
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	var delivered []string
+	for _, channel := range n.Channels {
+		err := channel.Send(&user, notification)
+		if errors.Is(err, errSkipped) {
+			continue
+		}
+		if err != nil {
+			log.Printf("notification %q to user %d failed on %s: %v", notification.Title, userID, channel.Name(), err)
+			continue
+		}
+		delivered = append(delivered, channel.Name())
+	}
+
+	if len(delivered) == 0 {
+		return nil, ErrNoChannelDelivered
+	}
+
+	return delivered, nil
+}
+
+func Notify(userID uint, notification Notification) ([]string, error) {
+	return Default.Notify(userID, notification)
+}
+
+var errSkipped = errors.New("channel skipped")
+
+type InAppChannel struct{}
+
+func (InAppChannel) Name() string { return "in_app" }
+
+func (InAppChannel) Send(user *models.User, n Notification) error {
+	if n.Sensitive {
+		return errSkipped
+	}
+
+	return config.DB.Create(&models.Notification{
+		UserID: user.ID,
+		Kind:   n.Kind,
+		Title:  n.Title,
+		Body:   n.Body,
+	}).Error
+}
+
+type EmailChannel struct{}
+
+func (EmailChannel) Name() string { return "email" }
+
+func (EmailChannel) Send(user *models.User, n Notification) error {
+	if user.Email == "" {
+		return errSkipped
+	}
+
+	return mailer.Send(mailer.Message{
+		To:        user.Email,
+		Subject:   n.Title,
+		Body:      n.Body,
+		Sensitive: n.Sensitive,
+	})
+}
+
+type SMS struct {
+	Phone   string
+	Message string
+
+	// Sensitive messages carry a secret and must not be logged.
+	Sensitive bool
+}
+
+type SMSSender interface {
+	SendSMS(sms SMS) error
+}
+
+// LogSMSSender stands in for an SMS gateway during local development. It
+// never logs the content of sensitive messages.
+type LogSMSSender struct{}
+
+func (LogSMSSender) SendSMS(sms SMS) error {
+	if sms.Sensitive {
+		log.Printf("[sms] to=%s (sensitive message not logged)", maskPhone(sms.Phone))
+		return nil
+	}
+	log.Printf("[sms] to=%s %s", maskPhone(sms.Phone), sms.Message)
+	return nil
+}
+
+type SMSChannel struct {
+	Sender SMSSender
+}
+
+func (SMSChannel) Name() string { return "sms" }
+
+func (ch SMSChannel) Send(user *models.User, n Notification) error {
+	if user.Phone == "" || ch.Sender == nil {
+		return errSkipped
+	}
+
+	phone, err := utils.DecryptString(user.Phone)
+	if err != nil {
+		return err
+	}
+
+	return ch.Sender.SendSMS(SMS{Phone: phone, Message: n.Title + ": " + n.Body, Sensitive: n.Sensitive})
+}
+
+func maskPhone(phone string) string {
+	if len(phone) <= 4 {
+		return phone
+	}
+	return strings.Repeat("*", len(phone)-4) + phone[len(phone)-4:]
+}
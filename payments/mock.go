@@ -0,0 +1,226 @@
+package payments
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	SimulateSuccess      = "success"
+	SimulateFailure      = "failure"
+	SimulateAsync        = "async"
+	SimulateAsyncFailure = "async_failure"
+)
+
+type mockOrder struct {
+	order     Order
+	simulate  string
+	paymentID string
+	captured  int64
+	refunded  int64
+}
+
+// MockProvider simulates a gateway in memory. Orders succeed or fail on
+// capture depending on OrderRequest.Simulate; the async modes answer capture
+// with pending and deliver the outcome to WebhookURL after AsyncDelay,
+// signed the same way VerifyWebhook expects.
+type MockProvider struct {
+	Secret     string
+	WebhookURL string
+	AsyncDelay time.Duration
+
+	mu       sync.Mutex
+	orders   map[string]*mockOrder
+	payments map[string]*mockOrder
+	refunds  map[string]*Refund
+}
+
+func NewMockProvider(secret string, webhookURL string, asyncDelay time.Duration) *MockProvider {
+	return &MockProvider{
+		Secret:     secret,
+		WebhookURL: webhookURL,
+		AsyncDelay: asyncDelay,
+		orders:     make(map[string]*mockOrder),
+		payments:   make(map[string]*mockOrder),
+		refunds:    make(map[string]*Refund),
+	}
+}
+
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+func (p *MockProvider) CreateOrder(req OrderRequest) (*Order, error) {
+	simulate := req.Simulate
+	switch simulate {
+	case SimulateSuccess, SimulateFailure, SimulateAsync, SimulateAsyncFailure:
+	default:
+		simulate = SimulateSuccess
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = Currency
+	}
+
+	order := Order{
+		ID:       "order_mock_" + randomID(),
+		Amount:   req.Amount,
+		Currency: currency,
+		Status:   StatusCreated,
+	}
+
+	p.mu.Lock()
+	p.orders[order.ID] = &mockOrder{order: order, simulate: simulate}
+	p.mu.Unlock()
+
+	return &order, nil
+}
+
+func (p *MockProvider) Capture(orderID string) (*CaptureResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	o, exists := p.orders[orderID]
+	if !exists {
+		return nil, ErrOrderNotFound
+	}
+
+	if o.paymentID == "" {
+		o.paymentID = "pay_mock_" + randomID()
+		p.payments[o.paymentID] = o
+	}
+
+	result := &CaptureResult{OrderID: orderID, PaymentID: o.paymentID}
+
+	switch o.simulate {
+	case SimulateFailure:
+		o.order.Status = StatusFailed
+		result.Status = StatusFailed
+		result.FailureReason = "card declined (simulated)"
+	case SimulateAsync, SimulateAsyncFailure:
+		o.order.Status = StatusPending
+		result.Status = StatusPending
+		go p.sendWebhook(o.order, o.paymentID, o.simulate == SimulateAsync)
+	default:
+		o.order.Status = StatusCaptured
+		o.captured = o.order.Amount
+		result.Status = StatusCaptured
+		result.Amount = o.captured
+	}
+
+	return result, nil
+}
+
+func (p *MockProvider) Refund(paymentID string, amount int64, reason string, idempotencyKey string) (*Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if refund, exists := p.refunds[idempotencyKey]; exists && idempotencyKey != "" {
+		return refund, nil
+	}
+
+	o, exists := p.payments[paymentID]
+	if !exists {
+		return nil, ErrOrderNotFound
+	}
+
+	if amount <= 0 || o.refunded+amount > o.captured {
+		return nil, ErrRefundTooLarge
+	}
+
+	o.refunded += amount
+	refund := &Refund{ID: "rfnd_mock_" + randomID(), Amount: amount, Status: StatusRefunded}
+	if idempotencyKey != "" {
+		p.refunds[idempotencyKey] = refund
+	}
+	return refund, nil
+}
+
+func (p *MockProvider) VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error) {
+	if !hmac.Equal([]byte(p.sign(payload)), []byte(signature)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (p *MockProvider) sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(p.Secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *MockProvider) sendWebhook(order Order, paymentID string, succeed bool) {
+	time.Sleep(p.AsyncDelay)
+
+	event := WebhookEvent{
+		Type:      EventPaymentCaptured,
+		OrderID:   order.ID,
+		PaymentID: paymentID,
+		Amount:    order.Amount,
+	}
+
+	p.mu.Lock()
+	if o, exists := p.orders[order.ID]; exists {
+		if succeed {
+			o.order.Status = StatusCaptured
+			o.captured = o.order.Amount
+		} else {
+			o.order.Status = StatusFailed
+			event.Type = EventPaymentFailed
+			event.FailureReason = "payment declined by bank (simulated)"
+		}
+	}
+	p.mu.Unlock()
+
+	if p.WebhookURL == "" {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[payments] failed to encode mock webhook: %v", err)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("[payments] failed to build mock webhook: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, p.sign(payload))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("[payments] mock webhook for %s failed: %v", order.ID, err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Printf("[payments] mock webhook for %s returned %d", order.ID, resp.StatusCode)
+	}
+}
+
+func randomID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,98 @@
+package payments
+
+import (
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"proj/config"
+)
+
+const Currency = "INR"
+
+// SignatureHeader carries the webhook signature.
+const SignatureHeader = "X-Payment-Signature"
+
+const (
+	StatusCreated  = "created"
+	StatusPending  = "pending"
+	StatusCaptured = "captured"
+	StatusFailed   = "failed"
+	StatusRefunded = "refunded"
+
+	EventPaymentCaptured = "payment.captured"
+	EventPaymentFailed   = "payment.failed"
+)
+
+var (
+	ErrOrderNotFound    = errors.New("payment order not found")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrRefundTooLarge   = errors.New("refund exceeds captured amount")
+)
+
+type OrderRequest struct {
+	BookingID uint
+	Amount    int64
+	Currency  string
+	Receipt   string
+	// Simulate is only honoured by the mock provider: success (default),
+	// failure, async or async_failure.
+	Simulate string
+}
+
+type Order struct {
+	ID       string `json:"id"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	Status   string `json:"status"`
+}
+
+// CaptureResult is the outcome of capturing an order. Status is pending when
+// the provider will report the outcome later through a webhook.
+type CaptureResult struct {
+	OrderID       string `json:"order_id"`
+	PaymentID     string `json:"payment_id"`
+	Status        string `json:"status"`
+	Amount        int64  `json:"amount"`
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+type Refund struct {
+	ID     string `json:"id"`
+	Amount int64  `json:"amount"`
+	Status string `json:"status"`
+}
+
+type WebhookEvent struct {
+	Type          string `json:"type"`
+	OrderID       string `json:"order_id"`
+	PaymentID     string `json:"payment_id"`
+	Amount        int64  `json:"amount"`
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+// Provider is a payment gateway. Amounts are in whole rupees like every other
+// price in the system. Refunds repeated with the same idempotency key return
+// the original refund instead of paying out again.
+type Provider interface {
+	Name() string
+	CreateOrder(req OrderRequest) (*Order, error)
+	Capture(orderID string) (*CaptureResult, error)
+	Refund(paymentID string, amount int64, reason string, idempotencyKey string) (*Refund, error)
+	VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error)
+}
+
+var Default Provider = NewMockProvider(config.GetPaymentWebhookSecret(), "", 5*time.Second)
+
+// Init picks the payment provider for this process from PAYMENT_PROVIDER.
+// Only the mock provider ships with the app; its async webhooks are sent to
+// APP_BASE_URL.
+func Init() {
+	provider := os.Getenv("PAYMENT_PROVIDER")
+	if provider != "" && provider != "mock" {
+		log.Printf("unknown PAYMENT_PROVIDER %q, using mock", provider)
+	}
+
+	Default = NewMockProvider(config.GetPaymentWebhookSecret(), config.GetAppBaseURL()+"/api/payments/webhook", 5*time.Second)
+}
@@ -0,0 +1,168 @@
+package payments
+
+import (
+	"fmt"
+
+	"proj/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefundBooking pays out whatever the ledger says is owed back to the renter
+// of a booking, through the providers of its captured payments, oldest
+// first. Bookings with nothing owed or that were never paid are skipped and
+// return no refunds, so it is safe to call after every change that may owe
+// the renter money.
+//
+// The refunds are recorded as pending in one transaction and only sent to
+// the provider after it commits, so no provider call is made while rows are
+// locked. Pending refunds left behind by an earlier call that never finished
+// are sent again first, under their original idempotency key.
+func RefundBooking(db *gorm.DB, bookingID uint, reason string) ([]*models.PaymentRefund, error) {
+	var pending []*models.PaymentRefund
+	if err := db.Where("booking_id = ? AND status = ?", bookingID, models.RefundStatusPending).
+		Order("id ASC").
+		Find(&pending).Error; err != nil {
+		return nil, err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var captured []models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("booking_id = ? AND status IN ?", bookingID, []string{models.PaymentStatusCaptured, models.PaymentStatusPartiallyRefunded}).
+			Order("id ASC").
+			Find(&captured).Error; err != nil {
+			return err
+		}
+
+		due, err := models.LedgerBalance(tx, models.LedgerAccountRefundsDue, nil, &bookingID)
+		if err != nil {
+			return err
+		}
+
+		for i := range captured {
+			if due <= 0 {
+				break
+			}
+			refund, err := reserveRefund(tx, &captured[i], due, reason)
+			if err != nil {
+				return err
+			}
+			if refund != nil {
+				due -= refund.Amount
+				pending = append(pending, refund)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var refunds []*models.PaymentRefund
+	for _, refund := range pending {
+		if err := sendRefund(db, refund); err != nil {
+			return refunds, err
+		}
+		refunds = append(refunds, refund)
+	}
+
+	return refunds, nil
+}
+
+// reserveRefund records a pending refund of up to amount of one captured
+// payment and takes it out of refunds_due, so a concurrent RefundBooking
+// can't claim the same money. The payment row must already be locked by tx.
+func reserveRefund(tx *gorm.DB, payment *models.Payment, amount int64, reason string) (*models.PaymentRefund, error) {
+	if remaining := payment.Amount - payment.RefundedAmount; amount > remaining {
+		amount = remaining
+	}
+	if amount <= 0 || payment.ProviderPaymentID == "" {
+		return nil, nil
+	}
+
+	payment.RefundedAmount += amount
+	if err := tx.Model(payment).Update("refunded_amount", payment.RefundedAmount).Error; err != nil {
+		return nil, err
+	}
+
+	refund := &models.PaymentRefund{
+		PaymentID: payment.ID,
+		BookingID: payment.BookingID,
+		Amount:    amount,
+		Reason:    reason,
+		Status:    models.RefundStatusPending,
+	}
+	if err := tx.Create(refund).Error; err != nil {
+		return nil, err
+	}
+
+	refund.IdempotencyKey = fmt.Sprintf("refund-%d", refund.ID)
+	if err := tx.Model(refund).Update("idempotency_key", refund.IdempotencyKey).Error; err != nil {
+		return nil, err
+	}
+
+	if err := models.PostRefund(tx, payment, refund); err != nil {
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// sendRefund asks the provider for a pending refund and settles the refund
+// row with the answer. A refund the provider turns down is marked failed and
+// its amount goes back into refunds_due.
+func sendRefund(db *gorm.DB, refund *models.PaymentRefund) error {
+	var payment models.Payment
+	if err := db.First(&payment, refund.PaymentID).Error; err != nil {
+		return err
+	}
+
+	result, refundErr := Default.Refund(payment.ProviderPaymentID, refund.Amount, refund.Reason, refund.IdempotencyKey)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, refund.PaymentID).Error; err != nil {
+			return err
+		}
+
+		// Another caller may have settled the same pending refund already.
+		if err := tx.First(refund, refund.ID).Error; err != nil {
+			return err
+		}
+		if refund.Status != models.RefundStatusPending {
+			return nil
+		}
+
+		if refundErr != nil {
+			refund.Status = models.RefundStatusFailed
+			refund.FailureReason = refundErr.Error()
+			if err := tx.Save(refund).Error; err != nil {
+				return err
+			}
+
+			payment.RefundedAmount -= refund.Amount
+			if err := tx.Model(&payment).Update("refunded_amount", payment.RefundedAmount).Error; err != nil {
+				return err
+			}
+			return models.PostRefundFailed(tx, &payment, refund)
+		}
+
+		refund.Status = models.RefundStatusSucceeded
+		refund.ProviderRefundID = result.ID
+		if err := tx.Save(refund).Error; err != nil {
+			return err
+		}
+
+		payment.Status = models.PaymentStatusPartiallyRefunded
+		if payment.RefundedAmount >= payment.Amount {
+			payment.Status = models.PaymentStatusRefunded
+		}
+		return tx.Model(&payment).Update("status", payment.Status).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return refundErr
+}
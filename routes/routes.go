@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"proj/auth"
 	"proj/handlers"
 	"proj/middleware"
 
@@ -12,29 +13,64 @@ func Register(r *gin.Engine) {
 
 	api.POST("/register", handlers.Register)
 	api.POST("/login", handlers.Login)
+	api.POST("/token/refresh", handlers.RefreshToken)
+	api.POST("/email/verify", handlers.VerifyEmail)
+	api.POST("/password/forgot", handlers.ForgotPassword)
+	api.POST("/password/reset", handlers.ResetPassword)
+	api.POST("/payments/webhook", handlers.PaymentWebhook)
 
 	protected := api.Group("")
 	protected.Use(middleware.AuthRequired())
 	{
+		protected.POST("/logout", handlers.Logout)
+		protected.POST("/logout-all", handlers.LogoutAll)
+		protected.POST("/email/verification", handlers.RequestEmailVerification)
+
 		protected.GET("/profile", handlers.GetProfile)
-		protected.GET("/users", handlers.GetUsers)
+		protected.GET("/referral", handlers.GetReferral)
+		protected.GET("/credits", handlers.GetCreditHistory)
+		protected.PUT("/payout-details", middleware.RequirePermission(auth.PermissionEarningsRead), handlers.UpdatePayoutDetails)
+		protected.GET("/earnings", middleware.RequirePermission(auth.PermissionEarningsRead), handlers.GetEarningsBalance)
+		protected.GET("/earnings/statement", middleware.RequirePermission(auth.PermissionEarningsRead), handlers.GetEarningsStatement)
+		protected.GET("/payouts", middleware.RequirePermission(auth.PermissionEarningsRead), handlers.GetMyPayouts)
+		protected.GET("/notifications", handlers.GetNotifications)
+		protected.POST("/notifications/:id/read", handlers.MarkNotificationRead)
+		protected.GET("/users", middleware.RequirePermission(auth.PermissionUsersRead), handlers.GetUsers)
+
+		protected.POST("/vehicles", middleware.RequirePermission(auth.PermissionVehiclesCreate), handlers.CreateVehicle)
+		protected.GET("/my-vehicles", middleware.RequirePermission(auth.PermissionVehiclesManage), handlers.GetMyVehicles)
+		protected.PUT("/vehicles/:id", middleware.RequirePermission(auth.PermissionVehiclesManage), handlers.UpdateVehicle)
+		protected.DELETE("/vehicles/:id", middleware.RequirePermission(auth.PermissionVehiclesManage), handlers.DeleteVehicle)
 
-		protected.POST("/vehicles", handlers.CreateVehicle)
-		protected.GET("/my-vehicles", handlers.GetMyVehicles)
-		protected.PUT("/vehicles/:id", handlers.UpdateVehicle)
-		protected.DELETE("/vehicles/:id", handlers.DeleteVehicle)
+		protected.POST("/vehicles/:id/availability", middleware.RequirePermission(auth.PermissionVehiclesManage), handlers.SetAvailability)
+		protected.PUT("/availability/:id", middleware.RequirePermission(auth.PermissionVehiclesManage), handlers.UpdateAvailability)
+		protected.DELETE("/availability/:id", middleware.RequirePermission(auth.PermissionVehiclesManage), handlers.DeleteAvailability)
 
-		protected.POST("/vehicles/:id/availability", handlers.SetAvailability)
-		protected.PUT("/availability/:id", handlers.UpdateAvailability)
-		protected.DELETE("/availability/:id", handlers.DeleteAvailability)
+		protected.POST("/vehicles/:id/pricing-rules", middleware.RequirePermission(auth.PermissionVehiclesManage), handlers.CreatePricingRule)
+		protected.PUT("/pricing-rules/:id", middleware.RequirePermission(auth.PermissionVehiclesManage), handlers.UpdatePricingRule)
+		protected.DELETE("/pricing-rules/:id", middleware.RequirePermission(auth.PermissionVehiclesManage), handlers.DeletePricingRule)
 
-		protected.POST("/bookings", handlers.CreateBooking)
+		protected.POST("/bookings", middleware.RequirePermission(auth.PermissionBookingsCreate), handlers.CreateBooking)
 		protected.GET("/bookings", handlers.GetBookings)
 		protected.GET("/bookings/active", handlers.GetActiveBooking)
 		protected.GET("/bookings/history", handlers.GetBookingHistory)
+		protected.GET("/bookings/overdue", handlers.GetOverdueBookings)
 		protected.GET("/bookings/:id", handlers.GetBookingByID)
 		protected.POST("/bookings/:id/confirm", handlers.ConfirmBooking)
 		protected.POST("/bookings/:id/cancel", handlers.CancelBooking)
+		protected.POST("/bookings/:id/dispute", handlers.DisputeBooking)
+		protected.GET("/bookings/:id/timeline", handlers.GetBookingTimeline)
+		protected.GET("/bookings/:id/deposit", handlers.GetBookingDeposit)
+		protected.POST("/bookings/:id/deposit/capture", handlers.CaptureDeposit)
+		protected.POST("/bookings/:id/payments", handlers.CreateBookingPayment)
+		protected.GET("/bookings/:id/payments", handlers.GetBookingPayments)
+		protected.GET("/bookings/:id/invoice", handlers.GetBookingInvoice)
+		protected.POST("/payments/:id/capture", handlers.CapturePayment)
+		protected.PUT("/bookings/:id", handlers.ModifyBooking)
+		protected.POST("/bookings/:id/extend", handlers.ExtendBooking)
+		protected.GET("/bookings/:id/amendments", handlers.GetBookingAmendments)
+		protected.POST("/bookings/:id/amendments/:amendment_id/approve", handlers.ApproveAmendment)
+		protected.POST("/bookings/:id/amendments/:amendment_id/reject", handlers.RejectAmendment)
 		protected.POST("/bookings/:id/pickup/generate-otp", handlers.GeneratePickupOTP)
 		protected.POST("/bookings/:id/pickup/verify-otp", handlers.VerifyPickupOTP)
 		protected.POST("/bookings/:id/return/generate-otp", handlers.GenerateReturnOTP)
@@ -44,13 +80,34 @@ func Register(r *gin.Engine) {
 		protected.GET("/documents", handlers.GetMyDocuments)
 		protected.GET("/documents/:id", handlers.GetDocumentByID)
 		protected.DELETE("/documents/:id", handlers.DeleteDocument)
+	}
+
+	admin := api.Group("/admin")
+	admin.Use(middleware.AuthRequired(), middleware.RequirePermission(auth.PermissionAdminAccess))
+	{
+		admin.GET("/documents/pending", middleware.RequirePermission(auth.PermissionDocumentsReview), handlers.GetPendingDocuments)
+		admin.POST("/documents/:id/verify", middleware.RequirePermission(auth.PermissionDocumentsReview), handlers.VerifyDocument)
+
+		admin.PUT("/users/:id/role", middleware.AdminOnly(), handlers.UpdateUserRole)
+
+		admin.POST("/bookings/:id/resolve", middleware.AdminOnly(), handlers.ResolveDispute)
+		admin.GET("/bookings/overdue", handlers.AdminGetOverdueBookings)
+
+		admin.GET("/promo-codes", middleware.AdminOnly(), handlers.GetPromoCodes)
+		admin.POST("/promo-codes", middleware.AdminOnly(), handlers.CreatePromoCode)
+		admin.PUT("/promo-codes/:id", middleware.AdminOnly(), handlers.UpdatePromoCode)
+		admin.GET("/promo-codes/:id/redemptions", middleware.AdminOnly(), handlers.GetPromoRedemptions)
 
-		protected.GET("/admin/documents/pending", handlers.GetPendingDocuments)
-		protected.POST("/admin/documents/:id/verify", handlers.VerifyDocument)
+		admin.GET("/payouts", middleware.AdminOnly(), handlers.AdminGetPayouts)
+		admin.POST("/payouts/:id/paid", middleware.AdminOnly(), handlers.MarkPayoutPaid)
+		admin.POST("/payouts/:id/failed", middleware.AdminOnly(), handlers.MarkPayoutFailed)
 	}
 
 	api.GET("/vehicles", handlers.GetVehicles)
 	api.GET("/vehicles/:id", handlers.GetVehicleByID)
 	api.GET("/vehicles/:id/availability", handlers.GetAvailability)
-	api.GET("/availability/check", handlers.CheckAvailability)
+	api.GET("/vehicles/:id/calendar", handlers.GetVehicleCalendar)
+	api.GET("/vehicles/:id/quote", handlers.GetVehicleQuote)
+	api.GET("/vehicles/:id/pricing-rules", handlers.GetPricingRules)
+	api.GET("/availability/check", middleware.OptionalAuth(), handlers.CheckAvailability)
 }
\ No newline at end of file
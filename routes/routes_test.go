@@ -0,0 +1,214 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"proj/auth"
+	"proj/config"
+	"proj/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var publicRoutes = map[string]bool{
+	"POST /api/register":                  true,
+	"POST /api/login":                     true,
+	"POST /api/token/refresh":             true,
+	"POST /api/email/verify":              true,
+	"POST /api/password/forgot":           true,
+	"POST /api/password/reset":            true,
+	"POST /api/payments/webhook":          true,
+	"GET /api/vehicles":                   true,
+	"GET /api/vehicles/:id":               true,
+	"GET /api/vehicles/:id/availability":  true,
+	"GET /api/vehicles/:id/calendar":      true,
+	"GET /api/vehicles/:id/quote":         true,
+	"GET /api/vehicles/:id/pricing-rules": true,
+	"GET /api/availability/check":         true,
+}
+
+// restrictedRoutes lists every route that needs more than a signed-in user.
+// Routes under /api/admin also need admin:access; adminOnly ones need the
+// admin role itself.
+var restrictedRoutes = map[string]struct {
+	permission auth.Permission
+	adminOnly  bool
+}{
+	"GET /api/users":                             {permission: auth.PermissionUsersRead},
+	"PUT /api/payout-details":                    {permission: auth.PermissionEarningsRead},
+	"GET /api/earnings":                          {permission: auth.PermissionEarningsRead},
+	"GET /api/earnings/statement":                {permission: auth.PermissionEarningsRead},
+	"GET /api/payouts":                           {permission: auth.PermissionEarningsRead},
+	"POST /api/vehicles":                         {permission: auth.PermissionVehiclesCreate},
+	"GET /api/my-vehicles":                       {permission: auth.PermissionVehiclesManage},
+	"PUT /api/vehicles/:id":                      {permission: auth.PermissionVehiclesManage},
+	"DELETE /api/vehicles/:id":                   {permission: auth.PermissionVehiclesManage},
+	"POST /api/vehicles/:id/availability":        {permission: auth.PermissionVehiclesManage},
+	"PUT /api/availability/:id":                  {permission: auth.PermissionVehiclesManage},
+	"DELETE /api/availability/:id":               {permission: auth.PermissionVehiclesManage},
+	"POST /api/vehicles/:id/pricing-rules":       {permission: auth.PermissionVehiclesManage},
+	"PUT /api/pricing-rules/:id":                 {permission: auth.PermissionVehiclesManage},
+	"DELETE /api/pricing-rules/:id":              {permission: auth.PermissionVehiclesManage},
+	"POST /api/bookings":                         {permission: auth.PermissionBookingsCreate},
+	"GET /api/admin/documents/pending":           {permission: auth.PermissionDocumentsReview},
+	"POST /api/admin/documents/:id/verify":       {permission: auth.PermissionDocumentsReview},
+	"PUT /api/admin/users/:id/role":              {adminOnly: true},
+	"POST /api/admin/bookings/:id/resolve":       {adminOnly: true},
+	"GET /api/admin/bookings/overdue":            {permission: auth.PermissionAdminAccess},
+	"GET /api/admin/promo-codes":                 {adminOnly: true},
+	"POST /api/admin/promo-codes":                {adminOnly: true},
+	"PUT /api/admin/promo-codes/:id":             {adminOnly: true},
+	"GET /api/admin/promo-codes/:id/redemptions": {adminOnly: true},
+	"GET /api/admin/payouts":                     {adminOnly: true},
+	"POST /api/admin/payouts/:id/paid":           {adminOnly: true},
+	"POST /api/admin/payouts/:id/failed":         {adminOnly: true},
+}
+
+// anyUserRoutes are open to every signed-in user but touch the caller's
+// own session, so they are only checked for authentication.
+var anyUserRoutes = map[string]bool{
+	"POST /api/logout":     true,
+	"POST /api/logout-all": true,
+}
+
+func newRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	Register(r)
+	return r
+}
+
+func routeKey(route gin.RouteInfo) string {
+	return route.Method + " " + route.Path
+}
+
+// concretePath fills path parameters with an ID that doesn't exist.
+func concretePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			parts[i] = "999999999"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func serve(r *gin.Engine, method, path, token string) int {
+	req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestProtectedRoutesRequireToken(t *testing.T) {
+	r := newRouter()
+
+	seen := map[string]bool{}
+	for _, route := range r.Routes() {
+		key := routeKey(route)
+		seen[key] = true
+		if publicRoutes[key] {
+			continue
+		}
+
+		if code := serve(r, route.Method, concretePath(route.Path), ""); code != http.StatusUnauthorized {
+			t.Errorf("%s without a token: got %d, want 401", key, code)
+		}
+	}
+
+	for key := range publicRoutes {
+		if !seen[key] {
+			t.Errorf("public route %s is not registered", key)
+		}
+	}
+	for key := range restrictedRoutes {
+		if !seen[key] {
+			t.Errorf("restricted route %s is not registered", key)
+		}
+	}
+}
+
+// TestRoutePermissions signs in as every role and checks each protected
+// route lets exactly the right roles through. It needs a PostgreSQL
+// database in TEST_DATABASE_URL since tokens are tied to stored sessions.
+func TestRoutePermissions(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	config.DB = db
+	if err := models.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	roles := []string{auth.RoleAdmin, auth.RoleModerator, auth.RoleOwner, auth.RoleRenter}
+	suffix := time.Now().UnixNano()
+
+	r := newRouter()
+	for _, role := range roles {
+		user := models.User{
+			Name:          role,
+			Email:         fmt.Sprintf("%s-%d@example.com", role, suffix),
+			EmailVerified: true,
+			Password:      "x",
+			Phone:         fmt.Sprintf("%s-%d", role, suffix),
+			StudentID:     fmt.Sprintf("%s-%d", role, suffix),
+			Role:          role,
+		}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatalf("create %s: %v", role, err)
+		}
+		t.Cleanup(func() { db.Unscoped().Delete(&user) })
+
+		pair, err := auth.StartSession(&user, "test", "127.0.0.1")
+		if err != nil {
+			t.Fatalf("start session for %s: %v", role, err)
+		}
+
+		for _, route := range r.Routes() {
+			key := routeKey(route)
+			if publicRoutes[key] || anyUserRoutes[key] {
+				continue
+			}
+
+			allowed := true
+			if rule, ok := restrictedRoutes[key]; ok {
+				if rule.adminOnly {
+					allowed = role == auth.RoleAdmin
+				} else {
+					allowed = auth.HasPermission(role, rule.permission)
+				}
+			}
+			if strings.HasPrefix(route.Path, "/api/admin/") && !auth.HasPermission(role, auth.PermissionAdminAccess) {
+				allowed = false
+			}
+
+			code := serve(r, route.Method, concretePath(route.Path), pair.AccessToken)
+			switch {
+			case code == http.StatusUnauthorized:
+				t.Errorf("%s as %s: got 401", key, role)
+			case allowed && code == http.StatusForbidden:
+				t.Errorf("%s as %s: got 403, want access", key, role)
+			case !allowed && code != http.StatusForbidden:
+				t.Errorf("%s as %s: got %d, want 403", key, role, code)
+			}
+		}
+	}
+}
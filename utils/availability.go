@@ -0,0 +1,364 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"proj/config"
+	"proj/models"
+)
+
+// Interval is a half-open time range [Start, End).
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+var weekdayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+var weekdayAliases = map[string]time.Weekday{
+	"SU": time.Sunday, "SUN": time.Sunday, "SUNDAY": time.Sunday, "0": time.Sunday, "7": time.Sunday,
+	"MO": time.Monday, "MON": time.Monday, "MONDAY": time.Monday, "1": time.Monday,
+	"TU": time.Tuesday, "TUE": time.Tuesday, "TUESDAY": time.Tuesday, "2": time.Tuesday,
+	"WE": time.Wednesday, "WED": time.Wednesday, "WEDNESDAY": time.Wednesday, "3": time.Wednesday,
+	"TH": time.Thursday, "THU": time.Thursday, "THURSDAY": time.Thursday, "4": time.Thursday,
+	"FR": time.Friday, "FRI": time.Friday, "FRIDAY": time.Friday, "5": time.Friday,
+	"SA": time.Saturday, "SAT": time.Saturday, "SATURDAY": time.Saturday, "6": time.Saturday,
+}
+
+// ParseDaysOfWeek reads a comma-separated list of weekdays given as RRULE
+// BYDAY codes (MO,TU), names (mon, Monday) or numbers (0 or 7 is Sunday).
+func ParseDaysOfWeek(s string) ([]time.Weekday, error) {
+	seen := map[time.Weekday]bool{}
+	var days []time.Weekday
+	for _, part := range strings.Split(s, ",") {
+		part = strings.ToUpper(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		day, ok := weekdayAliases[part]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", part)
+		}
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+
+	if len(days) == 0 {
+		return nil, errors.New("days_of_week must list at least one weekday")
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+	return days, nil
+}
+
+func FormatDaysOfWeek(days []time.Weekday) string {
+	codes := make([]string, len(days))
+	for i, day := range days {
+		codes[i] = weekdayCodes[day]
+	}
+	return strings.Join(codes, ",")
+}
+
+// ParseClock reads a wall-clock time as HH:MM and returns minutes after
+// midnight.
+func ParseClock(s string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 2 || len(parts[0]) != 2 || len(parts[1]) != 2 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+
+	hours, err1 := strconv.Atoi(parts[0])
+	minutes, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || hours < 0 || hours > 23 || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+
+	return hours*60 + minutes, nil
+}
+
+// ParseExceptDates reads a comma-separated list of YYYY-MM-DD dates on which
+// a recurring window does not start.
+func ParseExceptDates(s string) ([]string, error) {
+	var dates []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", part); err != nil {
+			return nil, fmt.Errorf("invalid exception date %q, expected YYYY-MM-DD", part)
+		}
+		dates = append(dates, part)
+	}
+	sort.Strings(dates)
+	return dates, nil
+}
+
+// ValidateAvailability checks an availability's recurrence fields and
+// rewrites them in canonical form (BYDAY codes, sorted exception dates).
+// One-off availabilities must not carry a weekly pattern.
+func ValidateAvailability(a *models.Availability) error {
+	if !a.AvailableFrom.Before(a.AvailableTo) {
+		return errors.New("available_from must be before available_to")
+	}
+
+	if !a.IsRecurring {
+		if a.DaysOfWeek != "" || a.StartTime != "" || a.EndTime != "" || a.ExceptDates != "" {
+			return errors.New("days_of_week, start_time, end_time and except_dates require is_recurring")
+		}
+		return nil
+	}
+
+	days, err := ParseDaysOfWeek(a.DaysOfWeek)
+	if err != nil {
+		return err
+	}
+
+	if a.StartTime == "" || a.EndTime == "" {
+		return errors.New("recurring availability needs start_time and end_time")
+	}
+	if _, err := ParseClock(a.StartTime); err != nil {
+		return err
+	}
+	if _, err := ParseClock(a.EndTime); err != nil {
+		return err
+	}
+
+	dates, err := ParseExceptDates(a.ExceptDates)
+	if err != nil {
+		return err
+	}
+
+	a.DaysOfWeek = FormatDaysOfWeek(days)
+	a.ExceptDates = strings.Join(dates, ",")
+	return nil
+}
+
+// AvailabilityOccurrences expands an availability into the concrete windows
+// it covers within [from, to). A one-off availability is a single window. A
+// recurring one opens on each listed weekday between AvailableFrom and
+// AvailableTo, from StartTime to EndTime in APP_TIMEZONE; an EndTime at or
+// before StartTime runs past midnight into the next day, so 00:00-00:00 is
+// the whole day.
+func AvailabilityOccurrences(a *models.Availability, from, to time.Time) []Interval {
+	bounds, ok := intersect(Interval{a.AvailableFrom, a.AvailableTo}, Interval{from, to})
+	if !ok {
+		return nil
+	}
+
+	if !a.IsRecurring {
+		return []Interval{bounds}
+	}
+
+	days, err := ParseDaysOfWeek(a.DaysOfWeek)
+	if err != nil {
+		return nil
+	}
+	startMin, err1 := ParseClock(a.StartTime)
+	endMin, err2 := ParseClock(a.EndTime)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+	if endMin <= startMin {
+		endMin += 24 * 60
+	}
+
+	onDay := map[time.Weekday]bool{}
+	for _, day := range days {
+		onDay[day] = true
+	}
+
+	exceptions := map[string]bool{}
+	dates, _ := ParseExceptDates(a.ExceptDates)
+	for _, date := range dates {
+		exceptions[date] = true
+	}
+
+	loc := config.GetTimeLocation()
+	first := bounds.Start.In(loc)
+	last := bounds.End.In(loc)
+
+	// Start a day early so a window running past midnight into the range
+	// is included.
+	day := time.Date(first.Year(), first.Month(), first.Day()-1, 0, 0, 0, 0, loc)
+
+	var occurrences []Interval
+	for !day.After(last) {
+		if onDay[day.Weekday()] && !exceptions[day.Format("2006-01-02")] {
+			window := Interval{
+				Start: time.Date(day.Year(), day.Month(), day.Day(), 0, startMin, 0, 0, loc),
+				End:   time.Date(day.Year(), day.Month(), day.Day(), 0, endMin, 0, 0, loc),
+			}
+			if clipped, ok := intersect(window, bounds); ok {
+				occurrences = append(occurrences, clipped)
+			}
+		}
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc)
+	}
+
+	return occurrences
+}
+
+// AvailableIntervals returns when a vehicle is open within [from, to): the
+// union of its available windows minus any blocked or maintenance windows.
+func AvailableIntervals(availabilities []models.Availability, from, to time.Time) []Interval {
+	var open, closed []Interval
+	for i := range availabilities {
+		occurrences := AvailabilityOccurrences(&availabilities[i], from, to)
+		switch availabilities[i].Status {
+		case models.AvailabilityStatusAvailable:
+			open = append(open, occurrences...)
+		case models.AvailabilityStatusBlocked, models.AvailabilityStatusMaintenance:
+			closed = append(closed, occurrences...)
+		}
+	}
+
+	return SubtractIntervals(MergeIntervals(open), MergeIntervals(closed))
+}
+
+// IntervalsCover reports whether [start, end) lies entirely inside one of
+// the merged intervals.
+func IntervalsCover(intervals []Interval, start, end time.Time) bool {
+	for _, interval := range intervals {
+		if !interval.Start.After(start) && !interval.End.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeIntervals sorts intervals and joins the ones that overlap or touch.
+func MergeIntervals(intervals []Interval) []Interval {
+	if len(intervals) == 0 {
+		return nil
+	}
+
+	sorted := append([]Interval(nil), intervals...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	merged := []Interval{sorted[0]}
+	for _, interval := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if interval.Start.After(last.End) {
+			merged = append(merged, interval)
+			continue
+		}
+		if interval.End.After(last.End) {
+			last.End = interval.End
+		}
+	}
+	return merged
+}
+
+// SubtractIntervals removes cut from base. Both must be merged.
+func SubtractIntervals(base, cut []Interval) []Interval {
+	var result []Interval
+	for _, interval := range base {
+		remaining := []Interval{interval}
+		for _, c := range cut {
+			var next []Interval
+			for _, r := range remaining {
+				if !c.Start.Before(r.End) || !c.End.After(r.Start) {
+					next = append(next, r)
+					continue
+				}
+				if c.Start.After(r.Start) {
+					next = append(next, Interval{r.Start, c.Start})
+				}
+				if c.End.Before(r.End) {
+					next = append(next, Interval{c.End, r.End})
+				}
+			}
+			remaining = next
+		}
+		result = append(result, remaining...)
+	}
+	return result
+}
+
+func intersect(a, b Interval) (Interval, bool) {
+	start := a.Start
+	if b.Start.After(start) {
+		start = b.Start
+	}
+	end := a.End
+	if b.End.Before(end) {
+		end = b.End
+	}
+	if !start.Before(end) {
+		return Interval{}, false
+	}
+	return Interval{start, end}, true
+}
+
+// AlignIntervals shrinks each interval inward to boundaries of step counted
+// from local midnight in loc, dropping any that end up empty. step must
+// divide a day evenly.
+func AlignIntervals(intervals []Interval, step time.Duration, loc *time.Location) []Interval {
+	var aligned []Interval
+	for _, interval := range intervals {
+		start := alignUp(interval.Start, step, loc)
+		end := alignDown(interval.End, step, loc)
+		if start.Before(end) {
+			aligned = append(aligned, Interval{start, end})
+		}
+	}
+	return aligned
+}
+
+// SplitByDay cuts intervals at local midnight in loc and groups the pieces
+// by date (YYYY-MM-DD), in order.
+func SplitByDay(intervals []Interval, loc *time.Location) ([]string, map[string][]Interval) {
+	var dates []string
+	byDate := map[string][]Interval{}
+	for _, interval := range intervals {
+		start := interval.Start
+		for start.Before(interval.End) {
+			local := start.In(loc)
+			next := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc)
+			end := interval.End
+			if next.Before(end) {
+				end = next
+			}
+			date := local.Format("2006-01-02")
+			if _, ok := byDate[date]; !ok {
+				dates = append(dates, date)
+			}
+			byDate[date] = append(byDate[date], Interval{start, end})
+			start = end
+		}
+	}
+	return dates, byDate
+}
+
+func alignDown(t time.Time, step time.Duration, loc *time.Location) time.Time {
+	local := t.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	return midnight.Add(t.Sub(midnight) / step * step)
+}
+
+func alignUp(t time.Time, step time.Duration, loc *time.Location) time.Time {
+	down := alignDown(t, step, loc)
+	if down.Equal(t) {
+		return t
+	}
+	return down.Add(step)
+}
+
+// BookingWindow returns the earliest start and latest end a new booking of
+// the vehicle may have at now. latest is zero when the vehicle has no
+// advance-booking limit.
+func BookingWindow(v *models.Vehicle, now time.Time) (earliest, latest time.Time) {
+	earliest = now.Add(time.Duration(v.MinNoticeHours) * time.Hour)
+	if v.MaxAdvanceDays > 0 {
+		latest = now.AddDate(0, 0, v.MaxAdvanceDays)
+	}
+	return earliest, latest
+}
@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"proj/config"
+	"proj/models"
+)
+
+// marchAt returns day/hour:minute in March 2026 in APP_TIMEZONE. The 9th is a
+// Monday.
+func marchAt(day, hour, minute int) time.Time {
+	return time.Date(2026, 3, day, hour, minute, 0, 0, config.GetTimeLocation())
+}
+
+func assertIntervals(t *testing.T, got, want []Interval) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d intervals %v, want %d %v", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if !got[i].Start.Equal(want[i].Start) || !got[i].End.Equal(want[i].End) {
+			t.Errorf("interval %d = [%s, %s), want [%s, %s)", i, got[i].Start, got[i].End, want[i].Start, want[i].End)
+		}
+	}
+}
+
+func recurring(days, start, end, except string) models.Availability {
+	return models.Availability{
+		AvailableFrom: marchAt(1, 0, 0),
+		AvailableTo:   marchAt(31, 0, 0),
+		IsRecurring:   true,
+		DaysOfWeek:    days,
+		StartTime:     start,
+		EndTime:       end,
+		ExceptDates:   except,
+		Status:        models.AvailabilityStatusAvailable,
+	}
+}
+
+func TestAvailabilityOccurrences(t *testing.T) {
+	// Every case is expanded over the week from Monday the 9th to Monday
+	// the 16th.
+	from, to := marchAt(9, 0, 0), marchAt(16, 0, 0)
+
+	clipped := recurring("MO,TU,WE,TH,FR", "09:00", "17:00", "")
+	clipped.AvailableFrom = marchAt(10, 12, 0)
+	clipped.AvailableTo = marchAt(12, 12, 0)
+
+	tests := []struct {
+		name         string
+		availability models.Availability
+		want         []Interval
+	}{
+		{
+			name: "one-off clipped to the range",
+			availability: models.Availability{
+				AvailableFrom: marchAt(8, 12, 0),
+				AvailableTo:   marchAt(10, 12, 0),
+			},
+			want: []Interval{{marchAt(9, 0, 0), marchAt(10, 12, 0)}},
+		},
+		{
+			name:         "listed weekdays only",
+			availability: recurring("MO,WE", "09:00", "17:00", ""),
+			want: []Interval{
+				{marchAt(9, 9, 0), marchAt(9, 17, 0)},
+				{marchAt(11, 9, 0), marchAt(11, 17, 0)},
+			},
+		},
+		{
+			name:         "except date skipped",
+			availability: recurring("MO,WE,FR", "09:00", "17:00", "2026-03-11"),
+			want: []Interval{
+				{marchAt(9, 9, 0), marchAt(9, 17, 0)},
+				{marchAt(13, 9, 0), marchAt(13, 17, 0)},
+			},
+		},
+		{
+			// Sunday the 8th's window runs into the range and Sunday the
+			// 15th's runs out of it.
+			name:         "window crossing midnight",
+			availability: recurring("SU", "22:00", "02:00", ""),
+			want: []Interval{
+				{marchAt(9, 0, 0), marchAt(9, 2, 0)},
+				{marchAt(15, 22, 0), marchAt(16, 0, 0)},
+			},
+		},
+		{
+			name:         "except date skips the day a window starts",
+			availability: recurring("SU", "22:00", "02:00", "2026-03-08"),
+			want:         []Interval{{marchAt(15, 22, 0), marchAt(16, 0, 0)}},
+		},
+		{
+			name:         "except date on the day a window ends",
+			availability: recurring("SU", "22:00", "02:00", "2026-03-09"),
+			want: []Interval{
+				{marchAt(9, 0, 0), marchAt(9, 2, 0)},
+				{marchAt(15, 22, 0), marchAt(16, 0, 0)},
+			},
+		},
+		{
+			name:         "midnight to midnight is the whole day",
+			availability: recurring("TU", "00:00", "00:00", ""),
+			want:         []Interval{{marchAt(10, 0, 0), marchAt(11, 0, 0)}},
+		},
+		{
+			name:         "clipped to available from and to",
+			availability: clipped,
+			want: []Interval{
+				{marchAt(10, 12, 0), marchAt(10, 17, 0)},
+				{marchAt(11, 9, 0), marchAt(11, 17, 0)},
+				{marchAt(12, 9, 0), marchAt(12, 12, 0)},
+			},
+		},
+		{
+			name: "outside the range",
+			availability: models.Availability{
+				AvailableFrom: marchAt(20, 0, 0),
+				AvailableTo:   marchAt(21, 0, 0),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIntervals(t, AvailabilityOccurrences(&tt.availability, from, to), tt.want)
+		})
+	}
+}
+
+func TestAvailableIntervals(t *testing.T) {
+	from, to := marchAt(9, 0, 0), marchAt(10, 0, 0)
+
+	lunch := recurring("MO", "12:00", "13:00", "")
+	lunch.Status = models.AvailabilityStatusBlocked
+
+	availabilities := []models.Availability{
+		{AvailableFrom: marchAt(9, 8, 0), AvailableTo: marchAt(9, 20, 0), Status: models.AvailabilityStatusAvailable},
+		recurring("MO", "07:00", "09:00", ""),
+		lunch,
+		{AvailableFrom: marchAt(9, 18, 0), AvailableTo: marchAt(9, 22, 0), Status: models.AvailabilityStatusMaintenance},
+	}
+
+	// Overlapping open windows merge, and blocked and maintenance windows
+	// are cut out of them.
+	assertIntervals(t, AvailableIntervals(availabilities, from, to), []Interval{
+		{marchAt(9, 7, 0), marchAt(9, 12, 0)},
+		{marchAt(9, 13, 0), marchAt(9, 18, 0)},
+	})
+}
+
+func TestAlignIntervals(t *testing.T) {
+	loc := config.GetTimeLocation()
+	intervals := []Interval{
+		{marchAt(9, 9, 10), marchAt(9, 11, 50)},
+		{marchAt(9, 12, 0), marchAt(9, 13, 0)},
+		{marchAt(9, 14, 10), marchAt(9, 14, 50)},
+	}
+
+	// Ragged edges shrink inward to the half hour, aligned edges stay, and
+	// an interval too short to hold a whole step is dropped.
+	assertIntervals(t, AlignIntervals(intervals, 30*time.Minute, loc), []Interval{
+		{marchAt(9, 9, 30), marchAt(9, 11, 30)},
+		{marchAt(9, 12, 0), marchAt(9, 13, 0)},
+	})
+}
+
+func TestSplitByDay(t *testing.T) {
+	loc := config.GetTimeLocation()
+	intervals := []Interval{
+		{marchAt(9, 9, 0), marchAt(9, 17, 0)},
+		{marchAt(9, 22, 0), marchAt(11, 2, 0)},
+	}
+
+	dates, byDate := SplitByDay(intervals, loc)
+
+	wantDates := []string{"2026-03-09", "2026-03-10", "2026-03-11"}
+	if len(dates) != len(wantDates) {
+		t.Fatalf("dates = %v, want %v", dates, wantDates)
+	}
+	for i := range wantDates {
+		if dates[i] != wantDates[i] {
+			t.Fatalf("dates = %v, want %v", dates, wantDates)
+		}
+	}
+
+	assertIntervals(t, byDate["2026-03-09"], []Interval{
+		{marchAt(9, 9, 0), marchAt(9, 17, 0)},
+		{marchAt(9, 22, 0), marchAt(10, 0, 0)},
+	})
+	assertIntervals(t, byDate["2026-03-10"], []Interval{{marchAt(10, 0, 0), marchAt(11, 0, 0)}})
+	assertIntervals(t, byDate["2026-03-11"], []Interval{{marchAt(11, 0, 0), marchAt(11, 2, 0)}})
+}
+
+func TestBookingWindow(t *testing.T) {
+	now := marchAt(9, 10, 0)
+
+	tests := []struct {
+		name         string
+		vehicle      models.Vehicle
+		wantEarliest time.Time
+		wantLatest   time.Time
+	}{
+		{
+			name:         "notice and horizon",
+			vehicle:      models.Vehicle{MinNoticeHours: 2, MaxAdvanceDays: 7},
+			wantEarliest: marchAt(9, 12, 0),
+			wantLatest:   marchAt(16, 10, 0),
+		},
+		{
+			name:         "zero notice allows starting now",
+			vehicle:      models.Vehicle{MaxAdvanceDays: 7},
+			wantEarliest: now,
+			wantLatest:   marchAt(16, 10, 0),
+		},
+		{
+			name:         "zero horizon has no latest end",
+			vehicle:      models.Vehicle{MinNoticeHours: 24},
+			wantEarliest: marchAt(10, 10, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			earliest, latest := BookingWindow(&tt.vehicle, now)
+			if !earliest.Equal(tt.wantEarliest) {
+				t.Errorf("earliest = %s, want %s", earliest, tt.wantEarliest)
+			}
+			if !latest.Equal(tt.wantLatest) {
+				t.Errorf("latest = %s, want %s", latest, tt.wantLatest)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"proj/models"
+)
+
+// BookingRuleViolation is one of a vehicle's booking rules that a request
+// breaks. Rule is a stable code clients can match on and Message is safe to
+// show to the renter.
+type BookingRuleViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// BookingRulesError carries every rule a booking request breaks, so the
+// renter can fix them all at once.
+type BookingRulesError struct {
+	Violations []BookingRuleViolation
+}
+
+func (e *BookingRulesError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "booking rules not met: " + strings.Join(messages, "; ")
+}
+
+// CheckBookingRules checks a booking of the vehicle from start to end
+// against the owner's rules and returns a *BookingRulesError listing each
+// one broken. A nil renter checks only the rules that don't depend on who is
+// booking. bringsHelmet is whether the renter has their own helmet.
+func CheckBookingRules(v *models.Vehicle, renter *models.User, start, end time.Time, bringsHelmet bool) error {
+	violations := CheckRentalDuration(v, start, end)
+
+	if renter != nil {
+		violations = append(violations, checkRenterRules(v, renter)...)
+	}
+
+	if v.RequireHelmet && !v.HasHelmet && !bringsHelmet {
+		violations = append(violations, BookingRuleViolation{
+			Rule:    models.BookingRuleHelmet,
+			Message: "this vehicle doesn't come with a helmet, so you must bring your own",
+		})
+	}
+
+	if len(violations) > 0 {
+		return &BookingRulesError{Violations: violations}
+	}
+	return nil
+}
+
+// CheckRentalDuration checks the vehicle's minimum and maximum rental
+// length. Zero limits are not enforced.
+func CheckRentalDuration(v *models.Vehicle, start, end time.Time) []BookingRuleViolation {
+	var violations []BookingRuleViolation
+	duration := end.Sub(start)
+
+	if v.MinRentalHours > 0 && duration < time.Duration(v.MinRentalHours)*time.Hour {
+		violations = append(violations, BookingRuleViolation{
+			Rule:    models.BookingRuleMinRentalHours,
+			Message: fmt.Sprintf("this vehicle must be rented for at least %d hours", v.MinRentalHours),
+		})
+	}
+
+	if v.MaxRentalDays > 0 && duration > time.Duration(v.MaxRentalDays)*24*time.Hour {
+		violations = append(violations, BookingRuleViolation{
+			Rule:    models.BookingRuleMaxRentalDays,
+			Message: fmt.Sprintf("this vehicle can be rented for at most %d days", v.MaxRentalDays),
+		})
+	}
+
+	return violations
+}
+
+func checkRenterRules(v *models.Vehicle, renter *models.User) []BookingRuleViolation {
+	var violations []BookingRuleViolation
+
+	if v.AllowedRenterYears != "" {
+		years, _ := ParseRenterYears(v.AllowedRenterYears)
+		allowed := false
+		for _, year := range years {
+			if renter.Year == year {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			violations = append(violations, BookingRuleViolation{
+				Rule:    models.BookingRuleRenterYear,
+				Message: "this vehicle is only available to students in year " + v.AllowedRenterYears,
+			})
+		}
+	}
+
+	if v.GenderRestriction != "" && !strings.EqualFold(strings.TrimSpace(renter.Gender), v.GenderRestriction) {
+		violations = append(violations, BookingRuleViolation{
+			Rule:    models.BookingRuleGender,
+			Message: "this vehicle is only available to " + v.GenderRestriction + " renters",
+		})
+	}
+
+	return violations
+}
+
+// ParseRenterYears reads a comma-separated list of study years, e.g. "2,3",
+// and returns them sorted without duplicates.
+func ParseRenterYears(s string) ([]int, error) {
+	seen := map[int]bool{}
+	var years []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		year, err := strconv.Atoi(part)
+		if err != nil || year < 1 || year > 10 {
+			return nil, fmt.Errorf("invalid year %q in allowed_renter_years", part)
+		}
+		if !seen[year] {
+			seen[year] = true
+			years = append(years, year)
+		}
+	}
+
+	if len(years) == 0 {
+		return nil, errors.New("allowed_renter_years must list at least one year")
+	}
+
+	sort.Ints(years)
+	return years, nil
+}
+
+// NormalizeRenterYears validates allowed_renter_years and rewrites it as a
+// sorted list. An empty value means every year is allowed.
+func NormalizeRenterYears(s string) (string, error) {
+	if strings.TrimSpace(s) == "" {
+		return "", nil
+	}
+
+	years, err := ParseRenterYears(s)
+	if err != nil {
+		return "", err
+	}
+
+	parts := make([]string, len(years))
+	for i, year := range years {
+		parts[i] = strconv.Itoa(year)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+func IsValidGenderRestriction(gender string) bool {
+	return gender == "" || gender == models.GenderMale || gender == models.GenderFemale
+}
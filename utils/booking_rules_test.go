@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"proj/models"
+)
+
+func TestCheckBookingRules(t *testing.T) {
+	start := time.Date(2026, 3, 9, 10, 0, 0, 0, time.UTC)
+	limits := models.Vehicle{MinRentalHours: 2, MaxRentalDays: 3}
+	secondYear := &models.User{Year: 2, Gender: models.GenderFemale}
+
+	tests := []struct {
+		name         string
+		vehicle      models.Vehicle
+		renter       *models.User
+		duration     time.Duration
+		bringsHelmet bool
+		want         []string
+	}{
+		{name: "within limits", vehicle: limits, duration: 2 * time.Hour},
+		{name: "shorter than minimum", vehicle: limits, duration: 90 * time.Minute, want: []string{models.BookingRuleMinRentalHours}},
+		{name: "at the maximum", vehicle: limits, duration: 72 * time.Hour},
+		{name: "longer than maximum", vehicle: limits, duration: 72*time.Hour + time.Minute, want: []string{models.BookingRuleMaxRentalDays}},
+		// An owner who sets a limit to 0 turns it off rather than getting
+		// the default back.
+		{name: "explicit zero minimum", vehicle: models.Vehicle{MaxRentalDays: 3}, duration: time.Minute},
+		{name: "explicit zero maximum", vehicle: models.Vehicle{MinRentalHours: 2}, duration: 30 * 24 * time.Hour},
+		{name: "explicit zero limits", duration: time.Minute},
+		{
+			name:     "renter outside allowed years",
+			vehicle:  models.Vehicle{AllowedRenterYears: "3,4"},
+			renter:   secondYear,
+			duration: time.Hour,
+			want:     []string{models.BookingRuleRenterYear},
+		},
+		{
+			name:     "renter of another gender",
+			vehicle:  models.Vehicle{GenderRestriction: models.GenderMale},
+			renter:   secondYear,
+			duration: time.Hour,
+			want:     []string{models.BookingRuleGender},
+		},
+		{
+			name:     "renter rules skipped without a renter",
+			vehicle:  models.Vehicle{AllowedRenterYears: "3,4", GenderRestriction: models.GenderMale},
+			duration: time.Hour,
+		},
+		{
+			name:     "helmet required and not provided",
+			vehicle:  models.Vehicle{RequireHelmet: true},
+			duration: time.Hour,
+			want:     []string{models.BookingRuleHelmet},
+		},
+		{name: "helmet required and vehicle has one", vehicle: models.Vehicle{RequireHelmet: true, HasHelmet: true}, duration: time.Hour},
+		{name: "helmet required and renter brings one", vehicle: models.Vehicle{RequireHelmet: true}, duration: time.Hour, bringsHelmet: true},
+		{
+			name:     "every violation reported",
+			vehicle:  models.Vehicle{MinRentalHours: 2, AllowedRenterYears: "3", GenderRestriction: models.GenderMale, RequireHelmet: true},
+			renter:   secondYear,
+			duration: time.Hour,
+			want: []string{
+				models.BookingRuleMinRentalHours,
+				models.BookingRuleRenterYear,
+				models.BookingRuleGender,
+				models.BookingRuleHelmet,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckBookingRules(&tt.vehicle, tt.renter, start, start.Add(tt.duration), tt.bringsHelmet)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+
+			var rulesErr *BookingRulesError
+			if !errors.As(err, &rulesErr) {
+				t.Fatalf("got %v, want a *BookingRulesError", err)
+			}
+			if len(rulesErr.Violations) != len(tt.want) {
+				t.Fatalf("violations = %v, want rules %v", rulesErr.Violations, tt.want)
+			}
+			for i, rule := range tt.want {
+				if rulesErr.Violations[i].Rule != rule {
+					t.Errorf("violation %d = %s, want %s", i, rulesErr.Violations[i].Rule, rule)
+				}
+			}
+		})
+	}
+}
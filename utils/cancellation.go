@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"math"
+	"time"
+
+	"proj/models"
+)
+
+type cancellationTier struct {
+	MinHoursBefore float64
+	FeePercent     int64
+}
+
+// Tiers are ordered from the earliest cancellation to the latest; the first
+// tier whose threshold is met applies.
+var cancellationPolicies = map[string][]cancellationTier{
+	models.CancellationPolicyFlexible: {
+		{MinHoursBefore: 2, FeePercent: 0},
+		{MinHoursBefore: math.Inf(-1), FeePercent: 25},
+	},
+	models.CancellationPolicyModerate: {
+		{MinHoursBefore: 24, FeePercent: 0},
+		{MinHoursBefore: 2, FeePercent: 25},
+		{MinHoursBefore: math.Inf(-1), FeePercent: 50},
+	},
+	models.CancellationPolicyStrict: {
+		{MinHoursBefore: 72, FeePercent: 0},
+		{MinHoursBefore: 24, FeePercent: 50},
+		{MinHoursBefore: math.Inf(-1), FeePercent: 100},
+	},
+}
+
+var ownerPenaltyTiers = []cancellationTier{
+	{MinHoursBefore: 24, FeePercent: 10},
+	{MinHoursBefore: math.Inf(-1), FeePercent: 25},
+}
+
+type CancellationOutcome struct {
+	Policy           string  `json:"policy"`
+	HoursBeforeStart float64 `json:"hours_before_start"`
+	FeePercent       int64   `json:"fee_percent"`
+	Fee              int64   `json:"fee"`
+	Refund           int64   `json:"refund"`
+	OwnerPenalty     int64   `json:"owner_penalty"`
+}
+
+func IsValidCancellationPolicy(policy string) bool {
+	_, ok := cancellationPolicies[policy]
+	return ok
+}
+
+func tierPercent(tiers []cancellationTier, hoursBefore float64) int64 {
+	for _, tier := range tiers {
+		if hoursBefore >= tier.MinHoursBefore {
+			return tier.FeePercent
+		}
+	}
+	return 0
+}
+
+// CalculateCancellation works out what a cancellation costs. Renters only pay
+// a fee once the owner has confirmed; owners never charge the renter but are
+// penalised for backing out of a confirmed booking, more so close to the
+// start time. Admin cancellations are free for both sides. paid is the rental
+// amount actually captured, so the fee and refund never exceed it.
+func CalculateCancellation(booking *models.Booking, policy string, actor string, paid int64, now time.Time) CancellationOutcome {
+	if !IsValidCancellationPolicy(policy) {
+		policy = models.CancellationPolicyModerate
+	}
+
+	hoursBefore := booking.StartTime.Sub(now).Hours()
+	outcome := CancellationOutcome{
+		Policy:           policy,
+		HoursBeforeStart: math.Round(hoursBefore*100) / 100,
+		Refund:           paid,
+	}
+
+	if booking.Status != models.BookingStatusConfirmed {
+		return outcome
+	}
+
+	switch actor {
+	case models.BookingActorRenter:
+		outcome.FeePercent = tierPercent(cancellationPolicies[policy], hoursBefore)
+		outcome.Fee = booking.EstimatedPrice * outcome.FeePercent / 100
+		if outcome.Fee > paid {
+			outcome.Fee = paid
+		}
+		outcome.Refund = paid - outcome.Fee
+	case models.BookingActorOwner:
+		outcome.OwnerPenalty = booking.EstimatedPrice * tierPercent(ownerPenaltyTiers, hoursBefore) / 100
+	}
+
+	return outcome
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"math"
+
+	"proj/models"
+)
+
+type FuelCharge struct {
+	Policy             string  `json:"policy"`
+	StartPercent       int     `json:"start_percent"`
+	EndPercent         int     `json:"end_percent"`
+	TargetPercent      int     `json:"target_percent"`
+	TankCapacityLiters float64 `json:"tank_capacity_liters"`
+	RefillLiters       float64 `json:"refill_liters"`
+	PricePerLiter      int64   `json:"price_per_liter"`
+	Cost               int64   `json:"cost"`
+}
+
+func IsValidFuelPolicy(policy string) bool {
+	return policy == models.FuelPolicySameLevel || policy == models.FuelPolicyReturnFull
+}
+
+// CalculateFuelCharge prices the fuel needed to bring the tank back to the
+// level the booking's policy asks for: the pickup level for same_level, a
+// full tank for return_full. Returning with more fuel is not credited, and
+// vehicles without a tank capacity are never charged.
+func CalculateFuelCharge(booking *models.Booking, endPercent int, pricePerLiter int64) FuelCharge {
+	charge := FuelCharge{
+		Policy:             booking.FuelPolicy,
+		StartPercent:       booking.FuelLevelStartPercent,
+		EndPercent:         endPercent,
+		TargetPercent:      booking.FuelLevelStartPercent,
+		TankCapacityLiters: booking.TankCapacityLiters,
+		PricePerLiter:      pricePerLiter,
+	}
+
+	if charge.Policy == models.FuelPolicyReturnFull {
+		charge.TargetPercent = 100
+	}
+
+	missing := charge.TargetPercent - endPercent
+	if missing <= 0 || booking.TankCapacityLiters <= 0 {
+		return charge
+	}
+
+	charge.RefillLiters = math.Round(float64(missing)/100*booking.TankCapacityLiters*100) / 100
+	charge.Cost = CalculateFuelCost(charge.RefillLiters, pricePerLiter)
+	return charge
+}
+
+// BookingFuelCharge rebuilds the fuel breakdown from what was stored on the
+// booking at return.
+func BookingFuelCharge(booking *models.Booking) FuelCharge {
+	charge := CalculateFuelCharge(booking, booking.FuelLevelEndPercent, booking.FuelPricePerLiter)
+	charge.Cost = booking.FuelCostCharged
+	return charge
+}
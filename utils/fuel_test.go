@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"testing"
+
+	"proj/models"
+)
+
+func TestCalculateFuelCharge(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       string
+		tankLiters   float64
+		startPercent int
+		endPercent   int
+		wantTarget   int
+		wantLiters   float64
+		wantCost     int64
+	}{
+		{name: "same level returned level", policy: models.FuelPolicySameLevel, tankLiters: 40, startPercent: 80, endPercent: 80, wantTarget: 80},
+		{name: "same level returned lower", policy: models.FuelPolicySameLevel, tankLiters: 40, startPercent: 80, endPercent: 50, wantTarget: 80, wantLiters: 12, wantCost: 1200},
+		{name: "extra fuel is not credited", policy: models.FuelPolicySameLevel, tankLiters: 40, startPercent: 50, endPercent: 80, wantTarget: 50},
+		{name: "no policy charges like same level", tankLiters: 40, startPercent: 60, endPercent: 55, wantTarget: 60, wantLiters: 2, wantCost: 200},
+		{name: "return full below full", policy: models.FuelPolicyReturnFull, tankLiters: 40, startPercent: 50, endPercent: 90, wantTarget: 100, wantLiters: 4, wantCost: 400},
+		{name: "return full returned full", policy: models.FuelPolicyReturnFull, tankLiters: 40, startPercent: 50, endPercent: 100, wantTarget: 100},
+		{name: "no tank capacity", policy: models.FuelPolicyReturnFull, startPercent: 100, endPercent: 20, wantTarget: 100},
+		{name: "liters rounded to two decimals", policy: models.FuelPolicySameLevel, tankLiters: 13.3, startPercent: 57, endPercent: 50, wantTarget: 57, wantLiters: 0.93, wantCost: 93},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			booking := &models.Booking{
+				FuelPolicy:            tt.policy,
+				FuelLevelStartPercent: tt.startPercent,
+				TankCapacityLiters:    tt.tankLiters,
+			}
+
+			got := CalculateFuelCharge(booking, tt.endPercent, 100)
+			if got.TargetPercent != tt.wantTarget || got.RefillLiters != tt.wantLiters || got.Cost != tt.wantCost {
+				t.Errorf("CalculateFuelCharge() = target %d, %v liters, cost %d; want target %d, %v liters, cost %d",
+					got.TargetPercent, got.RefillLiters, got.Cost, tt.wantTarget, tt.wantLiters, tt.wantCost)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// FinancialYear returns the Indian financial year (April to March) that t
+// falls in, e.g. "2026-27".
+func FinancialYear(t time.Time) string {
+	start := t.Year()
+	if t.Month() < time.April {
+		start--
+	}
+	return fmt.Sprintf("%d-%02d", start, (start+1)%100)
+}
+
+// SplitInclusiveGST splits a GST-inclusive amount into its taxable value and
+// the CGST and SGST halves of the tax.
+func SplitInclusiveGST(amount int64, ratePercent int) (taxable int64, cgst int64, sgst int64) {
+	if ratePercent <= 0 || amount <= 0 {
+		return amount, 0, 0
+	}
+
+	taxable = amount * 100 / int64(100+ratePercent)
+	tax := amount - taxable
+	cgst = tax / 2
+	sgst = tax - cgst
+	return taxable, cgst, sgst
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"math"
+	"time"
+
+	"proj/models"
+)
+
+type LateReturn struct {
+	LateMinutes     int   `json:"late_minutes"`
+	ChargeableHours int   `json:"chargeable_hours"`
+	FeePerHour      int64 `json:"fee_per_hour"`
+	Fee             int64 `json:"fee"`
+}
+
+// LateFeeRate is the hourly late fee captured on the booking. Vehicles that
+// don't set one charge their normal hourly rate.
+func LateFeeRate(booking *models.Booking) int64 {
+	if booking.LateFeePerHour > 0 {
+		return booking.LateFeePerHour
+	}
+	return booking.PricePerHour
+}
+
+// LateDeadline is the time after which a return counts as late.
+func LateDeadline(booking *models.Booking) time.Time {
+	return booking.EndTime.Add(time.Duration(booking.LateGraceMinutes) * time.Minute)
+}
+
+func IsOverdue(booking *models.Booking, now time.Time) bool {
+	return now.After(LateDeadline(booking))
+}
+
+// CalculateLateReturn works out the late fee for a vehicle returned at
+// returnedAt. Returns inside the grace period are free; past it, every
+// started hour after EndTime is charged, grace period included.
+func CalculateLateReturn(booking *models.Booking, returnedAt time.Time) LateReturn {
+	late := returnedAt.Sub(booking.EndTime)
+	if late <= 0 {
+		return LateReturn{}
+	}
+
+	result := LateReturn{
+		LateMinutes: int(math.Ceil(late.Minutes())),
+		FeePerHour:  LateFeeRate(booking),
+	}
+
+	if !IsOverdue(booking, returnedAt) {
+		return result
+	}
+
+	result.ChargeableHours = int(math.Ceil(late.Hours()))
+	result.Fee = int64(result.ChargeableHours) * result.FeePerHour
+	return result
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"proj/models"
+)
+
+func TestCalculateLateReturn(t *testing.T) {
+	end := time.Date(2026, 3, 14, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		graceMinutes   int
+		lateFeePerHour int64
+		late           time.Duration
+		want           LateReturn
+	}{
+		{name: "returned early", graceMinutes: 15, late: -30 * time.Minute, want: LateReturn{}},
+		{name: "returned on time", graceMinutes: 15, want: LateReturn{}},
+		{name: "inside grace period", graceMinutes: 15, late: 10 * time.Minute, want: LateReturn{LateMinutes: 10, FeePerHour: 100}},
+		{name: "end of grace period", graceMinutes: 15, late: 15 * time.Minute, want: LateReturn{LateMinutes: 15, FeePerHour: 100}},
+		{name: "seconds are rounded up to a minute", graceMinutes: 15, late: 30 * time.Second, want: LateReturn{LateMinutes: 1, FeePerHour: 100}},
+		// Once past the grace period the grace minutes are charged too.
+		{name: "just past grace period", graceMinutes: 15, late: 16 * time.Minute, want: LateReturn{LateMinutes: 16, ChargeableHours: 1, FeePerHour: 100, Fee: 100}},
+		{name: "started hours are rounded up", graceMinutes: 15, late: 61 * time.Minute, want: LateReturn{LateMinutes: 61, ChargeableHours: 2, FeePerHour: 100, Fee: 200}},
+		{name: "no grace period", late: time.Second, want: LateReturn{LateMinutes: 1, ChargeableHours: 1, FeePerHour: 100, Fee: 100}},
+		{name: "vehicle late fee rate", graceMinutes: 15, lateFeePerHour: 250, late: 2*time.Hour + 10*time.Minute, want: LateReturn{LateMinutes: 130, ChargeableHours: 3, FeePerHour: 250, Fee: 750}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			booking := &models.Booking{
+				EndTime:          end,
+				PricePerHour:     100,
+				LateFeePerHour:   tt.lateFeePerHour,
+				LateGraceMinutes: tt.graceMinutes,
+			}
+
+			if got := CalculateLateReturn(booking, end.Add(tt.late)); got != tt.want {
+				t.Errorf("CalculateLateReturn() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
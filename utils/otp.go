@@ -1,18 +1,66 @@
 package utils
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
+	"sync"
 	"time"
+
+	"proj/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	OTPTTL             = 10 * time.Minute
+	OTPMaxAttempts     = 5
+	OTPLockoutDuration = 30 * time.Minute
+
+	OTPRegenerateBaseDelay = 30 * time.Second
+	OTPRegenerateMaxDelay  = 15 * time.Minute
 )
 
-type OTPStore struct {
-	Code      string
-	ExpiresAt time.Time
+var (
+	ErrOTPNotFound = errors.New("otp not found")
+	ErrOTPExpired  = errors.New("otp expired")
+	ErrOTPInvalid  = errors.New("otp invalid")
+	ErrOTPLocked   = errors.New("otp verification locked")
+	ErrOTPCooldown = errors.New("otp regeneration too soon")
+)
+
+// OTPRetryError is returned when verification is locked or regeneration is
+// backing off. LockoutTriggered is set only on the failed attempt that caused
+// the lockout, so callers can audit and notify exactly once.
+type OTPRetryError struct {
+	Err              error
+	RetryAt          time.Time
+	LockoutTriggered bool
+}
+
+func (e *OTPRetryError) Error() string {
+	return fmt.Sprintf("%v, retry at %s", e.Err, e.RetryAt.Format(time.RFC3339))
+}
+
+func (e *OTPRetryError) Unwrap() error {
+	return e.Err
 }
 
-var otpStorage = make(map[string]OTPStore)
+// OTPStore issues and verifies the one-time codes used at pickup and return.
+// Codes are single use: a successful Verify consumes the code, and issuing a
+// new code for the same booking and purpose replaces the previous one.
+// Failed attempts are counted per booking and purpose and are not reset by
+// issuing a new code, so regenerating can't be used to get more guesses.
+type OTPStore interface {
+	Issue(bookingID uint, purpose string) (string, error)
+	Verify(bookingID uint, purpose string, code string) error
+	Clear(bookingID uint, purpose string) error
+}
 
 func GenerateOTP() (string, error) {
 	max := big.NewInt(1000000)
@@ -20,48 +68,269 @@ func GenerateOTP() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	
+
 	otp := fmt.Sprintf("%06d", n.Int64())
 	return otp, nil
 }
 
-func StoreOTP(bookingID string, otpType string) (string, error) {
+// HashOTP binds the code to its booking and purpose and keys it with the
+// server secret, so a leaked hash can't be brute forced offline or replayed
+// against another booking.
+func HashOTP(bookingID uint, purpose string, code string) string {
+	mac := hmac.New(sha256.New, getEncryptionKey())
+	fmt.Fprintf(mac, "%d:%s:%s", bookingID, purpose, code)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func regenerateDelay(issueCount int) time.Duration {
+	if issueCount <= 0 {
+		return 0
+	}
+	delay := OTPRegenerateBaseDelay
+	for i := 1; i < issueCount; i++ {
+		delay *= 2
+		if delay >= OTPRegenerateMaxDelay {
+			return OTPRegenerateMaxDelay
+		}
+	}
+	return delay
+}
+
+// otpState holds the rules shared by every OTPStore implementation. Stores
+// only load and persist it.
+type otpState struct {
+	CodeHash     string
+	ExpiresAt    time.Time
+	ConsumedAt   *time.Time
+	Attempts     int
+	LockedUntil  *time.Time
+	IssueCount   int
+	LastIssuedAt *time.Time
+}
+
+func (st *otpState) issue(bookingID uint, purpose string, now time.Time) (string, error) {
+	if st.LockedUntil != nil {
+		if now.Before(*st.LockedUntil) {
+			return "", &OTPRetryError{Err: ErrOTPLocked, RetryAt: *st.LockedUntil}
+		}
+		st.LockedUntil = nil
+		st.Attempts = 0
+	}
+
+	if st.LastIssuedAt != nil {
+		retryAt := st.LastIssuedAt.Add(regenerateDelay(st.IssueCount))
+		if now.Before(retryAt) {
+			return "", &OTPRetryError{Err: ErrOTPCooldown, RetryAt: retryAt}
+		}
+	}
+
 	otp, err := GenerateOTP()
 	if err != nil {
 		return "", err
 	}
-	
-	key := fmt.Sprintf("%s_%s", bookingID, otpType)
-	otpStorage[key] = OTPStore{
-		Code:      otp,
-		ExpiresAt: time.Now().Add(10 * time.Minute),
-	}
-	
+
+	st.CodeHash = HashOTP(bookingID, purpose, otp)
+	st.ExpiresAt = now.Add(OTPTTL)
+	st.ConsumedAt = nil
+	st.IssueCount++
+	st.LastIssuedAt = &now
+
 	return otp, nil
 }
 
-func VerifyOTP(bookingID string, otpType string, providedOTP string) bool {
-	key := fmt.Sprintf("%s_%s", bookingID, otpType)
-	
-	stored, exists := otpStorage[key]
+func (st *otpState) verify(bookingID uint, purpose string, code string, now time.Time) error {
+	if st.LockedUntil != nil && now.Before(*st.LockedUntil) {
+		return &OTPRetryError{Err: ErrOTPLocked, RetryAt: *st.LockedUntil}
+	}
+
+	if st.ConsumedAt != nil || st.CodeHash == "" {
+		return ErrOTPNotFound
+	}
+
+	if now.After(st.ExpiresAt) {
+		return ErrOTPExpired
+	}
+
+	if !hmac.Equal([]byte(HashOTP(bookingID, purpose, code)), []byte(st.CodeHash)) {
+		st.Attempts++
+		if st.Attempts >= OTPMaxAttempts {
+			// Locking also burns the current code; the owner has to issue a
+			// new one once the lockout is over.
+			until := now.Add(OTPLockoutDuration)
+			st.LockedUntil = &until
+			st.CodeHash = ""
+			return &OTPRetryError{Err: ErrOTPLocked, RetryAt: until, LockoutTriggered: true}
+		}
+		return ErrOTPInvalid
+	}
+
+	st.ConsumedAt = &now
+	st.CodeHash = ""
+	st.Attempts = 0
+	st.IssueCount = 0
+	st.LockedUntil = nil
+	return nil
+}
+
+type MemoryOTPStore struct {
+	mu      sync.Mutex
+	entries map[string]*otpState
+}
+
+func NewMemoryOTPStore() *MemoryOTPStore {
+	return &MemoryOTPStore{entries: make(map[string]*otpState)}
+}
+
+func otpKey(bookingID uint, purpose string) string {
+	return fmt.Sprintf("%d_%s", bookingID, purpose)
+}
+
+func (s *MemoryOTPStore) Issue(bookingID uint, purpose string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := otpKey(bookingID, purpose)
+	st, exists := s.entries[key]
 	if !exists {
-		return false
+		st = &otpState{}
+		s.entries[key] = st
 	}
-	
-	if time.Now().After(stored.ExpiresAt) {
-		delete(otpStorage, key)
-		return false
+
+	return st.issue(bookingID, purpose, time.Now())
+}
+
+func (s *MemoryOTPStore) Verify(bookingID uint, purpose string, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, exists := s.entries[otpKey(bookingID, purpose)]
+	if !exists {
+		return ErrOTPNotFound
+	}
+
+	return st.verify(bookingID, purpose, code, time.Now())
+}
+
+func (s *MemoryOTPStore) Clear(bookingID uint, purpose string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if st, exists := s.entries[otpKey(bookingID, purpose)]; exists {
+		st.CodeHash = ""
 	}
-	
-	if stored.Code != providedOTP {
-		return false
+	return nil
+}
+
+type DBOTPStore struct {
+	DB *gorm.DB
+}
+
+func NewDBOTPStore(db *gorm.DB) *DBOTPStore {
+	return &DBOTPStore{DB: db}
+}
+
+func (s *DBOTPStore) Issue(bookingID uint, purpose string) (string, error) {
+	var otp string
+	var issueErr error
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// A row lock needs a row: create it first so two first-time issues
+		// for the same booking serialize on the lock instead of racing on
+		// the unique index.
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "booking_id"}, {Name: "purpose"}},
+			DoNothing: true,
+		}).Create(&models.OTPCode{BookingID: bookingID, Purpose: purpose}).Error; err != nil {
+			return err
+		}
+
+		record, err := s.lock(tx, bookingID, purpose)
+		if err != nil {
+			return err
+		}
+
+		st := stateFromRecord(record)
+		otp, issueErr = st.issue(bookingID, purpose, time.Now())
+		if issueErr != nil {
+			return nil
+		}
+
+		applyStateToRecord(st, record)
+		return tx.Save(record).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	if issueErr != nil {
+		return "", issueErr
+	}
+
+	return otp, nil
+}
+
+func (s *DBOTPStore) Verify(bookingID uint, purpose string, code string) error {
+	var verifyErr error
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		record, err := s.lock(tx, bookingID, purpose)
+		if err != nil {
+			return err
+		}
+		if record.ID == 0 {
+			verifyErr = ErrOTPNotFound
+			return nil
+		}
+
+		// The state is saved even when verification fails so failed
+		// attempts and lockouts survive across requests.
+		st := stateFromRecord(record)
+		verifyErr = st.verify(bookingID, purpose, code, time.Now())
+
+		applyStateToRecord(st, record)
+		return tx.Save(record).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return verifyErr
+}
+
+func (s *DBOTPStore) Clear(bookingID uint, purpose string) error {
+	return s.DB.Model(&models.OTPCode{}).
+		Where("booking_id = ? AND purpose = ?", bookingID, purpose).
+		Update("code_hash", "").Error
+}
+
+func (s *DBOTPStore) lock(tx *gorm.DB, bookingID uint, purpose string) (*models.OTPCode, error) {
+	record := models.OTPCode{BookingID: bookingID, Purpose: purpose}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("booking_id = ? AND purpose = ?", bookingID, purpose).
+		First(&record).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func stateFromRecord(record *models.OTPCode) *otpState {
+	return &otpState{
+		CodeHash:     record.CodeHash,
+		ExpiresAt:    record.ExpiresAt,
+		ConsumedAt:   record.ConsumedAt,
+		Attempts:     record.Attempts,
+		LockedUntil:  record.LockedUntil,
+		IssueCount:   record.IssueCount,
+		LastIssuedAt: record.LastIssuedAt,
 	}
-	
-	delete(otpStorage, key)
-	return true
 }
 
-func ClearOTP(bookingID string, otpType string) {
-	key := fmt.Sprintf("%s_%s", bookingID, otpType)
-	delete(otpStorage, key)
+func applyStateToRecord(st *otpState, record *models.OTPCode) {
+	record.CodeHash = st.CodeHash
+	record.ExpiresAt = st.ExpiresAt
+	record.ConsumedAt = st.ConsumedAt
+	record.Attempts = st.Attempts
+	record.LockedUntil = st.LockedUntil
+	record.IssueCount = st.IssueCount
+	record.LastIssuedAt = st.LastIssuedAt
 }
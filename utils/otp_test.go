@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const testPurpose = "pickup"
+
+// wrongOTP returns a code that differs from code.
+func wrongOTP(code string) string {
+	if code == "000000" {
+		return "000001"
+	}
+	return "000000"
+}
+
+// backdate moves the stored state of a booking's code into the past, as if
+// d had passed since it was last touched.
+func backdate(s *MemoryOTPStore, bookingID uint, d time.Duration) {
+	st := s.entries[otpKey(bookingID, testPurpose)]
+	st.ExpiresAt = st.ExpiresAt.Add(-d)
+	if st.LastIssuedAt != nil {
+		t := st.LastIssuedAt.Add(-d)
+		st.LastIssuedAt = &t
+	}
+	if st.LockedUntil != nil {
+		t := st.LockedUntil.Add(-d)
+		st.LockedUntil = &t
+	}
+}
+
+func TestMemoryOTPStoreVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		// submit prepares the store after code was issued for booking 1 and
+		// returns the booking, purpose and code to verify.
+		submit  func(s *MemoryOTPStore, code string) (uint, string, string)
+		wantErr error
+	}{
+		{
+			name:   "correct code",
+			submit: func(s *MemoryOTPStore, code string) (uint, string, string) { return 1, testPurpose, code },
+		},
+		{
+			name:    "wrong code",
+			submit:  func(s *MemoryOTPStore, code string) (uint, string, string) { return 1, testPurpose, wrongOTP(code) },
+			wantErr: ErrOTPInvalid,
+		},
+		{
+			name: "code already used",
+			submit: func(s *MemoryOTPStore, code string) (uint, string, string) {
+				s.Verify(1, testPurpose, code)
+				return 1, testPurpose, code
+			},
+			wantErr: ErrOTPNotFound,
+		},
+		{
+			name: "expired code",
+			submit: func(s *MemoryOTPStore, code string) (uint, string, string) {
+				backdate(s, 1, OTPTTL+time.Second)
+				return 1, testPurpose, code
+			},
+			wantErr: ErrOTPExpired,
+		},
+		{
+			name: "cleared code",
+			submit: func(s *MemoryOTPStore, code string) (uint, string, string) {
+				s.Clear(1, testPurpose)
+				return 1, testPurpose, code
+			},
+			wantErr: ErrOTPNotFound,
+		},
+		{
+			name: "code for another booking",
+			submit: func(s *MemoryOTPStore, code string) (uint, string, string) {
+				s.Issue(2, testPurpose)
+				return 2, testPurpose, code
+			},
+			wantErr: ErrOTPInvalid,
+		},
+		{
+			name:    "code for another purpose",
+			submit:  func(s *MemoryOTPStore, code string) (uint, string, string) { return 1, "return", code },
+			wantErr: ErrOTPNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryOTPStore()
+			code, err := s.Issue(1, testPurpose)
+			if err != nil {
+				t.Fatalf("issue: %v", err)
+			}
+
+			bookingID, purpose, submitted := tt.submit(s, code)
+			err = s.Verify(bookingID, purpose, submitted)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("verify: unexpected error %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verify: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMemoryOTPStoreLockout(t *testing.T) {
+	s := NewMemoryOTPStore()
+	code, err := s.Issue(1, testPurpose)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	for i := 1; i < OTPMaxAttempts; i++ {
+		if err := s.Verify(1, testPurpose, wrongOTP(code)); !errors.Is(err, ErrOTPInvalid) {
+			t.Fatalf("attempt %d: got %v, want %v", i, err, ErrOTPInvalid)
+		}
+	}
+
+	var retry *OTPRetryError
+	err = s.Verify(1, testPurpose, wrongOTP(code))
+	if !errors.As(err, &retry) || !errors.Is(err, ErrOTPLocked) || !retry.LockoutTriggered {
+		t.Fatalf("attempt %d: got %v, want a triggered lockout", OTPMaxAttempts, err)
+	}
+
+	// The right code is refused while locked, and only the attempt that
+	// caused the lockout reports it.
+	err = s.Verify(1, testPurpose, code)
+	if !errors.As(err, &retry) || !errors.Is(err, ErrOTPLocked) || retry.LockoutTriggered {
+		t.Fatalf("verify while locked: got %v, want a lockout that was already triggered", err)
+	}
+	if _, err := s.Issue(1, testPurpose); !errors.Is(err, ErrOTPLocked) {
+		t.Fatalf("issue while locked: got %v, want %v", err, ErrOTPLocked)
+	}
+
+	backdate(s, 1, OTPLockoutDuration+time.Second)
+
+	// Locking burned the old code, and a new one gets a fresh set of tries.
+	if err := s.Verify(1, testPurpose, code); !errors.Is(err, ErrOTPNotFound) {
+		t.Fatalf("verify burned code: got %v, want %v", err, ErrOTPNotFound)
+	}
+	code, err = s.Issue(1, testPurpose)
+	if err != nil {
+		t.Fatalf("issue after lockout: %v", err)
+	}
+	if err := s.Verify(1, testPurpose, wrongOTP(code)); !errors.Is(err, ErrOTPInvalid) {
+		t.Fatalf("verify after lockout: got %v, want %v", err, ErrOTPInvalid)
+	}
+}
+
+func TestMemoryOTPStoreSuccessResetsCounters(t *testing.T) {
+	s := NewMemoryOTPStore()
+	code, err := s.Issue(1, testPurpose)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	for i := 1; i < OTPMaxAttempts; i++ {
+		s.Verify(1, testPurpose, wrongOTP(code))
+	}
+	if err := s.Verify(1, testPurpose, code); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	// A success clears the failed attempts and the back-off, so the next
+	// code can be issued straight away and gets every try again.
+	code, err = s.Issue(1, testPurpose)
+	if err != nil {
+		t.Fatalf("issue after success: %v", err)
+	}
+	for i := 1; i < OTPMaxAttempts; i++ {
+		if err := s.Verify(1, testPurpose, wrongOTP(code)); !errors.Is(err, ErrOTPInvalid) {
+			t.Fatalf("attempt %d after success: got %v, want %v", i, err, ErrOTPInvalid)
+		}
+	}
+}
+
+func TestMemoryOTPStoreRegenerateBackoff(t *testing.T) {
+	s := NewMemoryOTPStore()
+	if _, err := s.Issue(1, testPurpose); err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	// Each regeneration waits twice as long as the one before.
+	for _, wait := range []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute} {
+		var retry *OTPRetryError
+		_, err := s.Issue(1, testPurpose)
+		if !errors.As(err, &retry) || !errors.Is(err, ErrOTPCooldown) {
+			t.Fatalf("issue within %s: got %v, want %v", wait, err, ErrOTPCooldown)
+		}
+		lastIssued := *s.entries[otpKey(1, testPurpose)].LastIssuedAt
+		if got := retry.RetryAt.Sub(lastIssued); got != wait {
+			t.Fatalf("retry after %s, want %s", got, wait)
+		}
+
+		backdate(s, 1, wait)
+		if _, err := s.Issue(1, testPurpose); err != nil {
+			t.Fatalf("issue after %s: %v", wait, err)
+		}
+	}
+}
+
+func TestRegenerateDelay(t *testing.T) {
+	tests := []struct {
+		issueCount int
+		want       time.Duration
+	}{
+		{0, 0},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{6, OTPRegenerateMaxDelay},
+		{50, OTPRegenerateMaxDelay},
+	}
+
+	for _, tt := range tests {
+		if got := regenerateDelay(tt.issueCount); got != tt.want {
+			t.Errorf("regenerateDelay(%d) = %s, want %s", tt.issueCount, got, tt.want)
+		}
+	}
+}
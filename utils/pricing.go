@@ -25,37 +25,20 @@ func CalculateFuelCost(fuelConsumedLiters float64, fuelPricePerLiter int64) int6
 }
 
 func EstimatePrice(vehicle *models.Vehicle, estimatedDistanceKm float64, durationHours int, pricingModel string) int64 {
-	switch pricingModel {
-	case models.PricingModelDistance:
-		return CalculateDistanceBasedPrice(estimatedDistanceKm, vehicle.PricePerKm, vehicle.BasePrice)
-	case models.PricingModelTime:
-		return CalculateTimeBasedPrice(durationHours, vehicle.PricePerHour, vehicle.BasePrice)
-	case models.PricingModelHybrid:
-		return CalculateHybridPrice(estimatedDistanceKm, durationHours, vehicle.PricePerKm, vehicle.PricePerHour, vehicle.BasePrice)
-	default:
-		return CalculateDistanceBasedPrice(estimatedDistanceKm, vehicle.PricePerKm, vehicle.BasePrice)
-	}
+	return QuoteEstimate(vehicle, estimatedDistanceKm, durationHours, pricingModel).Total
 }
 
 func CalculateFinalPrice(booking *models.Booking) int64 {
-	var finalPrice int64
-	
-	switch booking.PricingModel {
-	case models.PricingModelDistance:
-		finalPrice = CalculateDistanceBasedPrice(booking.ActualDistanceKm, booking.PricePerKm, booking.BasePrice)
-	case models.PricingModelTime:
-		actualHours := int(math.Ceil(booking.ReturnTime.Sub(booking.PickupTime).Hours()))
-		finalPrice = CalculateTimeBasedPrice(actualHours, booking.PricePerHour, booking.BasePrice)
-	case models.PricingModelHybrid:
-		actualHours := int(math.Ceil(booking.ReturnTime.Sub(booking.PickupTime).Hours()))
-		finalPrice = CalculateHybridPrice(booking.ActualDistanceKm, actualHours, booking.PricePerKm, booking.PricePerHour, booking.BasePrice)
-	default:
-		finalPrice = booking.EstimatedPrice
+	return QuoteFinal(booking).Total
+}
+
+// billableEndTime stops the regular hourly charge at the scheduled end; time
+// past it is covered by the late fee instead.
+func billableEndTime(booking *models.Booking) time.Time {
+	if booking.ReturnTime.After(booking.EndTime) {
+		return booking.EndTime
 	}
-	
-	finalPrice += booking.FuelCostCharged
-	
-	return finalPrice
+	return booking.ReturnTime
 }
 
 func CalculateDuration(startTime, endTime time.Time) int {
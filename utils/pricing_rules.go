@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"proj/config"
+	"proj/models"
+)
+
+const (
+	MinPricingAdjustmentPercent = -90
+	MaxPricingAdjustmentPercent = 300
+)
+
+func ValidatePricingRule(rule *models.PricingRule) error {
+	if rule.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if rule.AdjustmentPercent == 0 || rule.AdjustmentPercent < MinPricingAdjustmentPercent || rule.AdjustmentPercent > MaxPricingAdjustmentPercent {
+		return errors.New("adjustment_percent must be non-zero and between -90 and 300")
+	}
+
+	switch rule.Type {
+	case models.PricingRuleWeekend:
+	case models.PricingRuleDateRange:
+		if rule.StartsAt == nil || rule.EndsAt == nil || !rule.StartsAt.Before(*rule.EndsAt) {
+			return errors.New("date_range rules need starts_at before ends_at")
+		}
+	case models.PricingRuleTimeOfDay:
+		if rule.StartHour < 0 || rule.StartHour > 23 || rule.EndHour < 0 || rule.EndHour > 23 || rule.StartHour == rule.EndHour {
+			return errors.New("time_of_day rules need different start_hour and end_hour between 0 and 23")
+		}
+	case models.PricingRuleEarlyBird, models.PricingRuleLastMinute:
+		if rule.LeadHours <= 0 {
+			return errors.New("early_bird and last_minute rules need a positive lead_hours")
+		}
+	default:
+		return errors.New("type must be weekend, date_range, time_of_day, early_bird or last_minute")
+	}
+
+	return nil
+}
+
+// ApplyPricingRules adds a line item for every active rule that covers part
+// of the rental. Calendar rules are prorated by the share of rental hours
+// they cover; early-bird and last-minute rules apply to the whole rental
+// depending on how far ahead of start it was booked. Adjustments are a
+// percentage of the quote so far and never take the total below zero.
+func ApplyPricingRules(quote *models.PriceQuote, rules []models.PricingRule, start, end, bookedAt time.Time) {
+	subtotal := quote.Total
+	if subtotal <= 0 || !start.Before(end) {
+		return
+	}
+
+	loc := config.GetTimeLocation()
+
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.IsActive {
+			continue
+		}
+
+		coverage := pricingRuleCoverage(rule, start, end, bookedAt, loc)
+		if coverage <= 0 {
+			continue
+		}
+
+		amount := int64(math.Round(float64(subtotal) * float64(rule.AdjustmentPercent) / 100 * coverage))
+		if quote.Total+amount < 0 {
+			amount = -quote.Total
+		}
+		if amount == 0 {
+			continue
+		}
+
+		quote.Add(models.PriceLineItem{
+			Code:        models.PriceLineItemPricingRule,
+			Description: rule.Name,
+			Quantity:    math.Round(coverage*100) / 100,
+			Unit:        "share",
+			Amount:      amount,
+			RuleID:      rule.ID,
+		})
+	}
+}
+
+func pricingRuleCoverage(rule *models.PricingRule, start, end, bookedAt time.Time, loc *time.Location) float64 {
+	lead := start.Sub(bookedAt)
+	leadLimit := time.Duration(rule.LeadHours) * time.Hour
+
+	switch rule.Type {
+	case models.PricingRuleEarlyBird:
+		if lead >= leadLimit {
+			return 1
+		}
+		return 0
+	case models.PricingRuleLastMinute:
+		if lead < leadLimit {
+			return 1
+		}
+		return 0
+	case models.PricingRuleDateRange:
+		if rule.StartsAt == nil || rule.EndsAt == nil {
+			return 0
+		}
+		overlapStart, overlapEnd := start, end
+		if rule.StartsAt.After(overlapStart) {
+			overlapStart = *rule.StartsAt
+		}
+		if rule.EndsAt.Before(overlapEnd) {
+			overlapEnd = *rule.EndsAt
+		}
+		if !overlapStart.Before(overlapEnd) {
+			return 0
+		}
+		return overlapEnd.Sub(overlapStart).Hours() / end.Sub(start).Hours()
+	case models.PricingRuleWeekend, models.PricingRuleTimeOfDay:
+		var covered time.Duration
+		for t := start; t.Before(end); t = t.Add(time.Hour) {
+			step := time.Hour
+			if remaining := end.Sub(t); remaining < step {
+				step = remaining
+			}
+			if pricingRuleMatchesHour(rule, t.In(loc)) {
+				covered += step
+			}
+		}
+		return covered.Hours() / end.Sub(start).Hours()
+	}
+
+	return 0
+}
+
+func pricingRuleMatchesHour(rule *models.PricingRule, t time.Time) bool {
+	if rule.Type == models.PricingRuleWeekend {
+		return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+	}
+
+	hour := t.Hour()
+	if rule.StartHour < rule.EndHour {
+		return hour >= rule.StartHour && hour < rule.EndHour
+	}
+	// Windows such as 22 to 6 wrap past midnight.
+	return hour >= rule.StartHour || hour < rule.EndHour
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"proj/config"
+	"proj/models"
+)
+
+func TestApplyPricingRules(t *testing.T) {
+	loc := config.GetTimeLocation()
+	// A Wednesday and a Saturday, four hours each, booked two days ahead.
+	weekday := time.Date(2026, 3, 11, 10, 0, 0, 0, loc)
+	saturday := time.Date(2026, 3, 14, 10, 0, 0, 0, loc)
+
+	weekend := models.PricingRule{Name: "Weekend", Type: models.PricingRuleWeekend, AdjustmentPercent: 20, IsActive: true}
+	earlyBird := models.PricingRule{Name: "Early bird", Type: models.PricingRuleEarlyBird, AdjustmentPercent: -10, LeadHours: 24, IsActive: true}
+	lastMinute := models.PricingRule{Name: "Last minute", Type: models.PricingRuleLastMinute, AdjustmentPercent: 30, LeadHours: 24, IsActive: true}
+	night := models.PricingRule{Name: "Night", Type: models.PricingRuleTimeOfDay, AdjustmentPercent: 20, StartHour: 22, EndHour: 6, IsActive: true}
+	bigDiscount := models.PricingRule{Name: "Clearance", Type: models.PricingRuleWeekend, AdjustmentPercent: -90, IsActive: true}
+
+	rangeStart, rangeEnd := saturday.Add(2*time.Hour), saturday.Add(48*time.Hour)
+	festival := models.PricingRule{Name: "Festival", Type: models.PricingRuleDateRange, AdjustmentPercent: 20, StartsAt: &rangeStart, EndsAt: &rangeEnd, IsActive: true}
+
+	inactiveWeekend := weekend
+	inactiveWeekend.IsActive = false
+
+	tests := []struct {
+		name     string
+		rules    []models.PricingRule
+		start    time.Time
+		hours    int
+		bookedAt time.Time
+		want     int64
+	}{
+		{name: "no rules", start: saturday, hours: 4, want: 1000},
+		{name: "rule outside the rental", rules: []models.PricingRule{weekend}, start: weekday, hours: 4, want: 1000},
+		{name: "inactive rule", rules: []models.PricingRule{inactiveWeekend}, start: saturday, hours: 4, want: 1000},
+		// Every rule is a percentage of the price before any rule, so rules
+		// don't compound and their order doesn't matter.
+		{name: "rules add up without compounding", rules: []models.PricingRule{weekend, earlyBird}, start: saturday, hours: 4, want: 1100},
+		{name: "rule order doesn't matter", rules: []models.PricingRule{earlyBird, weekend}, start: saturday, hours: 4, want: 1100},
+		{name: "early bird needs the lead time", rules: []models.PricingRule{earlyBird, lastMinute}, start: weekday, hours: 4, bookedAt: weekday.Add(-2 * time.Hour), want: 1300},
+		{name: "date range prorated by overlap", rules: []models.PricingRule{festival}, start: saturday, hours: 4, want: 1100},
+		{name: "time of day window past midnight", rules: []models.PricingRule{night}, start: weekday.Add(10 * time.Hour), hours: 4, want: 1100},
+		{name: "discounts never go below zero", rules: []models.PricingRule{bigDiscount, bigDiscount}, start: saturday, hours: 4, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bookedAt := tt.bookedAt
+			if bookedAt.IsZero() {
+				bookedAt = tt.start.Add(-48 * time.Hour)
+			}
+
+			quote := models.PriceQuote{}
+			quote.Add(models.PriceLineItem{Code: models.PriceLineItemTime, Amount: 1000})
+
+			ApplyPricingRules(&quote, tt.rules, tt.start, tt.start.Add(time.Duration(tt.hours)*time.Hour), bookedAt)
+			if quote.Total != tt.want {
+				t.Errorf("total = %d, want %d", quote.Total, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"crypto/rand"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"proj/models"
+)
+
+// PromoIneligibleError explains why a promo code can't be used. The reason
+// is safe to show to the renter.
+type PromoIneligibleError struct {
+	Reason string
+}
+
+func (e *PromoIneligibleError) Error() string {
+	return "promo code not applicable: " + e.Reason
+}
+
+const referralCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func ValidatePromoCode(promo *models.PromoCode) error {
+	if promo.Code == "" {
+		return errors.New("code is required")
+	}
+
+	switch promo.DiscountType {
+	case models.PromoDiscountPercent:
+		if promo.DiscountValue <= 0 || promo.DiscountValue > 100 {
+			return errors.New("percent discounts must be between 1 and 100")
+		}
+	case models.PromoDiscountFlat:
+		if promo.DiscountValue <= 0 {
+			return errors.New("flat discounts must be positive")
+		}
+	default:
+		return errors.New("discount_type must be percent or flat")
+	}
+
+	if promo.MaxDiscount < 0 || promo.MinBookingAmount < 0 || promo.UsageLimit < 0 || promo.PerUserLimit < 0 {
+		return errors.New("limits cannot be negative")
+	}
+
+	if promo.StartsAt != nil && promo.ExpiresAt != nil && !promo.StartsAt.Before(*promo.ExpiresAt) {
+		return errors.New("starts_at must be before expires_at")
+	}
+
+	return nil
+}
+
+// CheckPromoEligibility applies a code's targeting and limits to a renter.
+// priorBookings and userRedemptions are the renter's bookings that weren't
+// cancelled or expired and their live redemptions of this code.
+func CheckPromoEligibility(promo *models.PromoCode, user *models.User, subtotal int64, priorBookings int64, userRedemptions int64, now time.Time) error {
+	if !promo.IsActive {
+		return &PromoIneligibleError{Reason: "this code is no longer active"}
+	}
+	if promo.StartsAt != nil && now.Before(*promo.StartsAt) {
+		return &PromoIneligibleError{Reason: "this code is not active yet"}
+	}
+	if promo.ExpiresAt != nil && !now.Before(*promo.ExpiresAt) {
+		return &PromoIneligibleError{Reason: "this code has expired"}
+	}
+	if promo.UsageLimit > 0 && promo.UsedCount >= promo.UsageLimit {
+		return &PromoIneligibleError{Reason: "this code has reached its usage limit"}
+	}
+	if promo.PerUserLimit > 0 && userRedemptions >= int64(promo.PerUserLimit) {
+		return &PromoIneligibleError{Reason: "you have already used this code"}
+	}
+	if promo.FirstRideOnly && priorBookings > 0 {
+		return &PromoIneligibleError{Reason: "this code is only valid on your first ride"}
+	}
+	if promo.Department != "" && !strings.EqualFold(promo.Department, user.Department) {
+		return &PromoIneligibleError{Reason: "this code is not available for your department"}
+	}
+	if subtotal < promo.MinBookingAmount {
+		return &PromoIneligibleError{Reason: "booking amount is below the minimum for this code"}
+	}
+	return nil
+}
+
+// PromoDiscount is the amount a code takes off subtotal, never more than
+// the subtotal itself.
+func PromoDiscount(promo *models.PromoCode, subtotal int64) int64 {
+	var discount int64
+	switch promo.DiscountType {
+	case models.PromoDiscountPercent:
+		discount = int64(math.Round(float64(subtotal) * float64(promo.DiscountValue) / 100))
+		if promo.MaxDiscount > 0 && discount > promo.MaxDiscount {
+			discount = promo.MaxDiscount
+		}
+	case models.PromoDiscountFlat:
+		discount = promo.DiscountValue
+	}
+
+	if discount > subtotal {
+		discount = subtotal
+	}
+	if discount < 0 {
+		discount = 0
+	}
+	return discount
+}
+
+// ApplyBookingDiscounts adds the promo discount and credits fixed on the
+// booking when it was made. They are fixed amounts, capped so the quote
+// never goes below zero.
+func ApplyBookingDiscounts(quote *models.PriceQuote, booking *models.Booking) {
+	if booking.PromoDiscount > 0 {
+		quote.Add(models.PriceLineItem{
+			Code:        models.PriceLineItemPromo,
+			Description: "Promo code " + booking.PromoCode,
+			Amount:      -minInt64(booking.PromoDiscount, quote.Total),
+		})
+	}
+
+	if booking.CreditsApplied > 0 {
+		quote.Add(models.PriceLineItem{
+			Code:        models.PriceLineItemCredits,
+			Description: "Credits",
+			Amount:      -minInt64(booking.CreditsApplied, quote.Total),
+		})
+	}
+}
+
+func GenerateReferralCode() (string, error) {
+	code := make([]byte, 8)
+	max := big.NewInt(int64(len(referralCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = referralCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+
+	"proj/models"
+)
+
+const (
+	hoursPerDay        = 24
+	weeklyDiscountDays = 7
+)
+
+// pricingRates are the rates a quote is computed from: the vehicle's current
+// rates for estimates, the ones captured on the booking for final prices.
+type pricingRates struct {
+	BasePrice             int64
+	PricePerKm            int64
+	PricePerHour          int64
+	PricePerDay           int64
+	WeeklyDiscountPercent int
+}
+
+func vehicleRates(vehicle *models.Vehicle) pricingRates {
+	return pricingRates{
+		BasePrice:             vehicle.BasePrice,
+		PricePerKm:            vehicle.PricePerKm,
+		PricePerHour:          vehicle.PricePerHour,
+		PricePerDay:           vehicle.PricePerDay,
+		WeeklyDiscountPercent: vehicle.WeeklyDiscountPercent,
+	}
+}
+
+func bookingRates(booking *models.Booking) pricingRates {
+	return pricingRates{
+		BasePrice:             booking.BasePrice,
+		PricePerKm:            booking.PricePerKm,
+		PricePerHour:          booking.PricePerHour,
+		PricePerDay:           booking.PricePerDay,
+		WeeklyDiscountPercent: booking.WeeklyDiscountPercent,
+	}
+}
+
+func IsValidPricingModel(pricingModel string) bool {
+	switch pricingModel {
+	case models.PricingModelDistance, models.PricingModelTime, models.PricingModelHybrid, models.PricingModelDaily:
+		return true
+	}
+	return false
+}
+
+// QuoteEstimate prices a prospective trip from the vehicle's rates. Unknown
+// pricing models fall back to distance pricing, as EstimatePrice always has.
+func QuoteEstimate(vehicle *models.Vehicle, distanceKm float64, durationHours int, pricingModel string) models.PriceQuote {
+	if !IsValidPricingModel(pricingModel) {
+		pricingModel = models.PricingModelDistance
+	}
+
+	quote := models.PriceQuote{PricingModel: pricingModel}
+	addUsageItems(&quote, pricingModel, distanceKm, durationHours, vehicleRates(vehicle))
+	return quote
+}
+
+// QuoteFinal prices a returned booking from its actual usage and the rates
+// and pricing rules captured on it, less the promo discount and credits used
+// when booking, plus the fuel and late charges worked out at return.
+func QuoteFinal(booking *models.Booking) models.PriceQuote {
+	quote := models.PriceQuote{PricingModel: booking.PricingModel}
+
+	if IsValidPricingModel(booking.PricingModel) {
+		endTime := billableEndTime(booking)
+		actualHours := int(math.Ceil(endTime.Sub(booking.PickupTime).Hours()))
+		addUsageItems(&quote, booking.PricingModel, booking.ActualDistanceKm, actualHours, bookingRates(booking))
+		ApplyPricingRules(&quote, booking.PricingRules, booking.PickupTime, endTime, booking.CreatedAt)
+	} else {
+		quote.Add(models.PriceLineItem{
+			Code:        models.PriceLineItemEstimate,
+			Description: "Estimated price",
+			Amount:      booking.EstimatedPrice,
+		})
+	}
+
+	ApplyBookingDiscounts(&quote, booking)
+
+	if booking.FuelCostCharged != 0 {
+		quote.Add(models.PriceLineItem{
+			Code:        models.PriceLineItemFuel,
+			Description: "Refuel charge",
+			Quantity:    booking.FuelRefillLiters,
+			Unit:        "liter",
+			UnitPrice:   booking.FuelPricePerLiter,
+			Amount:      booking.FuelCostCharged,
+		})
+	}
+
+	if booking.LateFee != 0 {
+		quote.Add(models.PriceLineItem{
+			Code:        models.PriceLineItemLateFee,
+			Description: fmt.Sprintf("Late return (%d minutes)", booking.LateMinutes),
+			Quantity:    math.Ceil(float64(booking.LateMinutes) / 60),
+			Unit:        "hour",
+			UnitPrice:   LateFeeRate(booking),
+			Amount:      booking.LateFee,
+		})
+	}
+
+	return quote
+}
+
+func addUsageItems(quote *models.PriceQuote, pricingModel string, distanceKm float64, durationHours int, rates pricingRates) {
+	quote.Add(models.PriceLineItem{
+		Code:        models.PriceLineItemBase,
+		Description: "Base fare",
+		Amount:      rates.BasePrice,
+	})
+
+	if pricingModel == models.PricingModelDistance || pricingModel == models.PricingModelHybrid {
+		quote.Add(models.PriceLineItem{
+			Code:        models.PriceLineItemDistance,
+			Description: "Distance",
+			Quantity:    distanceKm,
+			Unit:        "km",
+			UnitPrice:   rates.PricePerKm,
+			Amount:      int64(math.Round(distanceKm * float64(rates.PricePerKm))),
+		})
+	}
+
+	var timeItems []models.PriceLineItem
+	switch pricingModel {
+	case models.PricingModelTime, models.PricingModelHybrid:
+		timeItems = hourlyTimeItems(durationHours, rates)
+	case models.PricingModelDaily:
+		days := int(math.Ceil(float64(durationHours) / hoursPerDay))
+		if days < 1 {
+			days = 1
+		}
+		timeItems = []models.PriceLineItem{{
+			Code:        models.PriceLineItemDays,
+			Description: "Days",
+			Quantity:    float64(days),
+			Unit:        "day",
+			UnitPrice:   rates.PricePerDay,
+			Amount:      int64(days) * rates.PricePerDay,
+		}}
+	}
+
+	var timeAmount int64
+	for _, item := range timeItems {
+		quote.Add(item)
+		timeAmount += item.Amount
+	}
+
+	if discount := weeklyDiscount(timeAmount, durationHours, rates.WeeklyDiscountPercent); discount > 0 {
+		quote.Add(models.PriceLineItem{
+			Code:        models.PriceLineItemWeeklyDiscount,
+			Description: fmt.Sprintf("Weekly discount (%d%%)", rates.WeeklyDiscountPercent),
+			Amount:      -discount,
+		})
+	}
+}
+
+// hourlyTimeItems bills by the hour but never charges more than PricePerDay
+// for any 24 hour stretch: whole days are billed at the cheaper of the day
+// rate and 24 hours, and the remaining hours are capped at the day rate.
+func hourlyTimeItems(durationHours int, rates pricingRates) []models.PriceLineItem {
+	days := durationHours / hoursPerDay
+	hours := durationHours % hoursPerDay
+
+	dayRate := hoursPerDay * rates.PricePerHour
+	if rates.PricePerDay > 0 && rates.PricePerDay < dayRate {
+		dayRate = rates.PricePerDay
+	}
+
+	var items []models.PriceLineItem
+	if days > 0 {
+		items = append(items, models.PriceLineItem{
+			Code:        models.PriceLineItemDays,
+			Description: "Full days",
+			Quantity:    float64(days),
+			Unit:        "day",
+			UnitPrice:   dayRate,
+			Amount:      int64(days) * dayRate,
+		})
+	}
+
+	if hours > 0 || days == 0 {
+		item := models.PriceLineItem{
+			Code:        models.PriceLineItemTime,
+			Description: "Time",
+			Quantity:    float64(hours),
+			Unit:        "hour",
+			UnitPrice:   rates.PricePerHour,
+			Amount:      int64(hours) * rates.PricePerHour,
+		}
+		if rates.PricePerDay > 0 && item.Amount > rates.PricePerDay {
+			item.Description = "Time (capped at day rate)"
+			item.Amount = rates.PricePerDay
+		}
+		items = append(items, item)
+	}
+
+	return items
+}
+
+func weeklyDiscount(timeAmount int64, durationHours int, percent int) int64 {
+	if percent <= 0 || durationHours < weeklyDiscountDays*hoursPerDay {
+		return 0
+	}
+	return int64(math.Round(float64(timeAmount) * float64(percent) / 100))
+}
@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"proj/models"
+)
+
+func lineItemCodes(quote models.PriceQuote) []string {
+	codes := []string{}
+	for _, item := range quote.LineItems {
+		codes = append(codes, item.Code)
+	}
+	return codes
+}
+
+func TestQuoteFinal(t *testing.T) {
+	pickup := time.Date(2026, 3, 11, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		booking   models.Booking
+		wantCodes []string
+		wantTotal int64
+	}{
+		{
+			name:      "returned early is billed to the return",
+			booking:   models.Booking{ReturnTime: pickup.Add(3 * time.Hour)},
+			wantCodes: []string{models.PriceLineItemBase, models.PriceLineItemTime},
+			wantTotal: 350,
+		},
+		{
+			name:      "returned late is billed to the end with a separate late fee",
+			booking:   models.Booking{ReturnTime: pickup.Add(6 * time.Hour), LateFee: 100, LateMinutes: 60},
+			wantCodes: []string{models.PriceLineItemBase, models.PriceLineItemTime, models.PriceLineItemLateFee},
+			wantTotal: 650,
+		},
+		{
+			name:      "promo and credits",
+			booking:   models.Booking{ReturnTime: pickup.Add(5 * time.Hour), PromoDiscount: 100, CreditsApplied: 100},
+			wantCodes: []string{models.PriceLineItemBase, models.PriceLineItemTime, models.PriceLineItemPromo, models.PriceLineItemCredits},
+			wantTotal: 350,
+		},
+		{
+			name:      "credits never take the rental below zero",
+			booking:   models.Booking{ReturnTime: pickup.Add(5 * time.Hour), CreditsApplied: 1000},
+			wantCodes: []string{models.PriceLineItemBase, models.PriceLineItemTime, models.PriceLineItemCredits},
+			wantTotal: 0,
+		},
+		{
+			name:      "fuel is charged after discounts",
+			booking:   models.Booking{ReturnTime: pickup.Add(5 * time.Hour), CreditsApplied: 1000, FuelCostCharged: 200, FuelRefillLiters: 2, FuelPricePerLiter: 100},
+			wantCodes: []string{models.PriceLineItemBase, models.PriceLineItemTime, models.PriceLineItemCredits, models.PriceLineItemFuel},
+			wantTotal: 200,
+		},
+		{
+			name:      "distance pricing",
+			booking:   models.Booking{PricingModel: models.PricingModelDistance, ReturnTime: pickup.Add(5 * time.Hour), ActualDistanceKm: 12.5, PricePerKm: 8},
+			wantCodes: []string{models.PriceLineItemBase, models.PriceLineItemDistance},
+			wantTotal: 150,
+		},
+		{
+			name:      "unknown pricing model keeps the estimate",
+			booking:   models.Booking{PricingModel: "legacy", ReturnTime: pickup.Add(5 * time.Hour), EstimatedPrice: 900},
+			wantCodes: []string{models.PriceLineItemEstimate},
+			wantTotal: 900,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			booking := tt.booking
+			if booking.PricingModel == "" {
+				booking.PricingModel = models.PricingModelTime
+			}
+			booking.PickupTime = pickup
+			booking.EndTime = pickup.Add(5 * time.Hour)
+			booking.BasePrice = 50
+			booking.PricePerHour = 100
+
+			quote := QuoteFinal(&booking)
+			if codes := lineItemCodes(quote); !reflect.DeepEqual(codes, tt.wantCodes) {
+				t.Errorf("line items = %v, want %v", codes, tt.wantCodes)
+			}
+			if quote.Total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", quote.Total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestHourlyTimeItemsDayCap(t *testing.T) {
+	tests := []struct {
+		name        string
+		hours       int
+		pricePerDay int64
+		wantCodes   []string
+		wantTotal   int64
+	}{
+		{name: "part day below the cap", hours: 5, pricePerDay: 1500, wantCodes: []string{models.PriceLineItemTime}, wantTotal: 500},
+		{name: "part day capped at the day rate", hours: 20, pricePerDay: 1500, wantCodes: []string{models.PriceLineItemTime}, wantTotal: 1500},
+		{name: "one full day", hours: 24, pricePerDay: 1500, wantCodes: []string{models.PriceLineItemDays}, wantTotal: 1500},
+		{name: "full day and hours", hours: 30, pricePerDay: 1500, wantCodes: []string{models.PriceLineItemDays, models.PriceLineItemTime}, wantTotal: 2100},
+		{name: "full day and capped hours", hours: 45, pricePerDay: 1500, wantCodes: []string{models.PriceLineItemDays, models.PriceLineItemTime}, wantTotal: 3000},
+		{name: "day rate above 24 hours", hours: 26, pricePerDay: 3000, wantCodes: []string{models.PriceLineItemDays, models.PriceLineItemTime}, wantTotal: 2600},
+		{name: "no day rate", hours: 30, wantCodes: []string{models.PriceLineItemDays, models.PriceLineItemTime}, wantTotal: 3000},
+		{name: "zero hours", hours: 0, pricePerDay: 1500, wantCodes: []string{models.PriceLineItemTime}, wantTotal: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := hourlyTimeItems(tt.hours, pricingRates{PricePerHour: 100, PricePerDay: tt.pricePerDay})
+
+			quote := models.PriceQuote{}
+			for _, item := range items {
+				quote.Add(item)
+			}
+			if codes := lineItemCodes(quote); !reflect.DeepEqual(codes, tt.wantCodes) {
+				t.Errorf("line items = %v, want %v", codes, tt.wantCodes)
+			}
+			if quote.Total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", quote.Total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestQuoteEstimateWeeklyDiscount(t *testing.T) {
+	tests := []struct {
+		name         string
+		pricingModel string
+		hours        int
+		distanceKm   float64
+		percent      int
+		wantDiscount int64
+		wantTotal    int64
+	}{
+		{name: "just under a week", pricingModel: models.PricingModelTime, hours: 167, percent: 10, wantTotal: 10500},
+		{name: "a full week", pricingModel: models.PricingModelTime, hours: 168, percent: 10, wantDiscount: -1050, wantTotal: 9450},
+		{name: "daily pricing", pricingModel: models.PricingModelDaily, hours: 170, percent: 10, wantDiscount: -1200, wantTotal: 10800},
+		// Only the time charge is discounted, not the distance.
+		{name: "hybrid pricing", pricingModel: models.PricingModelHybrid, hours: 168, distanceKm: 100, percent: 10, wantDiscount: -1050, wantTotal: 9950},
+		{name: "no discount set", pricingModel: models.PricingModelTime, hours: 168, wantTotal: 10500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vehicle := &models.Vehicle{
+				PricePerHour:          100,
+				PricePerDay:           1500,
+				PricePerKm:            5,
+				WeeklyDiscountPercent: tt.percent,
+			}
+
+			quote := QuoteEstimate(vehicle, tt.distanceKm, tt.hours, tt.pricingModel)
+
+			var discount int64
+			for _, item := range quote.LineItems {
+				if item.Code == models.PriceLineItemWeeklyDiscount {
+					discount += item.Amount
+				}
+			}
+			if discount != tt.wantDiscount {
+				t.Errorf("weekly discount = %d, want %d", discount, tt.wantDiscount)
+			}
+			if quote.Total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", quote.Total, tt.wantTotal)
+			}
+		})
+	}
+}